@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+func runAdd(args []string) {
+	var all, verbose, allowLarge, force bool
+	var maxSize *int64
+	var paths []string
+	for _, arg := range args {
+		switch {
+		case arg == "-A" || arg == "--all":
+			all = true
+		case arg == "-n" || arg == "--dry-run":
+			dryRun = true
+		case arg == "-v" || arg == "--verbose":
+			verbose = true
+		case strings.HasPrefix(arg, "--max-blob-size="):
+			value := strings.TrimPrefix(arg, "--max-blob-size=")
+			size, err := parseSize(value)
+			if err != nil {
+				handleError(fmt.Errorf("invalid --max-blob-size '%s'", value))
+			}
+			maxSize = &size
+		case arg == "--allow-large":
+			allowLarge = true
+		case arg == "-f" || arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			handleError(errors.New("usage: got add [-n] [-v] [-f] [-A] [--max-blob-size=<size>] [--allow-large] [<path>...]"))
+		default:
+			paths = append(paths, prefixPath(arg))
+		}
+	}
+	if len(paths) == 0 {
+		if !all {
+			fmt.Fprintln(os.Stderr, "Nothing specified, nothing added.")
+			advise("addEmptyPathspec", "Maybe you wanted to say 'got add .'?")
+			return
+		}
+		paths = []string{"."}
+	}
+
+	repo, err := currentRepo()
+	if err != nil {
+		handleError(err)
+	}
+	if maxSize == nil {
+		size, err := repo.Config.Size("got", "maxBlobSize", 0)
+		if err != nil {
+			handleError(err)
+		}
+		maxSize = &size
+	}
+	if !allowLarge {
+		maxBlobSize = *maxSize
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+
+	// Group the files to stage by directory so writeBlobs can hash each
+	// directory's files in parallel. Gitlinks are staged directly.
+	dirs := make(map[string][]string)
+	var gitlinks []TreeEntry
+	skip, err := addIgnoreFilter(idx, force)
+	if err != nil {
+		handleError(err)
+	}
+	var ignoredPaths []string
+	for _, p := range paths {
+		// A path named outright that is ignored is an error rather than
+		// silently skipped, unless -f is given.
+		if p != "." {
+			info, statErr := os.Lstat(filepath.FromSlash(p))
+			if statErr == nil {
+				ignored, err := skip(p, info.IsDir())
+				if err != nil {
+					handleError(err)
+				}
+				if ignored {
+					ignoredPaths = append(ignoredPaths, p)
+					continue
+				}
+			}
+		}
+		found, err := collectAddPaths(p, dirs, &gitlinks, skip)
+		if err != nil {
+			handleError(err)
+		}
+		if !found && !tracksPath(idx, p) {
+			handleError(fmt.Errorf("pathspec '%s' did not match any files", p))
+		}
+	}
+	if len(ignoredPaths) > 0 {
+		handleError(fmt.Errorf("the following paths are ignored by one of your .gitignore files:\n%s\nUse -f if you really want to add them", strings.Join(ignoredPaths, "\n")))
+	}
+
+	var staged []*IndexEntry
+	for _, dir := range sortedKeys(dirs) {
+		// Overlapping pathspecs can name the same file twice.
+		names := dirs[dir]
+		sort.Strings(names)
+		names = slices.Compact(names)
+		blobs, err := writeBlobs(filepath.FromSlash(dir), names)
+		if err != nil {
+			handleError(err)
+		}
+		for _, blob := range blobs {
+			if blob.Hash == "" {
+				continue // too large, reported below
+			}
+			p := path.Join(dir, blob.Name)
+			info, err := os.Lstat(filepath.FromSlash(p))
+			if err != nil {
+				handleError(err)
+			}
+			entry, err := newIndexEntry(p, blob.Mode, blob.Hash, info)
+			if err != nil {
+				handleError(err)
+			}
+			staged = append(staged, entry)
+		}
+	}
+	if len(largeBlobs) > 0 {
+		sort.Strings(largeBlobs)
+		handleError(fmt.Errorf("files larger than %d bytes (use --allow-large to add them anyway):\n\t%s", maxBlobSize, strings.Join(largeBlobs, "\n\t")))
+	}
+	for i, link := range gitlinks {
+		if slices.ContainsFunc(gitlinks[:i], func(e TreeEntry) bool { return e.Name == link.Name }) {
+			continue
+		}
+		entry, err := newIndexEntry(link.Name, link.Mode, link.Hash, nil)
+		if err != nil {
+			handleError(err)
+		}
+		staged = append(staged, entry)
+	}
+
+	// Tracked files under a pathspec that are gone from the working tree
+	// are staged as deletions.
+	var removed []string
+	for _, entry := range idx.Entries {
+		if !matchesPaths(entry.Path, paths) {
+			continue
+		}
+		if _, err := os.Lstat(filepath.FromSlash(entry.Path)); err != nil {
+			removed = append(removed, entry.Path)
+		}
+	}
+
+	var changed []*IndexEntry
+	for _, entry := range staged {
+		old := idx.entry(entry.Path)
+		if old == nil || old.Hash != entry.Hash || old.Mode != entry.Mode {
+			changed = append(changed, entry)
+			switch {
+			case dryRun:
+				// The hash was computed without storing the object, so
+				// this shows what would be written.
+				mode := displayMode(entry.ModeString())
+				fmt.Printf("%s %s\t%s\n", gitModes[mode], entry.Hash, entry.Path)
+			case verbose:
+				fmt.Printf("add '%s'\n", entry.Path)
+			}
+		}
+	}
+	for _, p := range removed {
+		if dryRun || verbose {
+			fmt.Printf("remove '%s'\n", p)
+		}
+	}
+	if dryRun {
+		return
+	}
+
+	// Refresh the stat data of unchanged entries in place, then merge in
+	// the rest with a single sort rather than one per path.
+	for _, entry := range staged {
+		if old := idx.entry(entry.Path); old != nil {
+			*old = *entry
+		}
+	}
+	for _, entry := range changed {
+		idx.remove(entry.Path)
+	}
+	for _, p := range removed {
+		idx.remove(p)
+	}
+	idx.Entries = append(idx.Entries, changed...)
+	if err := idx.write(); err != nil {
+		handleError(err)
+	}
+}
+
+// collectAddPaths adds the files at or under p to dirs, keyed by their
+// directory, and nested repositories to gitlinks. Paths below p for which
+// skip returns true are left out. It reports whether p exists in the
+// working tree.
+func collectAddPaths(p string, dirs map[string][]string, gitlinks *[]TreeEntry, skip func(string, bool) (bool, error)) (bool, error) {
+	err := filepath.WalkDir(filepath.FromSlash(p), func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		slashPath := filepath.ToSlash(fullPath)
+		if slashPath != p && d.Name() != ".git" {
+			skipped, err := skip(slashPath, d.IsDir())
+			if err != nil {
+				return err
+			}
+			if skipped && d.IsDir() {
+				return filepath.SkipDir
+			}
+			if skipped {
+				return nil
+			}
+		}
+		if !d.IsDir() {
+			dir, name := path.Split(slashPath)
+			dir = path.Clean(dir)
+			dirs[dir] = append(dirs[dir], name)
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if slashPath != "." && isSubmodule(fullPath) {
+			hash, err := submoduleHead(fullPath)
+			if err != nil {
+				return err
+			}
+			*gitlinks = append(*gitlinks, TreeEntry{Mode: "160000", Name: slashPath, Hash: hash})
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// addIgnoreFilter returns the test for paths "got add" leaves out: ignored
+// ones that are not already tracked. With force nothing is left out.
+func addIgnoreFilter(idx *Index, force bool) (func(string, bool) (bool, error), error) {
+	if force {
+		return func(string, bool) (bool, error) { return false, nil }, nil
+	}
+	ignore, err := newIgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+	trackedDirs := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+	return func(p string, isDir bool) (bool, error) {
+		if (isDir && trackedDirs[p]) || (!isDir && idx.entry(p) != nil) {
+			return false, nil
+		}
+		return ignore.ignored(p, isDir)
+	}, nil
+}
+
+// tracksPath reports whether the index has p or anything under it.
+func tracksPath(idx *Index, p string) bool {
+	for _, entry := range idx.Entries {
+		if matchesPaths(entry.Path, []string{p}) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
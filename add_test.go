@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddMatchesGit(t *testing.T) {
+	large := strings.Repeat("a line of a file too large to want in memory\n", 1<<16)
+	tests := []struct {
+		name     string
+		autocrlf string
+		files    map[string]string
+		symlink  bool
+		exec     bool
+	}{
+		{name: "small", files: map[string]string{"a": "a\n"}},
+		{name: "empty", files: map[string]string{"empty": ""}},
+		{name: "large", files: map[string]string{"big": large}},
+		{name: "nested", files: map[string]string{"d/e/f": "f\n", "d/g": "g\n"}},
+		{name: "crlf kept", files: map[string]string{"w": "one\r\ntwo\r\n"}},
+		{name: "crlf converted", autocrlf: "true", files: map[string]string{"w": "one\r\ntwo\r\n", "big": large}},
+		{name: "crlf input", autocrlf: "input", files: map[string]string{"w": "one\r\ntwo\r\n"}},
+		{name: "executable", files: map[string]string{"run.sh": "echo hi\n"}, exec: true},
+		{name: "symlink", files: map[string]string{"target": "t\n"}, symlink: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+			if tt.autocrlf != "" {
+				runGit(t, dir, "config", "core.autocrlf", tt.autocrlf)
+			}
+			for name, content := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				mode := os.FileMode(0644)
+				if tt.exec {
+					mode = 0755
+				}
+				if err := os.WriteFile(path, []byte(content), mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.symlink {
+				if err := os.Symlink("target", filepath.Join(dir, "link")); err != nil {
+					t.Skip("symlinks not supported:", err)
+				}
+			}
+
+			runGot(t, dir, "add", ".")
+			got := runGit(t, dir, "ls-files", "-s")
+			runGit(t, dir, "fsck", "--no-progress", "--no-dangling")
+			runGit(t, dir, "read-tree", "--empty")
+			runGit(t, dir, "add", ".")
+			if want := runGit(t, dir, "ls-files", "-s"); got != want {
+				t.Errorf("index after got add:\n%s\ngit add gives:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestAddDryRun(t *testing.T) {
+	dir := newTestRepo(t)
+	for name, content := range map[string]string{"a": "a\n", "d/b": "b\n"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := runGot(t, dir, "add", "-n", ".")
+	want := "blob " + strings.TrimSpace(runGit(t, dir, "hash-object", "a")) + "\ta\n" +
+		"blob " + strings.TrimSpace(runGit(t, dir, "hash-object", "d/b")) + "\td/b\n"
+	if got != want {
+		t.Errorf("add -n:\n%s\nwant:\n%s", got, want)
+	}
+	if out := runGit(t, dir, "count-objects"); !strings.HasPrefix(out, "0 objects") {
+		t.Errorf("add -n stored objects: %s", out)
+	}
+	if out := runGit(t, dir, "ls-files"); out != "" {
+		t.Errorf("add -n staged files:\n%s", out)
+	}
+}
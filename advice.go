@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// advise prints a hint to stderr unless advice.<key> is set to false in
+// the config. Each line of the message is prefixed with "hint: " the way
+// git does it.
+func advise(key, format string, args ...any) {
+	if !adviceEnabled(key) {
+		return
+	}
+
+	message := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	for _, line := range strings.Split(message, "\n") {
+		if line == "" {
+			fmt.Fprintln(os.Stderr, "hint:")
+		} else {
+			fmt.Fprintf(os.Stderr, "hint: %s\n", line)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "hint: Disable this message by setting advice.%s to false\n", key)
+}
+
+// adviceEnabled reports whether advice.<key> leaves the hint on, for
+// commands that print more than the hint itself around it.
+func adviceEnabled(key string) bool {
+	repo, err := currentRepo()
+	return err != nil || repo.Config.Bool("advice", key, true)
+}
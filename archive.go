@@ -0,0 +1,274 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// archiveWriter is the part of tar and zip output that differs: adding one
+// directory, file or symlink.
+type archiveWriter interface {
+	writeDir(name string) error
+	writeFile(name string, mode string, content []byte) error
+	Close() error
+}
+
+func runArchive(args []string) {
+	usage := errors.New("usage: got archive [--format=<fmt>] [--prefix=<prefix>/] [-o <file>] <tree-ish>")
+	var format, prefix, output, rev string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "--prefix="):
+			prefix = strings.TrimPrefix(arg, "--prefix=")
+		case strings.HasPrefix(arg, "--output="):
+			output = prefixFilename(strings.TrimPrefix(arg, "--output="))
+		case arg == "-o" || arg == "--output":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			output = prefixFilename(args[i])
+		case rev == "" && !strings.HasPrefix(arg, "-"):
+			rev = arg
+		default:
+			handleError(usage)
+		}
+	}
+	if rev == "" {
+		handleError(usage)
+	}
+	// As in git, the output file's extension picks the format when none
+	// is given.
+	if format == "" {
+		switch {
+		case strings.HasSuffix(output, ".zip"):
+			format = "zip"
+		case strings.HasSuffix(output, ".tar.gz") || strings.HasSuffix(output, ".tgz"):
+			format = "tar.gz"
+		default:
+			format = "tar"
+		}
+	}
+
+	hash, err := resolveRevision(rev)
+	if err != nil {
+		handleError(err)
+	}
+	tree, err := peelToTree(hash)
+	if err != nil {
+		handleError(err)
+	}
+	// A commit's files are dated and labelled with the commit; a bare tree
+	// gets the current time.
+	mtime, commit := time.Now(), ""
+	if commitHash, err := peelToCommit(hash); err == nil {
+		c, err := readLogCommit(commitHash)
+		if err != nil {
+			handleError(err)
+		}
+		mtime, commit = c.When, commitHash
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			handleError(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var aw archiveWriter
+	switch format {
+	case "tar":
+		aw, err = newTarArchive(w, nil, mtime, commit)
+	case "tar.gz", "tgz":
+		gz := gzip.NewWriter(w)
+		aw, err = newTarArchive(gz, gz, mtime, commit)
+	case "zip":
+		aw, err = newZipArchive(w, mtime, commit)
+	default:
+		err = fmt.Errorf("unknown archive format '%s'", format)
+	}
+	if err != nil {
+		handleError(err)
+	}
+	if strings.HasSuffix(prefix, "/") {
+		if err := aw.writeDir(prefix); err != nil {
+			handleError(err)
+		}
+	}
+	if err := archiveTree(aw, tree, prefix); err != nil {
+		handleError(err)
+	}
+	if err := aw.Close(); err != nil {
+		handleError(err)
+	}
+}
+
+// archiveTree adds every entry below tree to aw, each directory before its
+// contents. Submodules appear as empty directories, as in git.
+func archiveTree(aw archiveWriter, tree, prefix string) error {
+	_, content, err := readObject(tree)
+	if err != nil {
+		return err
+	}
+	entries, err := object.ParseTree(content)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := prefix + entry.Name
+		switch {
+		case object.IsTreeMode(entry.Mode):
+			if err := aw.writeDir(name + "/"); err != nil {
+				return err
+			}
+			if err := archiveTree(aw, entry.Hash, name+"/"); err != nil {
+				return err
+			}
+		case entry.Mode == "160000":
+			if err := aw.writeDir(name + "/"); err != nil {
+				return err
+			}
+		default:
+			_, content, err := readObject(entry.Hash)
+			if err != nil {
+				return err
+			}
+			if entry.Mode != "120000" {
+				if content, err = convertToWorktree(content); err != nil {
+					return err
+				}
+			}
+			if err := aw.writeFile(name, entry.Mode, content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tarArchive writes entries owned by root with git's default tar.umask of
+// 002 applied. gz, when set, is closed after the tar stream.
+type tarArchive struct {
+	tw    *tar.Writer
+	gz    io.Closer
+	mtime time.Time
+}
+
+func newTarArchive(w io.Writer, gz io.Closer, mtime time.Time, commit string) (*tarArchive, error) {
+	a := &tarArchive{tw: tar.NewWriter(w), gz: gz, mtime: mtime}
+	// git records the commit in a global header that tar ignores and
+	// "git get-tar-commit-id" reads.
+	if commit != "" {
+		err := a.tw.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			Name:       "pax_global_header",
+			PAXRecords: map[string]string{"comment": commit},
+			Format:     tar.FormatPAX,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *tarArchive) header(name string) *tar.Header {
+	return &tar.Header{Name: name, ModTime: a.mtime, Uname: "root", Gname: "root"}
+}
+
+func (a *tarArchive) writeDir(name string) error {
+	h := a.header(name)
+	h.Typeflag, h.Mode = tar.TypeDir, 0775
+	return a.tw.WriteHeader(h)
+}
+
+func (a *tarArchive) writeFile(name, mode string, content []byte) error {
+	h := a.header(name)
+	switch mode {
+	case "120000":
+		h.Typeflag, h.Mode, h.Linkname = tar.TypeSymlink, 0777, string(content)
+		return a.tw.WriteHeader(h)
+	case "100755":
+		h.Mode = 0775
+	default:
+		h.Mode = 0664
+	}
+	h.Typeflag, h.Size = tar.TypeReg, int64(len(content))
+	if err := a.tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(content)
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+// zipArchive writes deflated entries with Unix modes, and the commit as
+// the archive comment.
+type zipArchive struct {
+	zw    *zip.Writer
+	mtime time.Time
+}
+
+func newZipArchive(w io.Writer, mtime time.Time, commit string) (*zipArchive, error) {
+	a := &zipArchive{zw: zip.NewWriter(w), mtime: mtime}
+	if commit != "" {
+		if err := a.zw.SetComment(commit); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *zipArchive) writeDir(name string) error {
+	h := &zip.FileHeader{Name: name, Method: zip.Store, Modified: a.mtime}
+	h.SetMode(os.ModeDir | 0755)
+	_, err := a.zw.CreateHeader(h)
+	return err
+}
+
+func (a *zipArchive) writeFile(name, mode string, content []byte) error {
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.mtime}
+	switch mode {
+	case "120000":
+		h.Method = zip.Store
+		h.SetMode(os.ModeSymlink | 0777)
+	case "100755":
+		h.SetMode(0755)
+	default:
+		h.SetMode(0644)
+	}
+	f, err := a.zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// catFileBatch reads one object name per line from r and writes
+// "<hash> <type> <size>" for each to w, followed by the raw content and a
+// newline when contents is set. Names that do not resolve are reported as
+// "<name> missing" and the batch carries on. Output is flushed after every
+// object so a caller can read each answer before sending the next name.
+// With --json and no contents, each record is a batchCheckEntry on a line
+// of its own.
+func catFileBatch(r io.Reader, w io.Writer, contents, allowUnknown bool) error {
+	out := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if err := catFileBatchOne(out, name, contents, allowUnknown); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// batchCheckEntry is the --json form of a --batch-check record. A name
+// that does not resolve has only Name and Missing or Ambiguous set.
+type batchCheckEntry struct {
+	Hash      string `json:"hash,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Size      *int64 `json:"size,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Missing   bool   `json:"missing,omitempty"`
+	Ambiguous bool   `json:"ambiguous,omitempty"`
+}
+
+// writeBatchCheck writes entry as one line of JSON, or as git's
+// "<hash> <type> <size>", "<name> missing" or "<name> ambiguous".
+func writeBatchCheck(out *bufio.Writer, entry batchCheckEntry, asJSON bool) error {
+	switch {
+	case asJSON:
+		return json.NewEncoder(out).Encode(entry)
+	case entry.Ambiguous:
+		_, err := fmt.Fprintf(out, "%s ambiguous\n", entry.Name)
+		return err
+	case entry.Missing:
+		_, err := fmt.Fprintf(out, "%s missing\n", entry.Name)
+		return err
+	}
+	_, err := fmt.Fprintf(out, "%s %s %d\n", entry.Hash, entry.Type, *entry.Size)
+	return err
+}
+
+// catFileBatchOne writes the batch record for one name.
+func catFileBatchOne(out *bufio.Writer, name string, contents, allowUnknown bool) error {
+	// Contents cannot go in JSON, so --batch ignores --json.
+	asJSON := jsonOutput && !contents
+	hash, err := resolveRevision(name)
+	if err != nil {
+		ambiguous := errors.Is(err, errAmbiguous)
+		return writeBatchCheck(out, batchCheckEntry{Name: name, Missing: !ambiguous, Ambiguous: ambiguous}, asJSON)
+	}
+	if !contents {
+		objectType, size, err := objectHeader(hash)
+		if err != nil || (!allowUnknown && !isKnownType(objectType)) {
+			return writeBatchCheck(out, batchCheckEntry{Name: name, Missing: true}, asJSON)
+		}
+		return writeBatchCheck(out, batchCheckEntry{Hash: hash, Type: objectType, Size: &size}, asJSON)
+	}
+
+	objectType, size, content, err := openObject(hash)
+	if err != nil || (!allowUnknown && !isKnownType(objectType)) {
+		if err == nil {
+			content.Close()
+		}
+		return writeBatchCheck(out, batchCheckEntry{Name: name, Missing: true}, false)
+	}
+	defer content.Close()
+	fmt.Fprintf(out, "%s %s %d\n", hash, objectType, size)
+	n, err := io.Copy(out, content)
+	if err == nil && n != size {
+		err = fmt.Errorf("object %s: truncated: expected %d bytes, got %d", hash, size, n)
+	}
+	if err != nil {
+		return err
+	}
+	return out.WriteByte('\n')
+}
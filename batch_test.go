@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCatFileBatchCheck(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "add a")
+	commitFile(t, dir, "empty", "", "add empty")
+	names := []string{"HEAD", "HEAD:a", "HEAD:empty", "HEAD^{tree}", "nope", "HEAD:nope"}
+	input := strings.Join(names, "\n") + "\n"
+
+	want, _, err := runCommandInput(dir, input, "git", "cat-file", "--batch-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, stderr, err := runCommandInput(dir, input, gotBinary, "cat-file", "--batch-check")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, stderr)
+	}
+	if got != want {
+		t.Errorf("--batch-check:\n%s\ngit gives:\n%s", got, want)
+	}
+
+	out, stderr, err := runCommandInput(dir, input, gotBinary, "--json", "cat-file", "--batch-check")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, stderr)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	wantLines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	if len(lines) != len(names) {
+		t.Fatalf("--json --batch-check gave %d lines for %d names:\n%s", len(lines), len(names), out)
+	}
+	for i, line := range lines {
+		var entry struct {
+			Hash    string `json:"hash"`
+			Type    string `json:"type"`
+			Size    *int64 `json:"size"`
+			Name    string `json:"name"`
+			Missing bool   `json:"missing"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %d: %v: %s", i+1, err, line)
+		}
+		var text string
+		if entry.Missing {
+			text = entry.Name + " missing"
+		} else if entry.Size != nil {
+			text = strings.Join([]string{entry.Hash, entry.Type, strconv.FormatInt(*entry.Size, 10)}, " ")
+		}
+		if text != wantLines[i] {
+			t.Errorf("%s: JSON %s, git gives %q", names[i], line, wantLines[i])
+		}
+	}
+}
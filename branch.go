@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func runBranch(args []string) {
+	switch {
+	case len(args) == 0:
+		listBranches()
+	case len(args) == 2 && (args[0] == "-d" || args[0] == "-D"):
+		deleteBranch(args[1])
+	case len(args) == 1 && !strings.HasPrefix(args[0], "-"):
+		createBranch(args[0], "")
+	case len(args) == 2 && !strings.HasPrefix(args[0], "-"):
+		createBranch(args[0], args[1])
+	default:
+		handleError(errors.New("usage: got branch [-d] [<name> [<start-point>]]"))
+	}
+}
+
+func listBranches() {
+	refs, err := listRefs()
+	if err != nil {
+		handleError(err)
+	}
+	current, onBranch, err := currentBranch()
+	if err != nil {
+		handleError(err)
+	}
+	if !onBranch {
+		head, err := resolveRef("HEAD")
+		if err != nil {
+			handleError(err)
+		}
+		fmt.Printf("* (HEAD detached at %s)\n", head[:7])
+	}
+
+	for _, ref := range refs {
+		name, found := strings.CutPrefix(ref, "refs/heads/")
+		if !found {
+			continue
+		}
+		if onBranch && name == current {
+			fmt.Printf("* %s\n", name)
+		} else {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
+
+// createBranch creates refs/heads/name at startPoint, or at HEAD if
+// startPoint is empty.
+func createBranch(name, startPoint string) {
+	if err := checkRefName(name); err != nil {
+		handleError(err)
+	}
+	ref := "refs/heads/" + name
+	if exists, err := refExists(ref); err != nil {
+		handleError(err)
+	} else if exists {
+		handleError(fmt.Errorf("a branch named '%s' already exists", name))
+	}
+
+	var start string
+	var err error
+	if startPoint == "" {
+		start, err = resolveRef("HEAD")
+		if errors.Is(err, errRefNotFound) {
+			handleError(errors.New("HEAD does not point at a commit yet"))
+		}
+	} else {
+		start, err = resolveRevision(startPoint)
+		if err != nil {
+			handleError(fmt.Errorf("not a valid object name: '%s'", startPoint))
+		}
+		start, err = peelToCommit(start)
+	}
+	if err != nil {
+		handleError(err)
+	}
+
+	if err := writeRef(ref, start); err != nil {
+		handleError(err)
+	}
+	if startPoint == "" {
+		startPoint = "HEAD"
+	}
+	if err := appendReflog(ref, "", start, "branch: Created from "+startPoint); err != nil {
+		handleError(err)
+	}
+}
+
+func deleteBranch(name string) {
+	current, onBranch, err := currentBranch()
+	if err != nil {
+		handleError(err)
+	}
+	if onBranch && current == name {
+		handleError(fmt.Errorf("cannot delete branch '%s' checked out", name))
+	}
+
+	ref := "refs/heads/" + name
+	hash, err := resolveRef(ref)
+	if errors.Is(err, errRefNotFound) {
+		handleError(fmt.Errorf("branch '%s' not found", name))
+	}
+	if err != nil {
+		handleError(err)
+	}
+	if err := deleteRef(ref); err != nil {
+		handleError(err)
+	}
+	fmt.Printf("Deleted branch %s (was %s).\n", name, hash[:7])
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const bundleSignature = "# v2 git bundle\n"
+
+// bundle is a git bundle file: the refs it carries, the commits the
+// receiving repository must already have, and a pack of everything else.
+type bundle struct {
+	path          string
+	prerequisites []string
+	refs          []advertisedRef
+	pack          []byte
+}
+
+func runBundle(args []string) {
+	usage := errors.New("usage: got bundle create <file> <git-rev-list args>\n   or: got bundle verify <file>\n   or: got bundle list-heads <file>\n   or: got bundle unbundle <file>")
+	if len(args) < 2 {
+		handleError(usage)
+	}
+	sub, file := args[0], prefixFilename(args[1])
+
+	if sub == "create" {
+		if len(args) < 3 {
+			handleError(usage)
+		}
+		if err := createBundle(file, args[2:]); err != nil {
+			handleError(err)
+		}
+		return
+	}
+	if len(args) != 2 {
+		handleError(usage)
+	}
+	b, err := readBundle(file)
+	if err != nil {
+		handleError(err)
+	}
+	switch sub {
+	case "verify":
+		if err := b.verify(); err != nil {
+			handleError(err)
+		}
+		fmt.Fprintf(os.Stderr, "%s is okay\n", file)
+	case "list-heads":
+		for _, ref := range b.refs {
+			fmt.Printf("%s %s\n", ref.Hash, ref.Name)
+		}
+	case "unbundle":
+		// Like git, unbundle only stores the objects and prints the refs;
+		// updating refs from them is up to the caller.
+		pack, err := b.completePack()
+		if err != nil {
+			handleError(err)
+		}
+		if _, err := storePack(pack); err != nil {
+			handleError(err)
+		}
+		for _, ref := range b.refs {
+			fmt.Printf("%s %s\n", ref.Hash, ref.Name)
+		}
+	default:
+		handleError(usage)
+	}
+}
+
+// createBundle writes a bundle of the refs named in revs with every object
+// they reach. "^<rev>" and "<a>..<b>" leave out what a revision reaches;
+// the commits at that boundary become the bundle's prerequisites. --all
+// bundles every ref and HEAD.
+func createBundle(file string, revs []string) error {
+	var include, exclude []string
+	for _, rev := range revs {
+		switch from, to, isRange := strings.Cut(rev, ".."); {
+		case rev == "--all":
+			refs, err := listRefs()
+			if err != nil {
+				return err
+			}
+			include = append(include, append(refs, "HEAD")...)
+		case isRange:
+			if from == "" {
+				from = "HEAD"
+			}
+			if to == "" {
+				to = "HEAD"
+			}
+			exclude = append(exclude, from)
+			include = append(include, to)
+		case strings.HasPrefix(rev, "^"):
+			exclude = append(exclude, rev[1:])
+		case strings.HasPrefix(rev, "-"):
+			return fmt.Errorf("unsupported option '%s'", rev)
+		default:
+			include = append(include, rev)
+		}
+	}
+
+	b := &bundle{}
+	var roots []string
+	seen := make(map[string]bool)
+	for _, name := range include {
+		ref, err := bundleRefName(name)
+		if err != nil {
+			return err
+		}
+		hash, err := resolveRef(ref)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, hash)
+		if !seen[ref] {
+			seen[ref] = true
+			b.refs = append(b.refs, advertisedRef{Name: ref, Hash: hash})
+		}
+	}
+	var stopRoots []string
+	for _, name := range exclude {
+		hash, err := resolveRevision(name)
+		if err != nil {
+			return err
+		}
+		if hash, err = peelToCommit(hash); err != nil {
+			return err
+		}
+		stopRoots = append(stopRoots, hash)
+	}
+
+	known, err := objectClosure(stopRoots, nil)
+	if err != nil {
+		return err
+	}
+	stop := make(map[string]bool, len(known))
+	for _, hash := range known {
+		stop[hash] = true
+	}
+	objects, err := objectClosure(roots, stop)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return errors.New("refusing to create empty bundle")
+	}
+
+	// The prerequisites are the left-out commits that bundled commits
+	// have as parents.
+	isPrerequisite := make(map[string]bool)
+	for _, hash := range objects {
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			return err
+		}
+		if objectType != "commit" {
+			continue
+		}
+		references, err := objectReferences(hash, objectType, content)
+		if err != nil {
+			return err
+		}
+		for _, parent := range references["commit"] {
+			if stop[parent] && !isPrerequisite[parent] {
+				isPrerequisite[parent] = true
+				b.prerequisites = append(b.prerequisites, parent)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(bundleSignature)
+	for _, hash := range b.prerequisites {
+		c, err := readLogCommit(hash)
+		if err != nil {
+			return err
+		}
+		subject, _ := splitMessage(c.Commit.Message)
+		fmt.Fprintf(&buf, "-%s %s\n", hash, subject)
+	}
+	for _, ref := range b.refs {
+		fmt.Fprintf(&buf, "%s %s\n", ref.Hash, ref.Name)
+	}
+	buf.WriteString("\n")
+	opts, err := configPackOptions()
+	if err != nil {
+		return err
+	}
+	if _, _, err := writePackData(&buf, objects, opts); err != nil {
+		return err
+	}
+	tmp := file + ".lock"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// bundleRefName returns the full name of the ref a bundle argument names,
+// trying the same prefixes as revisions do.
+func bundleRefName(name string) (string, error) {
+	if name == "HEAD" {
+		return name, nil
+	}
+	for _, ref := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if exists, err := refExists(ref); err != nil || exists {
+			return ref, err
+		}
+	}
+	return "", fmt.Errorf("'%s' does not name a ref", name)
+}
+
+// isBundle reports whether path is a file that starts like a bundle.
+func isBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(bundleSignature))
+	_, err = io.ReadFull(f, header)
+	return err == nil && (string(header) == bundleSignature || string(header) == "# v3 git bundle\n")
+}
+
+// readBundle parses a version 2 or 3 bundle. Version 3 capabilities other
+// than the sha1 object format, such as filters, are rejected.
+func readBundle(path string) (*bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(bytes.NewReader(data))
+	signature, err := r.ReadString('\n')
+	if err != nil || (signature != bundleSignature && signature != "# v3 git bundle\n") {
+		return nil, fmt.Errorf("'%s' does not look like a v2 or v3 bundle file", path)
+	}
+
+	b := &bundle{path: path}
+	offset := len(signature)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%s: truncated bundle header", path)
+		}
+		offset += len(line)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "@"):
+			if line != "@object-format=sha1" {
+				return nil, fmt.Errorf("%s: unsupported bundle capability '%s'", path, line[1:])
+			}
+		case strings.HasPrefix(line, "-"):
+			hash, _, _ := strings.Cut(line[1:], " ")
+			if !isFullHash(hash) {
+				return nil, fmt.Errorf("%s: bad prerequisite line %q", path, line)
+			}
+			b.prerequisites = append(b.prerequisites, hash)
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if !ok || !isFullHash(hash) {
+				return nil, fmt.Errorf("%s: bad ref line %q", path, line)
+			}
+			b.refs = append(b.refs, advertisedRef{Name: name, Hash: hash})
+		}
+	}
+	b.pack = data[offset:]
+	return b, nil
+}
+
+// verify checks that the repository has every prerequisite commit.
+func (b *bundle) verify() error {
+	repo, err := currentRepo()
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, hash := range b.prerequisites {
+		if !repo.ObjectExists(hash) {
+			missing = append(missing, hash)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("repository lacks these prerequisite commits:\n\t%s", strings.Join(missing, "\n\t"))
+	}
+	return nil
+}
+
+// completePack returns the bundle's pack, with the bases of any deltas
+// against prerequisites added, ready for storePack.
+func (b *bundle) completePack() ([]byte, error) {
+	if err := b.verify(); err != nil {
+		return nil, err
+	}
+	if len(b.prerequisites) == 0 {
+		return b.pack, nil
+	}
+	return fixThinPack(b.pack)
+}
+
+// bundleTransport lets clone and fetch read from a bundle file as if it
+// were a server that offers its refs and always sends its whole pack.
+type bundleTransport struct {
+	b *bundle
+}
+
+func (t *bundleTransport) discoverRefs(service string, version int) (*refAdvertisement, error) {
+	if service != "git-upload-pack" {
+		return nil, fmt.Errorf("%s: cannot push to a bundle", t.b.path)
+	}
+	return &refAdvertisement{Refs: t.b.refs}, nil
+}
+
+func (t *bundleTransport) call(service string, body []byte) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("%s: a bundle has no %s to call", t.b.path, service)
+}
+
+func (t *bundleTransport) close() error { return nil }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func runCheckout(args []string) {
+	var newBranch, target string
+	force := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-f" || args[i] == "--force":
+			force = true
+		case args[i] == "-b":
+			if i+1 >= len(args) {
+				handleError(errors.New("option -b requires a value"))
+			}
+			i++
+			newBranch = args[i]
+		case target == "" && !strings.HasPrefix(args[i], "-"):
+			target = args[i]
+		default:
+			handleError(errors.New("usage: got checkout [-f] [-b <new-branch>] [<branch>]"))
+		}
+	}
+
+	current, onBranch, err := currentBranch()
+	if err != nil {
+		handleError(err)
+	}
+	previous, headErr := resolveRef("HEAD")
+	from := current
+	if !onBranch {
+		from = previous
+	}
+
+	if newBranch != "" {
+		if target != "" {
+			handleError(errors.New("usage: got checkout -b <new-branch>"))
+		}
+		// The new branch starts at HEAD, so the working tree and index
+		// stay as they are.
+		createBranch(newBranch, "")
+		if err := writeSymbolicRef("HEAD", "refs/heads/"+newBranch); err != nil {
+			handleError(err)
+		}
+		if err := appendReflog("HEAD", previous, previous, fmt.Sprintf("checkout: moving from %s to %s", from, newBranch)); err != nil {
+			handleError(err)
+		}
+		fmt.Fprintf(os.Stderr, "Switched to a new branch '%s'\n", newBranch)
+		return
+	}
+	if target == "" {
+		handleError(errors.New("usage: got checkout [-f] [-b <new-branch>] [<branch>]"))
+	}
+
+	ref := "refs/heads/" + target
+	hash, err := resolveRef(ref)
+	if errors.Is(err, errRefNotFound) {
+		// Anything that is not a branch detaches HEAD at the commit.
+		hash, err = resolveRevision(target)
+		if err != nil {
+			handleError(fmt.Errorf("pathspec '%s' did not match any branch or commit known to got", target))
+		}
+		if hash, err = peelToCommit(hash); err != nil {
+			handleError(err)
+		}
+		if err := switchTree(hash, force); err != nil {
+			handleError(err)
+		}
+		if err := writeRef("HEAD", hash); err != nil {
+			handleError(err)
+		}
+		if err := appendReflog("HEAD", previous, hash, fmt.Sprintf("checkout: moving from %s to %s", from, target)); err != nil {
+			handleError(err)
+		}
+		if onBranch && adviceEnabled("detachedHead") {
+			fmt.Fprintf(os.Stderr, "Note: switching to '%s'.\n\n", target)
+			advise("detachedHead", "You are in 'detached HEAD' state. You can look around, make experimental\n"+
+				"changes and commit them, and you can discard any commits you make in this\n"+
+				"state without impacting any branches by switching back to a branch.\n\n"+
+				"If you want to create a new branch to retain commits you create, you may\n"+
+				"do so (now or later) with:\n\n"+
+				"  got checkout -b <new-branch-name>")
+		}
+		fmt.Fprintf(os.Stderr, "HEAD is now at %s\n", describeCommit(hash))
+		return
+	}
+	if err != nil {
+		handleError(err)
+	}
+
+	if onBranch && current == target {
+		if force {
+			if err := switchTree(hash, true); err != nil {
+				handleError(err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Already on '%s'\n", target)
+		return
+	}
+
+	if err := switchTree(hash, force); err != nil {
+		handleError(err)
+	}
+	if err := writeSymbolicRef("HEAD", ref); err != nil {
+		handleError(err)
+	}
+	if err := appendReflog("HEAD", previous, hash, fmt.Sprintf("checkout: moving from %s to %s", from, target)); err != nil {
+		handleError(err)
+	}
+	if !onBranch && headErr == nil && previous != hash {
+		fmt.Fprintf(os.Stderr, "Previous HEAD position was %s\n", describeCommit(previous))
+	}
+	fmt.Fprintf(os.Stderr, "Switched to branch '%s'\n", target)
+}
+
+// peelToCommit returns the commit hash names, following annotated tags.
+func peelToCommit(hash string) (string, error) {
+	return peelToType(hash, "commit")
+}
+
+// describeCommit formats a commit as its abbreviated hash and subject.
+func describeCommit(hash string) string {
+	c, err := readLogCommit(hash)
+	if err != nil {
+		return hash[:7]
+	}
+	subject, _ := splitMessage(c.Commit.Message)
+	return hash[:7] + " " + subject
+}
+
+// flattenTree returns every non-tree entry below hash keyed by path.
+func flattenTree(hash string) (map[string]TreeEntry, error) {
+	files := make(map[string]TreeEntry)
+	if hash == "" {
+		return files, nil
+	}
+	err := walkTree(hash, "", func(path string, entry TreeEntry) error {
+		files[path] = entry
+		return nil
+	})
+	return files, err
+}
+
+// switchTree moves the working tree and index from HEAD's tree to the tree
+// of commit. Only paths that differ between the two trees are touched, so
+// unrelated local changes are carried over. Nothing is changed if a local
+// change would be lost. With force, every path is reset to commit's tree
+// instead and local changes to tracked files are discarded.
+func switchTree(commit string, force bool) error {
+	var oldTree string
+	head, err := resolveRef("HEAD")
+	if err == nil {
+		if oldTree, err = peelToTree(head); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, errRefNotFound) {
+		return err
+	}
+	newTree, err := peelToTree(commit)
+	if err != nil {
+		return err
+	}
+
+	oldFiles, err := flattenTree(oldTree)
+	if err != nil {
+		return err
+	}
+	newFiles, err := flattenTree(newTree)
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for path, entry := range oldFiles {
+		if newEntry, ok := newFiles[path]; !ok || newEntry != entry {
+			changed = append(changed, path)
+		}
+	}
+	for path := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	if force {
+		changed = changed[:0]
+		seen := make(map[string]bool)
+		for path := range oldFiles {
+			seen[path] = true
+		}
+		for path := range newFiles {
+			seen[path] = true
+		}
+		for _, entry := range idx.Entries {
+			seen[entry.Path] = true
+		}
+		for path := range seen {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	if !force {
+		// A path that is about to change must be clean in both the index and
+		// the working tree, and a new path must not clobber an untracked file.
+		var dirty, untracked []string
+		for _, path := range changed {
+			oldEntry, tracked := oldFiles[path]
+			entry := idx.entry(path)
+			if !tracked {
+				if entry != nil {
+					dirty = append(dirty, path)
+				} else {
+					found, err := untrackedAt(path, oldFiles)
+					if err != nil {
+						return err
+					}
+					untracked = append(untracked, found...)
+				}
+				continue
+			}
+			if entry == nil || entry.Hash != oldEntry.Hash || entry.ModeString() != oldEntry.Mode {
+				dirty = append(dirty, path)
+				continue
+			}
+			modified, err := worktreeModified(entry)
+			if err != nil {
+				return err
+			}
+			if modified {
+				dirty = append(dirty, path)
+			}
+		}
+		if len(dirty) > 0 {
+			return fmt.Errorf("your local changes to the following files would be overwritten by checkout:\n\t%s\nPlease commit your changes or stash them before you switch branches.\nAborting", strings.Join(dirty, "\n\t"))
+		}
+		if len(untracked) > 0 {
+			return fmt.Errorf("the following untracked working tree files would be overwritten by checkout:\n\t%s\nPlease move or remove them before you switch branches.\nAborting", strings.Join(untracked, "\n\t"))
+		}
+	}
+
+	// A partial clone fetches the blobs it is about to write in one go
+	// rather than one at a time.
+	if promisor, _, err := promisorRemote(); err != nil {
+		return err
+	} else if promisor != "" {
+		var missing []string
+		for _, path := range changed {
+			if entry, ok := newFiles[path]; ok && entry.Mode != "160000" && !objectStored(entry.Hash) {
+				missing = append(missing, entry.Hash)
+			}
+		}
+		if _, err := fetchPromised(missing); err != nil {
+			return err
+		}
+	}
+
+	// Deletions go first so that a directory replaced by a file, or the
+	// other way round, is out of the way before the new entry is written.
+	for _, path := range changed {
+		if _, ok := newFiles[path]; !ok {
+			idx.remove(path)
+			// A file only added to the index is unstaged but kept.
+			if _, tracked := oldFiles[path]; !tracked {
+				continue
+			}
+			if err := removeWorktreeFile(path); err != nil {
+				return err
+			}
+		}
+	}
+	for _, path := range changed {
+		entry, ok := newFiles[path]
+		if !ok {
+			continue
+		}
+		if err := checkoutEntry(path, entry); err != nil {
+			return err
+		}
+		info, err := os.Lstat(filepath.FromSlash(path))
+		if err != nil {
+			return err
+		}
+		indexEntry, err := newIndexEntry(path, entry.Mode, entry.Hash, info)
+		if err != nil {
+			return err
+		}
+		idx.add(indexEntry)
+	}
+	return idx.write()
+}
+
+// untrackedAt returns the untracked files that writing path would clobber:
+// a file already there, or the files in a directory there that files
+// lists. The tracked ones are removed before path is written.
+func untrackedAt(path string, files map[string]TreeEntry) ([]string, error) {
+	info, err := os.Lstat(filepath.FromSlash(path))
+	if err != nil {
+		return nil, nil
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var found []string
+	err = filepath.WalkDir(filepath.FromSlash(path), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, ok := files[filepath.ToSlash(p)]; !ok {
+			found = append(found, filepath.ToSlash(p))
+		}
+		return nil
+	})
+	return found, err
+}
+
+// removeWorktreeFile deletes a file and any parent directories it leaves
+// empty.
+func removeWorktreeFile(path string) error {
+	fullPath := filepath.FromSlash(path)
+	if err := os.RemoveAll(fullPath); err != nil {
+		return err
+	}
+	for dir := filepath.Dir(fullPath); dir != "."; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
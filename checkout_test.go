@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newCheckoutRepo makes a repository on main with a branch, feature, that
+// adds, changes and removes files, turns a file into a directory and makes
+// a script executable, and a tag, v1, on main's first commit.
+func newCheckoutRepo(t *testing.T) string {
+	t.Helper()
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "add a")
+	runGit(t, dir, "tag", "v1")
+	commitFile(t, dir, "gone", "gone\n", "add gone")
+	commitFile(t, dir, "x", "x is a file\n", "add x")
+	commitFile(t, dir, "run.sh", "echo hi\n", "add run.sh")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "a", "a on feature\n", "change a")
+	commitFile(t, dir, "dir/sub/new", "new\n", "add dir/sub/new")
+	runGit(t, dir, "rm", "-q", "gone", "x")
+	commitFile(t, dir, "x/y", "x is a directory\n", "make x a directory")
+	if err := os.Chmod(filepath.Join(dir, "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", "run.sh")
+	runGit(t, dir, "commit", "-q", "-m", "make run.sh executable")
+	runGit(t, dir, "checkout", "-q", "main")
+	return dir
+}
+
+func TestCheckoutCleanStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		target  string
+		missing []string
+	}{
+		{name: "to branch", target: "feature", missing: []string{"gone"}},
+		{name: "back to branch", from: "feature", target: "main", missing: []string{"dir"}},
+		{name: "to commit", target: "feature~2"},
+		{name: "to tag", target: "v1", missing: []string{"gone", "x", "run.sh"}},
+		{name: "from tag", from: "v1", target: "feature", missing: []string{"gone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newCheckoutRepo(t)
+			if tt.from != "" {
+				runGit(t, dir, "checkout", "-q", tt.from)
+			}
+			runGot(t, dir, "checkout", tt.target)
+
+			want := runGit(t, dir, "rev-parse", tt.target+"^{commit}")
+			if got := runGit(t, dir, "rev-parse", "HEAD"); got != want {
+				t.Errorf("HEAD = %s, want %s", strings.TrimSpace(got), strings.TrimSpace(want))
+			}
+			if got := runGit(t, dir, "status", "--porcelain", "--untracked-files=all"); got != "" {
+				t.Errorf("git status after checkout:\n%s", got)
+			}
+			if got := runGot(t, dir, "status", "--short"); got != "" {
+				t.Errorf("got status after checkout:\n%s", got)
+			}
+			for _, name := range tt.missing {
+				if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Errorf("%s left in the work tree: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckoutDetachedHeadAdvice(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		target   string
+		disabled bool
+		want     bool
+	}{
+		{name: "commit", target: "HEAD~1", want: true},
+		{name: "full hash", target: "<hash>", want: true},
+		{name: "tag", target: "v1", want: true},
+		{name: "disabled", target: "HEAD~1", disabled: true, want: false},
+		{name: "already detached", from: "HEAD~1", target: "v1", want: false},
+		{name: "branch", target: "feature", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newCheckoutRepo(t)
+			if tt.from != "" {
+				runGit(t, dir, "checkout", "-q", tt.from)
+			}
+			if tt.disabled {
+				runGit(t, dir, "config", "advice.detachedHead", "false")
+			}
+			target := tt.target
+			if target == "<hash>" {
+				target = strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD~2"))
+			}
+
+			_, stderr, err := runCommandStderr(dir, gotBinary, "checkout", target)
+			if err != nil {
+				t.Fatalf("checkout %s: %v\n%s", target, err, stderr)
+			}
+			note := "Note: switching to '" + target + "'."
+			if got := strings.Contains(stderr, note); got != tt.want {
+				t.Errorf("note shown = %v, want %v:\n%s", got, tt.want, stderr)
+			}
+			if got := strings.Contains(stderr, "hint: You are in 'detached HEAD' state."); got != tt.want {
+				t.Errorf("advice shown = %v, want %v:\n%s", got, tt.want, stderr)
+			}
+			if got := strings.Contains(stderr, "hint: Disable this message by setting advice.detachedHead to false"); got != tt.want {
+				t.Errorf("how to disable shown = %v, want %v:\n%s", got, tt.want, stderr)
+			}
+			if tt.target != "feature" && !strings.Contains(stderr, "HEAD is now at ") {
+				t.Errorf("no \"HEAD is now at\" line:\n%s", stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cloneOptions are the choices runClone passes on to cloneInto.
+type cloneOptions struct {
+	branch string
+	// depth, when above zero, makes a shallow clone.
+	depth int
+	// filter, when set, makes a partial clone.
+	filter string
+	// bare clones have no work tree; mirror ones are bare and also copy
+	// every ref the remote has, keeping them in step on fetch.
+	bare, mirror      bool
+	noCheckout, quiet bool
+}
+
+func runClone(args []string) {
+	usage := errors.New("usage: got clone [-q] [-n] [--bare | --mirror] [-b <branch>] [--depth <depth>] [--filter=<filter-spec>] <url> [<directory>]")
+	var opts cloneOptions
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-q" || arg == "--quiet":
+			opts.quiet = true
+		case arg == "-n" || arg == "--no-checkout":
+			opts.noCheckout = true
+		case arg == "--bare":
+			opts.bare = true
+		case arg == "--mirror":
+			opts.bare, opts.mirror = true, true
+		case arg == "-b" || arg == "--branch":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			opts.branch = args[i]
+		case arg == "--depth" || strings.HasPrefix(arg, "--depth="):
+			value, ok := strings.CutPrefix(arg, "--depth=")
+			if !ok {
+				if i+1 >= len(args) {
+					handleError(fmt.Errorf("option %s requires a value", arg))
+				}
+				i++
+				value = args[i]
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				handleError(fmt.Errorf("depth %s is not a positive number", value))
+			}
+			opts.depth = n
+		case strings.HasPrefix(arg, "--filter="):
+			spec, err := parseFilter(strings.TrimPrefix(arg, "--filter="))
+			if err != nil {
+				handleError(err)
+			}
+			opts.filter = spec
+		case strings.HasPrefix(arg, "-"):
+			handleError(usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) == 0 || len(positional) > 2 {
+		handleError(usage)
+	}
+
+	url := strings.TrimSuffix(positional[0], "/")
+	remote, err := openRemote(url)
+	if err != nil {
+		handleError(err)
+	}
+	dir := cloneDirName(url)
+	if opts.bare {
+		dir += ".git"
+	}
+	if len(positional) == 2 {
+		dir = positional[1]
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		handleError(fmt.Errorf("destination path '%s' already exists and is not an empty directory", dir))
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		handleError(err)
+	}
+	_, statErr := os.Stat(absDir)
+	created := errors.Is(statErr, os.ErrNotExist)
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		handleError(err)
+	}
+	if !opts.quiet {
+		if opts.bare {
+			fmt.Fprintf(os.Stderr, "Cloning into bare repository '%s'...\n", dir)
+		} else {
+			fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", dir)
+		}
+	}
+
+	err = os.Chdir(absDir)
+	if err == nil {
+		err = cloneInto(remote, url, opts)
+	}
+	if err != nil {
+		// Leave nothing half-cloned behind.
+		os.Chdir(filepath.Dir(absDir))
+		switch {
+		case created:
+			os.RemoveAll(absDir)
+		case opts.bare:
+			entries, _ := os.ReadDir(absDir)
+			for _, entry := range entries {
+				os.RemoveAll(filepath.Join(absDir, entry.Name()))
+			}
+		default:
+			os.RemoveAll(filepath.Join(absDir, ".git"))
+		}
+		handleError(err)
+	}
+}
+
+// cloneDirName is the directory "got clone" creates for url when none is
+// given: the last path component without ".git".
+func cloneDirName(url string) string {
+	name := path.Base(url)
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		// An scp-like host:repo.git has no slash before the path.
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".git"), ".bundle")
+	if name == "" || name == "/" || name == "." {
+		return "repository"
+	}
+	return name
+}
+
+// cloneInto creates a repository in the current directory, fetches every
+// branch and tag of url into it and checks out the remote's default branch,
+// or opts.branch if it is set. A depth above zero makes a shallow clone of
+// only the branch to check out, with that many commits of its history, and
+// the tags that point into them. A filter makes a partial clone, which
+// leaves out the blobs it matches and fetches them from origin when needed.
+// A bare clone is made in the current directory itself, with the remote's
+// branches as its own and nothing checked out; a mirror takes every ref.
+func cloneInto(remote Transport, url string, opts cloneOptions) error {
+	if opts.bare {
+		gitDir, commonDir, bareRepo = ".", ".", true
+	}
+	if err := createGitDir(gitDir, opts.bare); err != nil {
+		return err
+	}
+
+	adv, err := remote.discoverRefs("git-upload-pack", protocolVersion())
+	if err != nil {
+		return err
+	}
+	defer remote.close()
+	prefixes := []string{"HEAD", "refs/heads/", "refs/tags/"}
+	if opts.mirror {
+		prefixes = nil
+	}
+	if adv.Refs, err = listRemoteRefs(remote, adv, prefixes); err != nil {
+		return err
+	}
+
+	// fetchSpec maps the remote's refs to ours; a bare clone that is not a
+	// mirror uses it without recording it, as git does.
+	tracking := "refs/remotes/origin/"
+	if opts.bare {
+		tracking = "refs/heads/"
+	}
+	fetchSpec := "+refs/heads/*:" + tracking + "*"
+	switch {
+	case opts.mirror:
+		fetchSpec = "+refs/*:refs/*"
+	case opts.depth > 0:
+		single := opts.branch
+		if single == "" {
+			single = defaultBranch(adv)
+		}
+		if _, ok := adv.lookup("refs/heads/" + single); !ok && opts.branch != "" {
+			return fmt.Errorf("remote branch %s not found in upstream origin", opts.branch)
+		}
+		if single != "" {
+			fetchSpec = "+refs/heads/" + single + ":" + tracking + single
+		}
+	}
+	config := [][2]string{{"remote.origin.url", url}}
+	if !opts.bare || opts.mirror {
+		config = append(config, [2]string{"remote.origin.fetch", fetchSpec})
+	}
+	if opts.mirror {
+		config = append(config, [2]string{"remote.origin.mirror", "true"})
+	}
+	for _, kv := range config {
+		if err := setConfigValue(commonPath("config"), kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	if opts.filter != "" {
+		if err := registerPromisor("origin", opts.filter); err != nil {
+			return err
+		}
+	}
+
+	var wants []string
+	seen := make(map[string]bool)
+	for _, ref := range adv.Refs {
+		_, fetched := mapRefspec(fetchSpec, ref.Name)
+		if !fetched && (opts.depth > 0 || !strings.HasPrefix(ref.Name, "refs/tags/")) {
+			continue
+		}
+		if !seen[ref.Hash] {
+			seen[ref.Hash] = true
+			wants = append(wants, ref.Hash)
+		}
+	}
+	if len(wants) == 0 {
+		if target := adv.headTarget(); target != "" {
+			if err := writeSymbolicRef("HEAD", target); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(os.Stderr, "warning: You appear to have cloned an empty repository.")
+		return nil
+	}
+
+	pack, shallow, err := fetchPack(remote, adv, wants, nil, deepen{depth: opts.depth}, opts.filter, progressWriter(opts.quiet))
+	if err != nil {
+		return err
+	}
+	packPath, err := storePack(pack)
+	if err != nil {
+		return err
+	}
+	if opts.filter != "" {
+		if err := markPromisorPack(packPath); err != nil {
+			return err
+		}
+	}
+	if err := shallow.apply(); err != nil {
+		return err
+	}
+
+	for _, ref := range adv.Refs {
+		local, ok := mapRefspec(fetchSpec, ref.Name)
+		if !ok && strings.HasPrefix(ref.Name, "refs/tags/") {
+			local, ok = ref.Name, true
+		}
+		// Tags outside a shallow clone's history were not sent.
+		if !ok || !objectStored(ref.Hash) {
+			continue
+		}
+		if err := writeRef(local, ref.Hash); err != nil {
+			return err
+		}
+		if err := appendReflog(local, "", ref.Hash, "clone: from "+url); err != nil {
+			return err
+		}
+	}
+
+	branch := opts.branch
+	if branch == "" {
+		branch = defaultBranch(adv)
+		if branch == "" {
+			fmt.Fprintln(os.Stderr, "warning: remote HEAD refers to nonexistent ref, unable to checkout")
+			return nil
+		}
+		if !opts.bare {
+			if err := writeSymbolicRef("refs/remotes/origin/HEAD", "refs/remotes/origin/"+branch); err != nil {
+				return err
+			}
+		}
+	}
+	head, ok := adv.lookup("refs/heads/" + branch)
+	if !ok {
+		return fmt.Errorf("remote branch %s not found in upstream origin", branch)
+	}
+	if opts.bare {
+		// The branch itself came with the remote's; HEAD only names it.
+		return writeSymbolicRef("HEAD", "refs/heads/"+branch)
+	}
+
+	for _, kv := range [][2]string{{"branch." + branch + ".remote", "origin"}, {"branch." + branch + ".merge", "refs/heads/" + branch}} {
+		if err := setConfigValue(commonPath("config"), kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	// HEAD names the branch before it exists, so that switchTree starts
+	// from an empty tree.
+	if err := writeSymbolicRef("HEAD", "refs/heads/"+branch); err != nil {
+		return err
+	}
+	if !opts.noCheckout {
+		if err := switchTree(head.Hash, false); err != nil {
+			return err
+		}
+	}
+	if err := writeRef("refs/heads/"+branch, head.Hash); err != nil {
+		return err
+	}
+	for _, ref := range []string{"refs/heads/" + branch, "HEAD"} {
+		if err := appendReflog(ref, "", head.Hash, "clone: from "+url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultBranch returns the short name of the branch the remote's HEAD
+// points at. Servers that do not say are matched by hash, preferring main
+// and master.
+func defaultBranch(adv *refAdvertisement) string {
+	if target, ok := strings.CutPrefix(adv.headTarget(), "refs/heads/"); ok {
+		return target
+	}
+	head, ok := adv.lookup("HEAD")
+	if !ok {
+		return ""
+	}
+	var match string
+	for _, ref := range adv.Refs {
+		name, isBranch := strings.CutPrefix(ref.Name, "refs/heads/")
+		if !isBranch || ref.Hash != head.Hash {
+			continue
+		}
+		if name == "main" || name == "master" {
+			return name
+		}
+		if match == "" {
+			match = name
+		}
+	}
+	return match
+}
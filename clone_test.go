@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCloneBareAndMirror(t *testing.T) {
+	url := newTestRemote(t)
+	remoteDir := strings.TrimPrefix(url, "ssh://localhost")
+	src := newTestRepo(t)
+	commitFile(t, src, "a", "a\n", "add a")
+	runGit(t, src, "tag", "-a", "-m", "v1", "v1")
+	runGit(t, src, "branch", "topic")
+	commitFile(t, src, "b", "b\n", "add b")
+	runGit(t, src, "push", "-q", url, "main", "topic", "v1", "HEAD~1:refs/changes/1")
+	refs := runGit(t, remoteDir, "for-each-ref")
+
+	for _, option := range []string{"--bare", "--mirror"} {
+		t.Run(option, func(t *testing.T) {
+			parent := t.TempDir()
+			runGot(t, parent, "clone", "-q", option, url, "clone.git")
+			dir := filepath.Join(parent, "clone.git")
+
+			for _, name := range []string{".git", "a", "b", "index"} {
+				if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+					t.Errorf("clone %s made %s", option, name)
+				}
+			}
+			if got := strings.TrimSpace(runGit(t, dir, "rev-parse", "--is-bare-repository")); got != "true" {
+				t.Errorf("clone %s: bare = %s", option, got)
+			}
+
+			got := runGit(t, dir, "for-each-ref")
+			mirrored, _ := runCommand(dir, "git", "config", "remote.origin.mirror")
+			if option == "--bare" {
+				if strings.Contains(got, "refs/changes/") {
+					t.Errorf("clone --bare copied refs/changes:\n%s", got)
+				}
+				if mirrored != "" {
+					t.Errorf("clone --bare set remote.origin.mirror = %s", mirrored)
+				}
+				return
+			}
+			if got != refs {
+				t.Errorf("clone --mirror refs:\n%s\nremote has:\n%s", got, refs)
+			}
+			if strings.TrimSpace(mirrored) != "true" {
+				t.Errorf("clone --mirror: remote.origin.mirror = %q, want true", mirrored)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+// argKind describes what a command's positional arguments refer to, so
+// that completion can offer sensible candidates.
+type argKind int
+
+const (
+	argNone argKind = iota
+	argRef
+	argPath
+)
+
+type command struct {
+	name   string
+	args   argKind
+	hidden bool
+	// noRepo commands make or need no repository, so got does not look
+	// for one before running them.
+	noRepo bool
+	// workTree commands read or change files, so a bare repository
+	// cannot run them.
+	workTree bool
+	run      func(args []string)
+}
+
+// commands is the registry of every got subcommand. It is filled in by
+// init because some commands (completion) need to look at the registry.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "init", args: argNone, noRepo: true, run: runInit},
+		{name: "clone", args: argNone, noRepo: true, run: runClone},
+		{name: "fetch", args: argNone, run: runFetch},
+		{name: "push", args: argNone, run: runPush},
+		{name: "remote", args: argNone, run: runRemote},
+		{name: "ls-remote", args: argRef, run: runLsRemote},
+		{name: "cat-file", args: argRef, run: runCatFile},
+		{name: "hash-object", args: argPath, run: runHashObject},
+		{name: "ls-tree", args: argRef, run: runLsTree},
+		{name: "add", args: argPath, workTree: true, run: runAdd},
+		{name: "status", args: argPath, workTree: true, run: runStatus},
+		{name: "write-tree", args: argNone, run: runWriteTree},
+		{name: "commit", args: argPath, workTree: true, run: runCommit},
+		{name: "commit-tree", args: argRef, run: runCommitTree},
+		{name: "diff-tree", args: argRef, run: runDiffTree},
+		{name: "log", args: argRef, run: runLog},
+		{name: "show", args: argRef, run: runShow},
+		{name: "merge-base", args: argRef, run: runMergeBase},
+		{name: "merge-tree", args: argRef, run: runMergeTree},
+		{name: "merge", args: argRef, workTree: true, run: runMerge},
+		{name: "ls-files", args: argNone, run: runLsFiles},
+		{name: "checkout", args: argRef, workTree: true, run: runCheckout},
+		{name: "restore", args: argPath, workTree: true, run: runRestore},
+		{name: "reset", args: argRef, run: runReset},
+		{name: "rm", args: argPath, workTree: true, run: runRm},
+		{name: "mv", args: argPath, workTree: true, run: runMv},
+		{name: "stash", args: argNone, workTree: true, run: runStash},
+		{name: "archive", args: argRef, run: runArchive},
+		{name: "bundle", args: argRef, run: runBundle},
+		{name: "reflog", args: argRef, run: runReflog},
+		{name: "rev-parse", args: argRef, run: runRevParse},
+		{name: "show-ref", args: argNone, run: runShowRef},
+		{name: "fsck", args: argNone, run: runFsck},
+		{name: "verify-pack", args: argPath, run: runVerifyPack},
+		{name: "unpack-objects", args: argNone, run: runUnpackObjects},
+		{name: "index-pack", args: argPath, run: runIndexPack},
+		{name: "pack-objects", args: argPath, run: runPackObjects},
+		{name: "gc", args: argNone, run: runGc},
+		{name: "count-objects", args: argNone, run: runCountObjects},
+		{name: "prune", args: argNone, run: runPrune},
+		{name: "branch", args: argRef, run: runBranch},
+		{name: "tag", args: argRef, run: runTag},
+		{name: "diff", args: argPath, run: runDiff},
+		{name: "config", args: argNone, run: runConfig},
+		{name: "version", args: argNone, noRepo: true, run: runVersion},
+		{name: "__complete", args: argNone, hidden: true, run: runComplete},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
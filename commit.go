@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+func runCommit(args []string) {
+	var messages []string
+	var all, allowEmpty, quiet bool
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-m" || arg == "--message" || arg == "-am" || arg == "-F" || arg == "--file":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			if arg == "-am" {
+				all = true
+			}
+			if arg != "-F" && arg != "--file" {
+				messages = append(messages, args[i])
+				continue
+			}
+			message, err := readMessageFile(prefixFilename(args[i]))
+			if err != nil {
+				handleError(err)
+			}
+			messages = append(messages, message)
+		case strings.HasPrefix(arg, "--message="):
+			messages = append(messages, strings.TrimPrefix(arg, "--message="))
+		case strings.HasPrefix(arg, "-m") && len(arg) > 2:
+			messages = append(messages, arg[2:])
+		case arg == "-a" || arg == "--all":
+			all = true
+		case arg == "--allow-empty":
+			allowEmpty = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		default:
+			handleError(errors.New("usage: got commit [-a] [-q] [--allow-empty] (-m <message> | -F <file>)..."))
+		}
+	}
+	// Concluding a conflicted merge records the merged commit as a second
+	// parent and offers the prepared message.
+	var mergeHead string
+	if data, err := os.ReadFile(mergeHeadPath()); err == nil {
+		mergeHead = strings.TrimSpace(string(data))
+		if len(messages) == 0 {
+			if data, err := os.ReadFile(mergeMsgPath()); err == nil {
+				messages = append(messages, stripComments(string(data)))
+			}
+		}
+	}
+	if len(messages) == 0 {
+		handleError(errors.New("no commit message given; use -m <message> or -F <file>"))
+	}
+	message := cleanupMessage(strings.Join(messages, "\n\n"))
+	if message == "" {
+		handleError(errors.New("aborting commit due to empty commit message"))
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+	if all {
+		if err := stageTrackedChanges(idx); err != nil {
+			handleError(err)
+		}
+	}
+	for _, entry := range idx.Entries {
+		if entry.Stage() != 0 {
+			handleError(errors.New("committing is not possible because you have unmerged files"))
+		}
+	}
+	tree, err := writeIndexTree(idx, "", false)
+	if err != nil {
+		handleError(err)
+	}
+
+	var parents []string
+	parentTree := object.EmptyTreeHash
+	head, err := resolveRef("HEAD")
+	switch {
+	case errors.Is(err, errRefNotFound):
+	case err != nil:
+		handleError(err)
+	default:
+		parents = []string{head}
+		if parentTree, err = peelToTree(head); err != nil {
+			handleError(err)
+		}
+	}
+
+	if mergeHead != "" {
+		parents = append(parents, mergeHead)
+	}
+
+	if tree == parentTree && !allowEmpty && mergeHead == "" {
+		// Like git, explain why with the status and fail.
+		status, err := readStatus()
+		if err != nil {
+			handleError(err)
+		}
+		writeLongStatus(status)
+		exit(1)
+	}
+
+	hash, err := createCommit(tree, parents, message)
+	if err != nil {
+		handleError(err)
+	}
+
+	branch, onBranch, err := currentBranch()
+	if err != nil {
+		handleError(err)
+	}
+	ref := "HEAD"
+	if onBranch {
+		ref = "refs/heads/" + branch
+	}
+	if err := writeRef(ref, hash); err != nil {
+		handleError(err)
+	}
+	// What -a staged is saved only once the commit exists, so a failure
+	// leaves the index as it was.
+	if all {
+		if err := idx.write(); err != nil {
+			handleError(err)
+		}
+	}
+
+	if mergeHead != "" {
+		if err := clearMergeState(); err != nil {
+			handleError(err)
+		}
+	}
+
+	subject, _ := splitMessage(message)
+	action := "commit"
+	switch {
+	case len(parents) == 0:
+		action = "commit (initial)"
+	case mergeHead != "":
+		action = "commit (merge)"
+	}
+	if err := appendReflog("HEAD", head, hash, action+": "+subject); err != nil {
+		handleError(err)
+	}
+	if onBranch {
+		if err := appendReflog(ref, head, hash, action+": "+subject); err != nil {
+			handleError(err)
+		}
+	}
+
+	if quiet {
+		return
+	}
+	label := "detached HEAD"
+	if onBranch {
+		label = branch
+	}
+	if len(parents) == 0 {
+		label += " (root-commit)"
+	}
+	fmt.Printf("[%s %s] %s\n", label, hash[:7], subject)
+}
+
+// readMessageFile reads a commit message from name, or from stdin if name
+// is "-".
+func readMessageFile(name string) (string, error) {
+	if name == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		return string(b), err
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("could not read log file '%s': %w", name, err)
+	}
+	return string(b), nil
+}
+
+// cleanupMessage strips trailing whitespace from each line, collapses runs
+// of blank lines and removes leading and trailing blank lines, as git's
+// default "strip" cleanup does apart from comments. The result ends in a
+// newline unless it is empty.
+func cleanupMessage(message string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// stripComments drops the "#" lines git puts in prepared messages.
+func stripComments(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stageTrackedChanges updates idx with the working tree copy of every
+// tracked file that was modified, and drops files that were deleted, as
+// "got commit -a" does. Untracked files are left alone.
+func stageTrackedChanges(idx *Index) error {
+	var removed []string
+	for _, entry := range idx.Entries {
+		if entry.Stage() != 0 || entry.Mode == 0160000 {
+			continue
+		}
+		fullPath := filepath.FromSlash(entry.Path)
+		info, err := os.Lstat(fullPath)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && info.IsDir()) {
+			removed = append(removed, entry.Path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := unchangedHash(entry.Path, info); ok {
+			continue
+		}
+
+		mode, content, err := readWorktreeBlob(fullPath)
+		if err != nil {
+			return err
+		}
+		if mode == entry.ModeString() && hashBlob(content) == entry.Hash {
+			setStat(entry, info)
+			continue
+		}
+		hash, err := writeObject("blob", content)
+		if err != nil {
+			return err
+		}
+		updated, err := newIndexEntry(entry.Path, mode, hash, info)
+		if err != nil {
+			return err
+		}
+		*entry = *updated
+	}
+	for _, p := range removed {
+		idx.remove(p)
+	}
+	return nil
+}
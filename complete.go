@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runComplete implements the hidden "__complete <shell> <words>..." helper
+// used by shell completion scripts. The words are the command line typed so
+// far, after "got"; the last one is the word being completed. Candidates are
+// printed one per line.
+func runComplete(args []string) {
+	if len(args) < 1 {
+		handleError(errors.New("usage: got __complete <shell> [<words>...]"))
+	}
+	if shell := args[0]; shell != "bash" && shell != "zsh" {
+		handleError(fmt.Errorf("unsupported shell %q", shell))
+	}
+
+	words := args[1:]
+	partial := ""
+	if len(words) > 0 {
+		partial = words[len(words)-1]
+	}
+
+	var candidates []string
+	if len(words) <= 1 {
+		candidates = completeCommands(partial)
+	} else if cmd := findCommand(words[0]); cmd != nil && !strings.HasPrefix(partial, "-") {
+		switch cmd.args {
+		case argRef:
+			candidates = completeRefs(partial)
+		case argPath:
+			candidates = completePaths(partial)
+		}
+	}
+
+	for _, candidate := range candidates {
+		fmt.Println(candidate)
+	}
+}
+
+func completeCommands(partial string) []string {
+	var candidates []string
+	for _, cmd := range commands {
+		if !cmd.hidden && strings.HasPrefix(cmd.name, partial) {
+			candidates = append(candidates, cmd.name)
+		}
+	}
+	return candidates
+}
+
+// completeRefs offers HEAD and the short names of branches, tags and
+// remote-tracking branches.
+func completeRefs(partial string) []string {
+	names := []string{"HEAD"}
+	refs, err := listRefs()
+	if err == nil {
+		for _, ref := range refs {
+			for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+				if short, found := strings.CutPrefix(ref, prefix); found {
+					names = append(names, short)
+					break
+				}
+			}
+		}
+	}
+
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, partial) {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates
+}
+
+// completePaths offers the entries of the directory named by partial,
+// with a trailing slash on directories. partial is relative to where got
+// was run, not the top of the work tree.
+func completePaths(partial string) []string {
+	dir, base := filepath.Split(partial)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+
+	entries, err := os.ReadDir(prefixFilename(readDir))
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		if entry.Name() == ".git" || !strings.HasPrefix(entry.Name(), base) {
+			continue
+		}
+		candidate := dir + entry.Name()
+		if entry.IsDir() {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCompleteInSubdirectory(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a/b/c.txt", "c\n", "add c")
+	commitFile(t, dir, "a/d.txt", "d\n", "add d")
+	sub := filepath.Join(dir, "a")
+	tests := []struct {
+		name  string
+		words []string
+		want  string
+	}{
+		{name: "refs", words: []string{"checkout", "ma"}, want: "main\n"},
+		{name: "paths", words: []string{"add", "b/"}, want: "b/c.txt\n"},
+		{name: "paths in the current directory", words: []string{"add", "d"}, want: "d.txt\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"__complete", "bash"}, tt.words...)
+			if got := runGot(t, sub, args...); got != tt.want {
+				t.Errorf("got %v in a = %q, want %q", args, got, tt.want)
+			}
+		})
+	}
+}
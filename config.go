@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds the values read from a git config file. Keys are stored as
+// "section.key" or "section.subsection.key"; section and key names are
+// case-insensitive, subsection names are not.
+type Config struct {
+	values map[string][]string
+	// entries keeps every key and value in the order they were read, for
+	// listing.
+	entries []configEntry
+}
+
+type configEntry struct {
+	Key   string
+	Value string
+}
+
+// readConfig parses the INI-style config file at path. A missing file is
+// treated as an empty config.
+func readConfig(path string) (*Config, error) {
+	config := &Config{values: make(map[string][]string)}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.LastIndexByte(line, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("%s:%d: bad section header", path, lineNo)
+			}
+			section, err = parseSectionHeader(line[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("%s:%d: key outside of a section", path, lineNo)
+		}
+
+		// A key without "=" is boolean shorthand for true.
+		key, value, found := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if found {
+			// A backslash at the end of the line joins the next one on.
+			for continuesLine(value) {
+				value = value[:len(value)-1]
+				if !scanner.Scan() {
+					break
+				}
+				lineNo++
+				value += scanner.Text()
+			}
+			value = parseConfigValue(value)
+		} else {
+			key = strings.ToLower(strings.TrimSpace(stripConfigComment(line)))
+			value = "true"
+		}
+		full := section + "." + key
+		config.values[full] = append(config.values[full], value)
+		config.entries = append(config.entries, configEntry{full, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseSectionHeader turns `core` into "core" and `remote "origin"` into
+// "remote.origin".
+func parseSectionHeader(header string) (string, error) {
+	name, sub, found := strings.Cut(strings.TrimSpace(header), " ")
+	name = strings.ToLower(name)
+	if !found {
+		return name, nil
+	}
+	sub = strings.TrimSpace(sub)
+	if len(sub) < 2 || sub[0] != '"' || sub[len(sub)-1] != '"' {
+		return "", errors.New("bad subsection name")
+	}
+	sub = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(sub[1 : len(sub)-1])
+	return name + "." + sub, nil
+}
+
+// parseConfigValue strips surrounding whitespace, trailing comments and
+// quotes from a raw value, and expands the usual escape sequences.
+func parseConfigValue(raw string) string {
+	var value strings.Builder
+	inQuote := false
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(raw[i])
+			}
+		case (c == '#' || c == ';') && !inQuote:
+			return strings.TrimSpace(value.String())
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return strings.TrimRight(value.String(), " \t")
+}
+
+// continuesLine reports whether a raw value ends in a backslash that
+// continues it on the next line, rather than in an escape or a comment.
+func continuesLine(raw string) bool {
+	inQuote := false
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == '"':
+			inQuote = !inQuote
+		case c == '\\':
+			if i+1 == len(raw) {
+				return true
+			}
+			i++
+		case (c == '#' || c == ';') && !inQuote:
+			return false
+		}
+	}
+	return false
+}
+
+func stripConfigComment(line string) string {
+	if idx := strings.IndexAny(line, "#;"); idx != -1 {
+		return line[:idx]
+	}
+	return line
+}
+
+// Get returns the last value set for key in section. Subsections are
+// addressed with a dot, e.g. Get("remote.origin", "url").
+func (c *Config) Get(section, key string) (string, bool) {
+	values := c.values[configKey(section, key)]
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// Bool returns the boolean value of key in section, or def if it is unset
+// or not a recognised boolean.
+func (c *Config) Bool(section, key string, def bool) bool {
+	value, ok := c.Get(section, key)
+	if !ok {
+		return def
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0", "":
+		return false
+	}
+	return def
+}
+
+func configKey(section, key string) string {
+	name, sub, found := strings.Cut(section, ".")
+	name = strings.ToLower(name)
+	if found {
+		name += "." + sub
+	}
+	return name + "." + strings.ToLower(key)
+}
+
+// Size returns an integer value of key in section, accepting git's k, m
+// and g suffixes, or def if it is unset.
+func (c *Config) Size(section, key string, def int64) (int64, error) {
+	value, ok := c.Get(section, key)
+	if !ok {
+		return def, nil
+	}
+	n, err := parseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s' for '%s.%s'", value, section, key)
+	}
+	return n, nil
+}
+
+// parseSize parses an integer with an optional k, m or g suffix.
+func parseSize(value string) (int64, error) {
+	multiplier := int64(1)
+	if value != "" {
+		switch strings.ToLower(value[len(value)-1:]) {
+		case "k":
+			multiplier = 1 << 10
+		case "m":
+			multiplier = 1 << 20
+		case "g":
+			multiplier = 1 << 30
+		}
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// globalConfigPath returns the per-user config file: $GIT_CONFIG_GLOBAL if
+// set, otherwise ~/.gitconfig.
+func globalConfigPath() (string, error) {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".gitconfig"), nil
+}
+
+// readRepoConfig reads the global config followed by the repository's own
+// config at path, so that values set in the repository win.
+func readRepoConfig(path string) (*Config, error) {
+	config := &Config{values: make(map[string][]string)}
+	global, err := globalConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range []string{global, path} {
+		c, err := readConfig(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range c.entries {
+			config.values[entry.Key] = append(config.values[entry.Key], entry.Value)
+			config.entries = append(config.entries, entry)
+		}
+	}
+	return config, nil
+}
+
+// splitConfigName splits "user.name" into "user" and "name", and
+// "remote.origin.url" into "remote.origin" and "url".
+func splitConfigName(name string) (section, key string, err error) {
+	i := strings.IndexByte(name, '.')
+	j := strings.LastIndexByte(name, '.')
+	if i <= 0 || j == len(name)-1 {
+		return "", "", fmt.Errorf("key does not contain a section: %s", name)
+	}
+	return name[:j], name[j+1:], nil
+}
+
+// formatSectionHeader is the inverse of parseSectionHeader.
+func formatSectionHeader(section string) string {
+	name, sub, found := strings.Cut(section, ".")
+	if !found {
+		return "[" + name + "]"
+	}
+	sub = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(sub)
+	return fmt.Sprintf("[%s \"%s\"]", name, sub)
+}
+
+// quoteConfigValue escapes value so that parseConfigValue reads it back
+// unchanged, quoting it if it has surrounding space or comment characters.
+func quoteConfigValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
+
+// setConfigValue rewrites the config file at path so that name is set to
+// value, or removed if unset is true. An existing key is changed in place;
+// a new one is added at the end of its section, which is created if need
+// be.
+func setConfigValue(path, name, value string, unset bool) error {
+	return editConfigValue(path, name, value, unset, false)
+}
+
+// addConfigValue adds another value for name to the config file at path,
+// after any it already has, as multi-valued keys such as
+// remote.<name>.fetch need.
+func addConfigValue(path, name, value string) error {
+	return editConfigValue(path, name, value, false, true)
+}
+
+func editConfigValue(path, name, value string, unset, add bool) error {
+	section, key, err := splitConfigName(name)
+	if err != nil {
+		return err
+	}
+	want := configKey(section, key)
+
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	text := strings.TrimSuffix(string(b), "\n")
+	var lines []string
+	if text != "" {
+		lines = strings.Split(text, "\n")
+	}
+
+	current, sectionEnd := "", -1
+	var matches []int
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			end := strings.LastIndexByte(trimmed, ']')
+			current = ""
+			if end != -1 {
+				current, _ = parseSectionHeader(trimmed[1:end])
+			}
+			if configKey(current, "") == configKey(section, "") {
+				sectionEnd = i
+			}
+			continue
+		}
+		if current == "" || configKey(current, "") != configKey(section, "") {
+			continue
+		}
+		sectionEnd = i
+		k, _, _ := strings.Cut(stripConfigComment(trimmed), "=")
+		if trimmed != "" && configKey(current, strings.TrimSpace(k)) == want {
+			matches = append(matches, i)
+		}
+	}
+
+	line := fmt.Sprintf("\t%s = %s", key, quoteConfigValue(value))
+	switch {
+	case unset:
+		if len(matches) == 0 {
+			return fmt.Errorf("key '%s' is not set", name)
+		}
+		for n := len(matches) - 1; n >= 0; n-- {
+			lines = append(lines[:matches[n]], lines[matches[n]+1:]...)
+		}
+	case len(matches) > 0 && !add:
+		lines[matches[len(matches)-1]] = line
+	case sectionEnd >= 0:
+		lines = append(lines[:sectionEnd+1], append([]string{line}, lines[sectionEnd+1:]...)...)
+	default:
+		lines = append(lines, formatSectionHeader(section), line)
+	}
+
+	return writeConfigLines(path, lines)
+}
+
+// renameConfigSection renames section in the config file at path to
+// newSection, or removes it with all its keys if newSection is empty. It
+// reports whether the section was there.
+func renameConfigSection(path, section, newSection string) (bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	text := strings.TrimSuffix(string(b), "\n")
+	if text == "" {
+		return false, nil
+	}
+
+	found, inSection := false, false
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			current := ""
+			if end := strings.LastIndexByte(trimmed, ']'); end != -1 {
+				current, _ = parseSectionHeader(trimmed[1:end])
+			}
+			inSection = configKey(current, "") == configKey(section, "")
+			if inSection {
+				found = true
+				if newSection != "" {
+					lines = append(lines, formatSectionHeader(newSection))
+				}
+				continue
+			}
+		}
+		if !inSection || newSection != "" {
+			lines = append(lines, line)
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	return true, writeConfigLines(path, lines)
+}
+
+// writeConfigLines replaces the config file at path with lines.
+func writeConfigLines(path string, lines []string) error {
+	lock := path + ".lock"
+	if err := os.WriteFile(lock, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(lock, path)
+}
+
+func runConfig(args []string) {
+	usage := errors.New("usage: got config [--global] (<name> [<value>] | --unset <name> | --list)")
+	global, unset, list := false, false, false
+	var rest []string
+	for _, arg := range args {
+		switch arg {
+		case "--global":
+			global = true
+		case "--unset":
+			unset = true
+		case "-l", "--list":
+			list = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				handleError(usage)
+			}
+			rest = append(rest, arg)
+		}
+	}
+
+	path := commonPath("config")
+	if global {
+		var err error
+		if path, err = globalConfigPath(); err != nil {
+			handleError(err)
+		}
+	}
+
+	switch {
+	case list && len(rest) == 0 && !unset:
+		for _, entry := range readConfigScope(path, global).entries {
+			fmt.Printf("%s=%s\n", entry.Key, entry.Value)
+		}
+	case unset && len(rest) == 1:
+		if err := setConfigValue(path, rest[0], "", true); err != nil {
+			handleError(err)
+		}
+	case len(rest) == 2 && !unset && !list:
+		if !global {
+			if _, err := os.Stat(gitDir); err != nil {
+				handleError(errors.New("not a git repository"))
+			}
+		}
+		if err := setConfigValue(path, rest[0], rest[1], false); err != nil {
+			handleError(err)
+		}
+	case len(rest) == 1 && !unset && !list:
+		section, key, err := splitConfigName(rest[0])
+		if err != nil {
+			handleError(err)
+		}
+		value, ok := readConfigScope(path, global).Get(section, key)
+		if !ok {
+			exit(1)
+		}
+		fmt.Println(value)
+	default:
+		handleError(usage)
+	}
+}
+
+// readConfigScope reads only the file at path for --global, and the
+// global config merged with it otherwise.
+func readConfigScope(path string, global bool) *Config {
+	read := readRepoConfig
+	if global {
+		read = readConfig
+	}
+	config, err := read(path)
+	if err != nil {
+		handleError(err)
+	}
+	return config
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigLineContinuation(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"joined", "multi = a\\\nb\n", "ab"},
+		{"three lines", "multi = a\\\nb\\\nc\n", "abc"},
+		{"inside quotes", "multi = \"a \\\n b\"\n", "a  b"},
+		{"escaped backslash", "multi = a\\\\\n", `a\`},
+		{"in a comment", "multi = a # b\\\nother = c\n", "a"},
+		{"last line", "multi = a\\", "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte("[section]\n"+tt.text), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := readConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := config.Get("section", "multi"); got != tt.want {
+				t.Errorf("section.multi = %q, want %q", got, tt.want)
+			}
+			// git reads the same file the same way.
+			dir := newTestRepo(t)
+			if want := runGit(t, dir, "config", "-f", path, "section.multi"); want != tt.want+"\n" {
+				t.Errorf("git has section.multi = %q, test wants %q", want, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityDates(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "first")
+	tree := strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD^{tree}"))
+	for _, date := range []string{
+		"1600000000 +0530",
+		"@1600000000 -0230",
+		"Thu, 07 Apr 2005 22:13:13 +0200",
+		"7 Apr 2005 22:13:13 -0700",
+		"2005-04-07T22:13:13+02:00",
+		"2005-04-07T22:13:13Z",
+		"2005-04-07 22:13:13 -0700",
+		"2005-04-07T22:13:13",
+	} {
+		t.Run(date, func(t *testing.T) {
+			env := []string{"TZ=UTC", "GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=1700000000 -0100"}
+			args := []string{"commit-tree", tree, "-m", "dated"}
+			want, stderr, err := runCommandEnv(dir, "", env, "git", args...)
+			if err != nil {
+				t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, stderr)
+			}
+			got, stderr, err := runCommandEnv(dir, "", env, gotBinary, args...)
+			if err != nil {
+				t.Fatalf("got %s: %v\n%s", strings.Join(args, " "), err, stderr)
+			}
+			if got != want {
+				t.Errorf("got wrote commit %s, git wrote %s:\n%s", strings.TrimSpace(got), strings.TrimSpace(want),
+					runGit(t, dir, "cat-file", "commit", strings.TrimSpace(got)))
+			}
+		})
+	}
+
+	env := []string{"GIT_AUTHOR_DATE=yesterday-ish"}
+	if _, stderr, err := runCommandEnv(dir, "", env, gotBinary, "commit-tree", tree, "-m", "bad"); err == nil || !strings.Contains(stderr, "invalid date format") {
+		t.Errorf("commit-tree with a bad date: %v\n%s", err, stderr)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushyadav1617/got/repo"
+)
+
+func runCountObjects(args []string) {
+	verbose := false
+	for _, arg := range args {
+		if arg == "-v" || arg == "--verbose" {
+			verbose = true
+		} else {
+			handleError(errors.New("usage: got count-objects [-v]"))
+		}
+	}
+
+	objects, err := looseObjects()
+	if err != nil {
+		handleError(err)
+	}
+	var looseSize int64
+	for _, path := range objects {
+		info, err := os.Lstat(path)
+		if err != nil {
+			handleError(err)
+		}
+		looseSize += diskUsage(info)
+	}
+
+	if !verbose {
+		fmt.Printf("%d objects, %d kilobytes\n", len(objects), looseSize/1024)
+		return
+	}
+
+	packDir := commonPath("objects", "pack")
+	entries, err := os.ReadDir(packDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		handleError(err)
+	}
+
+	var packs, inPack, garbage int
+	var packSize, garbageSize int64
+	var packed []*repo.Pack
+	for _, entry := range entries {
+		name := entry.Name()
+		info, err := entry.Info()
+		if err != nil {
+			handleError(err)
+		}
+		ext := filepath.Ext(name)
+		switch {
+		case strings.HasPrefix(name, "pack-") && ext == ".pack":
+			p, err := openPack(filepath.Join(packDir, name))
+			if err != nil {
+				// A pack without a usable index is garbage to git too.
+				garbage++
+				garbageSize += diskUsage(info)
+				continue
+			}
+			packs++
+			inPack += p.Count()
+			packed = append(packed, p)
+			// Unlike loose objects, packs are counted by file size.
+			packSize += info.Size()
+		case strings.HasPrefix(name, "pack-") && ext == ".idx":
+			packSize += info.Size()
+		case strings.HasPrefix(name, "pack-") && (ext == ".keep" || ext == ".bitmap" || ext == ".rev" || ext == ".promisor" || ext == ".mtimes"):
+		default:
+			garbage++
+			garbageSize += diskUsage(info)
+		}
+	}
+
+	// Loose objects that are also in a pack can be removed by prune-packed.
+	prunable := 0
+	for hash := range objects {
+		for _, p := range packed {
+			if _, ok := p.Find(hash); ok {
+				prunable++
+				break
+			}
+		}
+	}
+
+	fmt.Printf("count: %d\n", len(objects))
+	fmt.Printf("size: %d\n", looseSize/1024)
+	fmt.Printf("in-pack: %d\n", inPack)
+	fmt.Printf("packs: %d\n", packs)
+	fmt.Printf("size-pack: %d\n", packSize/1024)
+	fmt.Printf("prune-packable: %d\n", prunable)
+	fmt.Printf("garbage: %d\n", garbage)
+	fmt.Printf("size-garbage: %d\n", garbageSize/1024)
+}
@@ -0,0 +1,128 @@
+package main
+
+// deltaBlock is the length of the chunks a delta base is indexed by. A
+// match must be at least this long to be found.
+const deltaBlock = 16
+
+// deltaMaxCopy is the longest run one copy instruction takes. Longer
+// matches are split, as git does, so any reader can apply them.
+const deltaMaxCopy = 0x10000
+
+// deltaIndex maps every deltaBlock-aligned chunk of a base object to where
+// it occurs, so deltas against that base can be computed quickly.
+type deltaIndex struct {
+	base   []byte
+	chunks map[[deltaBlock]byte][]int
+}
+
+func newDeltaIndex(base []byte) *deltaIndex {
+	idx := &deltaIndex{base: base, chunks: make(map[[deltaBlock]byte][]int)}
+	for i := 0; i+deltaBlock <= len(base); i += deltaBlock {
+		var key [deltaBlock]byte
+		copy(key[:], base[i:])
+		// A few positions per chunk are plenty; repetitive data would
+		// otherwise make lookups slow.
+		if len(idx.chunks[key]) < 8 {
+			idx.chunks[key] = append(idx.chunks[key], i)
+		}
+	}
+	return idx
+}
+
+// computeDelta encodes target as a git delta against idx's base: copy
+// instructions for runs found in the base and inserts for the rest. It
+// returns nil if the delta would be longer than maxSize.
+func (idx *deltaIndex) computeDelta(target []byte, maxSize int) []byte {
+	delta := appendDeltaSize(nil, len(idx.base))
+	delta = appendDeltaSize(delta, len(target))
+	var insert []byte
+	flush := func() {
+		for len(insert) > 0 {
+			n := min(len(insert), 0x7f)
+			delta = append(delta, byte(n))
+			delta = append(delta, insert[:n]...)
+			insert = insert[n:]
+		}
+	}
+
+	for p := 0; p < len(target); {
+		bestOffset, bestLen := 0, 0
+		if p+deltaBlock <= len(target) {
+			var key [deltaBlock]byte
+			copy(key[:], target[p:])
+			for _, offset := range idx.chunks[key] {
+				n := 0
+				for offset+n < len(idx.base) && p+n < len(target) && idx.base[offset+n] == target[p+n] {
+					n++
+				}
+				if n > bestLen {
+					bestOffset, bestLen = offset, n
+				}
+			}
+		}
+		if bestLen < deltaBlock {
+			insert = append(insert, target[p])
+			p++
+			if len(delta)+len(insert) > maxSize {
+				return nil
+			}
+			continue
+		}
+
+		// The match may start earlier than the chunk it was found by.
+		p += bestLen
+		for bestOffset > 0 && len(insert) > 0 && idx.base[bestOffset-1] == insert[len(insert)-1] {
+			bestOffset--
+			bestLen++
+			insert = insert[:len(insert)-1]
+		}
+		flush()
+		for bestLen > 0 {
+			n := min(bestLen, deltaMaxCopy)
+			delta = appendDeltaCopy(delta, bestOffset, n)
+			bestOffset += n
+			bestLen -= n
+		}
+		if len(delta) > maxSize {
+			return nil
+		}
+	}
+	flush()
+	if len(delta) > maxSize {
+		return nil
+	}
+	return delta
+}
+
+// appendDeltaSize appends a size in the delta header's little-endian
+// groups of 7 bits.
+func appendDeltaSize(b []byte, size int) []byte {
+	for size >= 0x80 {
+		b = append(b, byte(size)|0x80)
+		size >>= 7
+	}
+	return append(b, byte(size))
+}
+
+// appendDeltaCopy appends an instruction copying size bytes from offset in
+// the base. Only the non-zero bytes of each are written, flagged in the
+// opcode; a size of 0x10000 is written as no bytes at all.
+func appendDeltaCopy(b []byte, offset, size int) []byte {
+	op := byte(0x80)
+	var args []byte
+	for i := 0; i < 4; i++ {
+		if v := byte(offset >> (8 * i)); v != 0 {
+			op |= 1 << i
+			args = append(args, v)
+		}
+	}
+	if size != deltaMaxCopy {
+		for i := 0; i < 3; i++ {
+			if v := byte(size >> (8 * i)); v != 0 {
+				op |= 0x10 << i
+				args = append(args, v)
+			}
+		}
+	}
+	return append(append(b, op), args...)
+}
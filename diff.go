@@ -0,0 +1,821 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+const diffContextLines = 3
+
+type diffOp struct {
+	Kind byte // ' ', '-' or '+'
+	Line string
+}
+
+// splitLines splits content into lines, keeping the trailing newline on
+// each line so that a missing newline at end of file can be reported.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		idx := bytes.IndexByte(content, '\n')
+		if idx == -1 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:idx+1]))
+		content = content[idx+1:]
+	}
+	return lines
+}
+
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
+
+// diffLines computes the shortest edit script between a and b using the
+// linear-space form of Myers' O(ND) algorithm: the middle snake of an
+// optimal path is found by searching forward from the start and backward
+// from the end at once, and the halves on either side of it are diffed in
+// turn. Memory stays O(N+M) however far apart a and b are. Within each run
+// of changes the removed lines come before the added ones, as in git.
+func diffLines(a, b []string) []diffOp {
+	d := &myersDiff{a: a, b: b, ops: make([]diffOp, 0, max(len(a), len(b)))}
+	d.compare(0, len(a), 0, len(b))
+
+	ops := d.ops
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].Kind != ' ' {
+			j++
+		}
+		sort.SliceStable(ops[i:j], func(x, y int) bool {
+			return ops[i+x].Kind == '-' && ops[i+y].Kind == '+'
+		})
+		i = j
+	}
+	return ops
+}
+
+// myersDiff holds the state of one diffLines call. vf and vb are the
+// furthest reaching x on each diagonal of the forward search and the
+// furthest reaching y of the backward one, reused by every middle snake.
+type myersDiff struct {
+	a, b   []string
+	vf, vb []int
+	ops    []diffOp
+}
+
+// compare appends the edits turning a[aLo:aHi] into b[bLo:bHi].
+func (d *myersDiff) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{Kind: ' ', Line: d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := aHi
+	for aHi > aLo && bHi > bLo && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for _, line := range d.b[bLo:bHi] {
+			d.ops = append(d.ops, diffOp{Kind: '+', Line: line})
+		}
+	case bLo == bHi:
+		for _, line := range d.a[aLo:aHi] {
+			d.ops = append(d.ops, diffOp{Kind: '-', Line: line})
+		}
+	default:
+		// With the common ends gone and both sides non-empty, at least two
+		// edits remain, so both halves are strictly smaller.
+		x, y := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aHi, y, bHi)
+	}
+
+	for _, line := range d.a[aHi:suffix] {
+		d.ops = append(d.ops, diffOp{Kind: ' ', Line: line})
+	}
+}
+
+// middleSnake returns a point on an optimal path from (aLo, bLo) to (aHi,
+// bHi) that splits its edits roughly in half.
+func (d *myersDiff) middleSnake(aLo, aHi, bLo, bHi int) (int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	if size := 2*limit + 3; len(d.vf) < size {
+		d.vf = make([]int, size)
+		d.vb = make([]int, size)
+	}
+	vf, vb := d.vf, d.vb
+	vf[offset+1] = aLo
+	vb[offset+1] = bHi
+
+	for depth := 0; depth <= limit; depth++ {
+		// Forward: vf holds x on diagonal k = (x-aLo) - (y-bLo).
+		for k := depth; k >= -depth; k -= 2 {
+			var x, px int
+			if k == -depth || (k != depth && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+				px = x
+			} else {
+				px = vf[offset+k-1]
+				x = px + 1
+			}
+			y := bLo + (x - aLo) - k
+			py := y
+			if depth > 0 && x == px {
+				py--
+			}
+			for x < aHi && y < bHi && d.a[x] == d.b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			if c := k - delta; delta%2 != 0 && -(depth-1) <= c && c <= depth-1 && y >= vb[offset+c] {
+				return px, py
+			}
+		}
+
+		// Backward: vb holds y on diagonal c = k - delta, walking from
+		// the end.
+		for c := depth; c >= -depth; c -= 2 {
+			var y, py int
+			if c == -depth || (c != depth && vb[offset+c-1] > vb[offset+c+1]) {
+				y = vb[offset+c+1]
+				py = y
+			} else {
+				py = vb[offset+c-1]
+				y = py - 1
+			}
+			k := c + delta
+			x := aLo + (y - bLo) + k
+			for x > aLo && y > bLo && d.a[x-1] == d.b[y-1] {
+				x--
+				y--
+			}
+			vb[offset+c] = y
+			if delta%2 == 0 && -depth <= k && k <= depth && x <= vf[offset+k] {
+				return x, y
+			}
+		}
+	}
+	panic("diff: no middle snake")
+}
+
+// writeUnifiedHunks writes the @@ hunks describing the change from a to b.
+func writeUnifiedHunks(w io.Writer, a, b []string) {
+	ops := diffLines(a, b)
+
+	for start := 0; start < len(ops); {
+		// Find the next change.
+		for start < len(ops) && ops[start].Kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			return
+		}
+
+		// Extend the hunk until a run of unchanged lines is long enough
+		// to separate it from the next change.
+		end := start
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContextLines {
+				break
+			}
+			end = run
+		}
+
+		hunkStart := start - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + diffContextLines
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.Kind != '+' {
+				oldLine++
+			}
+			if op.Kind != '-' {
+				newLine++
+			}
+		}
+		var oldCount, newCount int
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.Kind != '+' {
+				oldCount++
+			}
+			if op.Kind != '-' {
+				newCount++
+			}
+		}
+
+		header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		if funcname := hunkFuncname(a[:oldLine-1]); funcname != "" {
+			header += " " + funcname
+		}
+		fmt.Fprintln(w, header)
+		for _, op := range ops[hunkStart:hunkEnd] {
+			fmt.Fprintf(w, "%c%s", op.Kind, op.Line)
+			if len(op.Line) == 0 || op.Line[len(op.Line)-1] != '\n' {
+				fmt.Fprint(w, "\n\\ No newline at end of file\n")
+			}
+		}
+
+		start = hunkEnd
+	}
+}
+
+// hunkFuncname returns the closest line before a hunk that looks like the
+// start of a function or section, using git's default heuristic of a line
+// beginning with a letter, '_' or '$'.
+func hunkFuncname(before []string) string {
+	for i := len(before) - 1; i >= 0; i-- {
+		line := strings.TrimRight(before[i], "\r\n")
+		if line == "" {
+			continue
+		}
+		c := line[0]
+		if c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			if len(line) > 80 {
+				line = line[:80]
+			}
+			return strings.TrimRight(line, " \t")
+		}
+	}
+	return ""
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// diffFile describes one side of a file comparison. A nil Content with an
+// empty Mode means the file does not exist on that side.
+type diffFile struct {
+	Path    string
+	Mode    string
+	Content []byte
+}
+
+func (f diffFile) exists() bool {
+	return f.Mode != ""
+}
+
+// writeFileDiff writes a git-style patch for the change from a to b.
+func writeFileDiff(w io.Writer, a, b diffFile) {
+	aName, bName := a.Path, b.Path
+	if !a.exists() {
+		aName = b.Path
+	}
+	if !b.exists() {
+		bName = a.Path
+	}
+	fmt.Fprintf(w, "diff --git a/%s b/%s\n", aName, bName)
+
+	oldHash, newHash := "0000000", "0000000"
+	if a.exists() {
+		oldHash = hashBlob(a.Content)[:7]
+	}
+	if b.exists() {
+		newHash = hashBlob(b.Content)[:7]
+	}
+
+	switch {
+	case !a.exists():
+		fmt.Fprintf(w, "new file mode %s\n", b.Mode)
+		fmt.Fprintf(w, "index %s..%s\n", oldHash, newHash)
+	case !b.exists():
+		fmt.Fprintf(w, "deleted file mode %s\n", a.Mode)
+		fmt.Fprintf(w, "index %s..%s\n", oldHash, newHash)
+	case a.Mode != b.Mode:
+		fmt.Fprintf(w, "old mode %s\n", a.Mode)
+		fmt.Fprintf(w, "new mode %s\n", b.Mode)
+		if oldHash != newHash {
+			fmt.Fprintf(w, "index %s..%s\n", oldHash, newHash)
+		}
+	default:
+		fmt.Fprintf(w, "index %s..%s %s\n", oldHash, newHash, a.Mode)
+	}
+
+	if bytes.Equal(a.Content, b.Content) {
+		return
+	}
+
+	oldPath, newPath := "a/"+a.Path, "b/"+b.Path
+	if !a.exists() {
+		oldPath = "/dev/null"
+	}
+	if !b.exists() {
+		newPath = "/dev/null"
+	}
+
+	if isBinary(a.Content) || isBinary(b.Content) {
+		fmt.Fprintf(w, "Binary files %s and %s differ\n", oldPath, newPath)
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n", oldPath)
+	fmt.Fprintf(w, "+++ %s\n", newPath)
+	writeUnifiedHunks(w, splitLines(a.Content), splitLines(b.Content))
+}
+
+func hashBlob(content []byte) string {
+	return object.Hash("blob", content)
+}
+
+// diffNoIndex compares two paths on disk, outside of any repository, and
+// reports whether they differ.
+func diffNoIndex(w io.Writer, pathA, pathB string) (bool, error) {
+	infoA, err := os.Stat(pathA)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(pathB)
+	if err != nil {
+		return false, err
+	}
+
+	if infoA.IsDir() != infoB.IsDir() {
+		return false, fmt.Errorf("cannot compare a directory with a file: %s, %s", pathA, pathB)
+	}
+	if !infoA.IsDir() {
+		a, err := readDiffFile(pathA)
+		if err != nil {
+			return false, err
+		}
+		b, err := readDiffFile(pathB)
+		if err != nil {
+			return false, err
+		}
+		if a.Mode == b.Mode && bytes.Equal(a.Content, b.Content) {
+			return false, nil
+		}
+		writeFileDiff(w, a, b)
+		return true, nil
+	}
+
+	filesA, err := listFiles(pathA)
+	if err != nil {
+		return false, err
+	}
+	filesB, err := listFiles(pathB)
+	if err != nil {
+		return false, err
+	}
+
+	names := make(map[string]bool)
+	for name := range filesA {
+		names[name] = true
+	}
+	for name := range filesB {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	differs := false
+	for _, name := range sorted {
+		var a, b diffFile
+		if filesA[name] {
+			if a, err = readDiffFile(filepath.Join(pathA, name)); err != nil {
+				return false, err
+			}
+		}
+		if filesB[name] {
+			if b, err = readDiffFile(filepath.Join(pathB, name)); err != nil {
+				return false, err
+			}
+		}
+		if a.Mode == b.Mode && bytes.Equal(a.Content, b.Content) {
+			continue
+		}
+		// Paths are shown relative to the command line arguments, the
+		// same way git does for --no-index.
+		if a.exists() {
+			a.Path = displayPath(filepath.Join(pathA, name))
+		}
+		if b.exists() {
+			b.Path = displayPath(filepath.Join(pathB, name))
+		}
+		writeFileDiff(w, a, b)
+		differs = true
+	}
+
+	return differs, nil
+}
+
+func readDiffFile(path string) (diffFile, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return diffFile{}, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return diffFile{}, err
+		}
+		return diffFile{Path: displayPath(path), Mode: "120000", Content: []byte(target)}, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return diffFile{}, err
+	}
+	mode := "100644"
+	if info.Mode()&0111 != 0 {
+		mode = "100755"
+	}
+	return diffFile{Path: displayPath(path), Mode: mode, Content: content}, nil
+}
+
+// displayPath formats a path for a diff header, where it is prefixed
+// with a/ or b/. Paths are shown relative to the directory got was run
+// from, undoing prefixFilename.
+func displayPath(path string) string {
+	if startDir != "." && !filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(startDir, path); err == nil {
+			path = rel
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// listFiles returns the set of file paths under dir, relative to dir.
+func listFiles(dir string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func runDiff(args []string) {
+	if len(args) > 0 && args[0] == "--no-index" {
+		if len(args) != 3 {
+			handleError(errors.New("usage: got diff --no-index <path> <path>"))
+		}
+		differs, err := diffNoIndex(os.Stdout, prefixFilename(args[1]), prefixFilename(args[2]))
+		if err != nil {
+			handleError(err)
+		}
+		if differs {
+			exit(1)
+		}
+		return
+	}
+
+	usage := errors.New("usage: got diff [--cached] [<commit> [<commit>]] [--] [<path>...]")
+	cached := false
+	var commits, paths []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--cached" || arg == "--staged":
+			cached = true
+		case arg == "--":
+			for _, p := range args[i+1:] {
+				paths = append(paths, prefixPath(p))
+			}
+			i = len(args)
+		case strings.HasPrefix(arg, "-"):
+			handleError(usage)
+		default:
+			// Arguments name commits until the first one that is not a
+			// revision; the rest are paths.
+			if len(paths) == 0 && len(commits) < 2 {
+				if from, to, ok := strings.Cut(arg, ".."); ok && len(commits) == 0 {
+					// An omitted side of A..B is HEAD.
+					if from == "" {
+						from = "HEAD"
+					}
+					if to == "" {
+						to = "HEAD"
+					}
+					a, errA := resolveRevision(from)
+					b, errB := resolveRevision(to)
+					if errA == nil && errB == nil {
+						commits = append(commits, a, b)
+						continue
+					}
+				} else if hash, err := resolveRevision(arg); err == nil {
+					commits = append(commits, hash)
+					continue
+				}
+			}
+			paths = append(paths, prefixPath(arg))
+		}
+	}
+
+	var err error
+	switch {
+	case len(commits) == 2:
+		if cached {
+			handleError(usage)
+		}
+		err = diffCommits(os.Stdout, commits[0], commits[1], paths)
+	case len(commits) == 1:
+		var tree string
+		if tree, err = peelToTree(commits[0]); err != nil {
+			break
+		}
+		if cached {
+			err = diffIndexToTree(os.Stdout, tree, paths)
+		} else {
+			err = diffWorktreeToTree(os.Stdout, tree, paths)
+		}
+	case cached:
+		var tree string
+		if tree, err = headTree(); err == nil {
+			err = diffIndexToTree(os.Stdout, tree, paths)
+		}
+	default:
+		err = diffWorktreeToIndex(os.Stdout, paths)
+	}
+	if err != nil {
+		handleError(err)
+	}
+}
+
+// matchesPaths reports whether name is one of paths or inside one of them.
+// An empty list matches everything.
+func matchesPaths(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		if p == "." || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffWorktreeToIndex writes a patch for every tracked file whose working
+// tree copy differs from the staged blob.
+func diffWorktreeToIndex(w io.Writer, paths []string) error {
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range idx.Entries {
+		if !matchesPaths(entry.Path, paths) {
+			continue
+		}
+		if entry.Stage() != 0 {
+			// Report each unmerged path once, on its first stage.
+			if i == 0 || idx.Entries[i-1].Path != entry.Path {
+				fmt.Fprintf(w, "* Unmerged path %s\n", entry.Path)
+			}
+			continue
+		}
+
+		old, err := blobDiffFile(entry.Path, TreeEntry{Mode: entry.ModeString(), Hash: entry.Hash})
+		if err != nil {
+			return err
+		}
+
+		fullPath := filepath.FromSlash(entry.Path)
+		info, err := os.Lstat(fullPath)
+		if errors.Is(err, os.ErrNotExist) {
+			writeFileDiff(w, old, diffFile{})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Mode == 0160000 {
+			continue
+		}
+		if _, ok := unchangedHash(entry.Path, info); ok {
+			continue
+		}
+
+		mode, content, err := readWorktreeBlob(fullPath)
+		if err != nil {
+			return err
+		}
+		if mode == old.Mode && hashBlob(content) == entry.Hash {
+			continue
+		}
+		writeFileDiff(w, old, diffFile{Path: entry.Path, Mode: mode, Content: content})
+	}
+	return nil
+}
+
+// diffIndexToTree writes a patch for every path staged differently from
+// tree. An empty tree hash stands for the empty tree.
+func diffIndexToTree(w io.Writer, tree string, paths []string) error {
+	treeFiles, err := flattenTree(tree)
+	if err != nil {
+		return err
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	staged := make(map[string]TreeEntry)
+	for _, entry := range idx.Entries {
+		if entry.Stage() == 0 {
+			staged[entry.Path] = TreeEntry{Mode: entry.ModeString(), Hash: entry.Hash}
+		}
+	}
+
+	for _, name := range unionPaths(treeFiles, staged) {
+		if !matchesPaths(name, paths) {
+			continue
+		}
+		oldEntry, newEntry := treeFiles[name], staged[name]
+		if oldEntry.Hash == newEntry.Hash && oldEntry.Mode == newEntry.Mode {
+			continue
+		}
+		if err := writeTreeChangePatch(w, treeChange{Path: name, Old: oldEntry, New: newEntry}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffWorktreeToTree writes a patch for every path whose working tree copy
+// differs from tree. As with git, only files in the index or in tree are
+// compared; untracked files are left out.
+func diffWorktreeToTree(w io.Writer, tree string, paths []string) error {
+	treeFiles, err := flattenTree(tree)
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	tracked := make(map[string]TreeEntry)
+	for i, entry := range idx.Entries {
+		if entry.Stage() != 0 {
+			if matchesPaths(entry.Path, paths) && (i == 0 || idx.Entries[i-1].Path != entry.Path) {
+				fmt.Fprintf(w, "* Unmerged path %s\n", entry.Path)
+			}
+			continue
+		}
+		tracked[entry.Path] = TreeEntry{Mode: entry.ModeString(), Hash: entry.Hash}
+	}
+
+	for _, name := range unionPaths(treeFiles, tracked) {
+		if !matchesPaths(name, paths) {
+			continue
+		}
+		old, err := blobDiffFile(name, treeFiles[name])
+		if err != nil {
+			return err
+		}
+		entry, inIndex := tracked[name]
+		if !inIndex {
+			writeFileDiff(w, old, diffFile{})
+			continue
+		}
+		if entry.Mode == "160000" {
+			// Submodules are compared by the commit staged for them.
+			if entry.Hash != treeFiles[name].Hash || entry.Mode != treeFiles[name].Mode {
+				if err := writeTreeChangePatch(w, treeChange{Path: name, Old: treeFiles[name], New: entry}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		fullPath := filepath.FromSlash(name)
+		info, err := os.Lstat(fullPath)
+		if errors.Is(err, os.ErrNotExist) {
+			if old.exists() {
+				writeFileDiff(w, old, diffFile{})
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if hash, ok := unchangedHash(name, info); ok && hash == treeFiles[name].Hash && entry.Mode == old.Mode {
+			continue
+		}
+		mode, content, err := readWorktreeBlob(fullPath)
+		if err != nil {
+			return err
+		}
+		if mode == old.Mode && hashBlob(content) == treeFiles[name].Hash {
+			continue
+		}
+		writeFileDiff(w, old, diffFile{Path: name, Mode: mode, Content: content})
+	}
+	return nil
+}
+
+// diffCommits writes a patch for every file that differs between the
+// trees of two commits.
+func diffCommits(w io.Writer, from, to string, paths []string) error {
+	oldTree, err := peelToTree(from)
+	if err != nil {
+		return err
+	}
+	newTree, err := peelToTree(to)
+	if err != nil {
+		return err
+	}
+	changes, err := diffTrees(oldTree, newTree, "")
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if !matchesPaths(change.Path, paths) {
+			continue
+		}
+		if err := writeTreeChangePatch(w, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headTree returns the tree of the commit HEAD points at, or an empty hash
+// on an unborn branch.
+func headTree() (string, error) {
+	head, err := resolveRef("HEAD")
+	if errors.Is(err, errRefNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return peelToTree(head)
+}
+
+// unionPaths returns the paths present in either map, sorted.
+func unionPaths(a, b map[string]TreeEntry) []string {
+	names := make(map[string]bool)
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
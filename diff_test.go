@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDiffMatchesGit(t *testing.T) {
+	dir := newTestRepo(t)
+	var before, after strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&before, "line %d\n", i)
+		switch {
+		case i%17 == 0:
+			fmt.Fprintf(&after, "changed %d\n", i)
+		case i%23 == 0:
+		default:
+			fmt.Fprintf(&after, "line %d\n", i)
+		}
+		if i%31 == 0 {
+			fmt.Fprintf(&after, "added %d\n", i)
+		}
+	}
+	commitFile(t, dir, "f", before.String(), "add f")
+	commitFile(t, dir, "g", "old\n", "add g")
+	for name, content := range map[string]string{"f": after.String(), "g": "new\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := runGot(t, dir, "diff"), runGit(t, dir, "diff"); got != want {
+		t.Errorf("got diff:\n%s\ngit diff:\n%s", got, want)
+	}
+}
+
+// TestDiffLinesRewrite diffs two large inputs with no line in common,
+// which is the worst case for Myers' algorithm: the edit distance is the
+// sum of their lengths.
+func TestDiffLinesRewrite(t *testing.T) {
+	const n = 5000
+	a, b := make([]string, n), make([]string, n)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d\n", i)
+		b[i] = fmt.Sprintf("new %d\n", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+
+	if len(ops) != 2*n {
+		t.Fatalf("diffLines returned %d ops, want %d", len(ops), 2*n)
+	}
+	for i, op := range ops {
+		want := diffOp{Kind: '-', Line: a[i%n]}
+		if i >= n {
+			want = diffOp{Kind: '+', Line: b[i-n]}
+		}
+		if op != want {
+			t.Fatalf("op %d is %c%q, want %c%q", i, op.Kind, op.Line, want.Kind, want.Line)
+		}
+	}
+	// Keeping the search state for every edit would take gigabytes.
+	if used := after.TotalAlloc - before.TotalAlloc; used > 16<<20 {
+		t.Errorf("diffLines allocated %d bytes for a %d-line rewrite", used, n)
+	}
+}
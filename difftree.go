@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// treeChange is one path that differs between two trees. Old or New has an
+// empty Mode when the path is absent on that side.
+type treeChange struct {
+	Status byte // 'A', 'D' or 'M'
+	Path   string
+	Old    TreeEntry
+	New    TreeEntry
+}
+
+// readTreeEntries reads the tree with the given hash and returns its
+// entries keyed by name. An empty hash is treated as an empty tree.
+func readTreeEntries(hash string) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+	if hash == "" {
+		return entries, nil
+	}
+
+	objectType, content, err := readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "tree" {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", hash, objectType)
+	}
+	list, err := object.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range list {
+		entries[entry.Name] = entry
+	}
+	return entries, nil
+}
+
+// diffTrees compares two trees recursively and returns the changed files
+// in path order. Either hash may be empty to stand for an empty tree.
+func diffTrees(oldHash, newHash, prefix string) ([]treeChange, error) {
+	oldEntries, err := readTreeEntries(oldHash)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := readTreeEntries(newHash)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	// Walk in git's tree order so changes come out in the same order as
+	// git diff-tree.
+	sortKey := func(name string) string {
+		if entry, ok := newEntries[name]; ok {
+			return object.SortKey(entry)
+		}
+		return object.SortKey(oldEntries[name])
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sortKey(sorted[i]) < sortKey(sorted[j])
+	})
+
+	var changes []treeChange
+	for _, name := range sorted {
+		oldEntry, inOld := oldEntries[name]
+		newEntry, inNew := newEntries[name]
+		if inOld && inNew && oldEntry.Hash == newEntry.Hash && oldEntry.Mode == newEntry.Mode {
+			continue
+		}
+		path := prefix + name
+
+		oldTree := inOld && object.IsTreeMode(oldEntry.Mode)
+		newTree := inNew && object.IsTreeMode(newEntry.Mode)
+
+		// Subtrees are descended into; a side that is a file is reported
+		// on its own so that a file replaced by a directory shows up as
+		// a deletion plus additions.
+		var oldSub, newSub string
+		if oldTree {
+			oldSub = oldEntry.Hash
+		}
+		if newTree {
+			newSub = newEntry.Hash
+		}
+		if oldTree || newTree {
+			sub, err := diffTrees(oldSub, newSub, path+"/")
+			if err != nil {
+				return nil, err
+			}
+			if inOld && !oldTree {
+				changes = append(changes, treeChange{Status: 'D', Path: path, Old: oldEntry})
+			}
+			changes = append(changes, sub...)
+			if inNew && !newTree {
+				changes = append(changes, treeChange{Status: 'A', Path: path, New: newEntry})
+			}
+			continue
+		}
+
+		switch {
+		case !inOld:
+			changes = append(changes, treeChange{Status: 'A', Path: path, New: newEntry})
+		case !inNew:
+			changes = append(changes, treeChange{Status: 'D', Path: path, Old: oldEntry})
+		default:
+			changes = append(changes, treeChange{Status: 'M', Path: path, Old: oldEntry, New: newEntry})
+		}
+	}
+	return changes, nil
+}
+
+// blobDiffFile loads one side of a tree change for writeFileDiff.
+func blobDiffFile(path string, entry TreeEntry) (diffFile, error) {
+	if entry.Mode == "" {
+		return diffFile{}, nil
+	}
+	file := diffFile{Path: path, Mode: entry.Mode}
+	if entry.Mode == "160000" {
+		file.Content = []byte(fmt.Sprintf("Subproject commit %s\n", entry.Hash))
+		return file, nil
+	}
+	_, content, err := readObject(entry.Hash)
+	if err != nil {
+		return diffFile{}, err
+	}
+	file.Content = content
+	return file, nil
+}
+
+func writeTreeChangePatch(w io.Writer, change treeChange) error {
+	a, err := blobDiffFile(change.Path, change.Old)
+	if err != nil {
+		return err
+	}
+	b, err := blobDiffFile(change.Path, change.New)
+	if err != nil {
+		return err
+	}
+	writeFileDiff(w, a, b)
+	return nil
+}
+
+// peelToTree returns the tree for hash, which may name a tree, a commit or
+// a tag pointing at either.
+func peelToTree(hash string) (string, error) {
+	return peelToType(hash, "tree")
+}
+
+func runDiffTree(args []string) {
+	patch := false
+	var trees []string
+	for _, arg := range args {
+		if arg == "-p" || arg == "--patch" {
+			patch = true
+		} else {
+			trees = append(trees, arg)
+		}
+	}
+	if len(trees) != 2 {
+		handleError(errors.New("usage: got diff-tree [-p] <tree-a> <tree-b>"))
+	}
+
+	var resolved [2]string
+	for i, name := range trees {
+		hash, err := resolveRevision(name)
+		if err != nil {
+			handleError(err)
+		}
+		if resolved[i], err = peelToTree(hash); err != nil {
+			handleError(err)
+		}
+	}
+	oldTree, newTree := resolved[0], resolved[1]
+
+	changes, err := diffTrees(oldTree, newTree, "")
+	if err != nil {
+		handleError(err)
+	}
+	for _, change := range changes {
+		if patch {
+			if err := writeTreeChangePatch(os.Stdout, change); err != nil {
+				handleError(err)
+			}
+		} else {
+			fmt.Printf("%c %s\n", change.Status, change.Path)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// refUpdate is a local ref that a fetch moves to what the remote has.
+type refUpdate struct {
+	src, dst string
+	old, new string
+	force    bool
+}
+
+func runFetch(args []string) {
+	usage := errors.New("usage: got fetch [-q] [-p] [--depth <n> | --deepen <n> | --unshallow] [--filter=<filter-spec>] [<remote>]")
+	var quiet, prune, unshallow bool
+	var d deepen
+	var name, filter string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "-p" || arg == "--prune":
+			prune = true
+		case arg == "--depth" || arg == "--deepen" || strings.HasPrefix(arg, "--depth=") || strings.HasPrefix(arg, "--deepen="):
+			option, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				if i+1 >= len(args) {
+					handleError(fmt.Errorf("option %s requires a value", arg))
+				}
+				i++
+				value = args[i]
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				handleError(fmt.Errorf("depth %s is not a positive number", value))
+			}
+			d = deepen{depth: n, relative: option == "--deepen"}
+		case arg == "--unshallow":
+			unshallow = true
+		case strings.HasPrefix(arg, "--filter="):
+			spec, err := parseFilter(strings.TrimPrefix(arg, "--filter="))
+			if err != nil {
+				handleError(err)
+			}
+			filter = spec
+		case name == "" && !strings.HasPrefix(arg, "-"):
+			name = arg
+		default:
+			handleError(usage)
+		}
+	}
+	if unshallow {
+		if d.depth > 0 {
+			handleError(errors.New("--depth and --unshallow cannot be used together"))
+		}
+		if shallow, err := readShallow(); err != nil {
+			handleError(err)
+		} else if len(shallow) == 0 {
+			handleError(errors.New("--unshallow on a complete repository does not make sense"))
+		}
+		d.depth = unshallowDepth
+	}
+
+	repo, err := currentRepo()
+	if err != nil {
+		handleError(err)
+	}
+	if name == "" {
+		name = "origin"
+		if branch, onBranch, _ := currentBranch(); onBranch {
+			if remote, ok := repo.Config.Get("branch."+branch, "remote"); ok && remote != "." {
+				name = remote
+			}
+		}
+	}
+	specs := repo.Config.values[configKey("remote."+name, "fetch")]
+	if len(specs) == 0 {
+		handleError(fmt.Errorf("'%s' does not appear to be a configured remote with fetch refspecs", name))
+	}
+	// Giving a filter makes the remote a promisor, as in git; fetches from
+	// one go on filtering as they started.
+	if filter != "" {
+		if err := registerPromisor(name, filter); err != nil {
+			handleError(err)
+		}
+	} else if promisor, _ := repo.Config.Get("extensions", "partialClone"); promisor == name {
+		if spec, ok := repo.Config.Get("remote."+name, "partialclonefilter"); ok {
+			if filter, err = parseFilter(spec); err != nil {
+				handleError(err)
+			}
+		}
+	}
+	url := remoteURL(repo, name)
+	remote, err := openRemote(url)
+	if err != nil {
+		handleError(err)
+	}
+
+	action := strings.Join(append([]string{"fetch"}, args...), " ")
+	rejected, err := fetchRemote(remote, url, specs, d, filter, prune, quiet, action)
+	if err != nil {
+		handleError(err)
+	}
+	if rejected {
+		exit(1)
+	}
+}
+
+// fetchRemote downloads what the refs matched by specs need and updates
+// the local refs they map to. Tags that point into the fetched history are
+// created too. A depth in d deepens or cuts the history fetched, and a
+// filter leaves out blobs, as in fetchPack. Reflog entries name action as
+// the command that made them. It reports whether any update was rejected.
+func fetchRemote(remote Transport, url string, specs []string, d deepen, filter string, prune, quiet bool, action string) (bool, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return false, err
+	}
+	adv, err := remote.discoverRefs("git-upload-pack", protocolVersion())
+	if err != nil {
+		return false, err
+	}
+	defer remote.close()
+	// Only the refs the refspecs can match are asked for, and tags, which
+	// are followed.
+	prefixes := []string{"refs/tags/"}
+	for _, spec := range specs {
+		src, _, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+		prefix, _, _ := strings.Cut(src, "*")
+		prefixes = append(prefixes, prefix)
+	}
+	if adv.Refs, err = listRemoteRefs(remote, adv, prefixes); err != nil {
+		return false, err
+	}
+
+	var updates []refUpdate
+	for _, ref := range adv.Refs {
+		for _, spec := range specs {
+			if dst, ok := mapRefspec(spec, ref.Name); ok {
+				updates = append(updates, refUpdate{src: ref.Name, dst: dst, new: ref.Hash, force: strings.HasPrefix(spec, "+")})
+				break
+			}
+		}
+	}
+
+	var wants []string
+	wanted := make(map[string]bool)
+	// Changing the depth needs history below tips we may already have.
+	want := func(hash string) {
+		if !wanted[hash] && (d.depth > 0 || !repo.ObjectExists(hash)) {
+			wanted[hash] = true
+			wants = append(wants, hash)
+		}
+	}
+	for _, u := range updates {
+		want(u.new)
+	}
+	// Tags on commits we already have are not sent by include-tag, so ask
+	// for them directly.
+	for _, ref := range adv.Refs {
+		if strings.HasPrefix(ref.Name, "refs/tags/") && ref.Peeled != "" && repo.ObjectExists(ref.Peeled) {
+			if exists, _ := refExists(ref.Name); !exists {
+				want(ref.Hash)
+			}
+		}
+	}
+
+	if len(wants) > 0 {
+		haves, err := fetchHaves()
+		if err != nil {
+			return false, err
+		}
+		pack, shallow, err := fetchPack(remote, adv, wants, haves, d, filter, progressWriter(quiet))
+		if err != nil {
+			return false, err
+		}
+		packPath, err := storePack(pack)
+		if err != nil {
+			return false, err
+		}
+		if filter != "" {
+			if err := markPromisorPack(packPath); err != nil {
+				return false, err
+			}
+		}
+		if err := shallow.apply(); err != nil {
+			return false, err
+		}
+	}
+
+	for _, ref := range adv.Refs {
+		if !strings.HasPrefix(ref.Name, "refs/tags/") || strings.HasSuffix(ref.Name, "^{}") {
+			continue
+		}
+		exists, err := refExists(ref.Name)
+		if err != nil {
+			return false, err
+		}
+		if !exists && repo.ObjectExists(ref.Hash) {
+			updates = append(updates, refUpdate{src: ref.Name, dst: ref.Name, new: ref.Hash})
+		}
+	}
+
+	var out strings.Builder
+	rejected := false
+	for _, u := range updates {
+		old, err := resolveRef(u.dst)
+		if err != nil && !errors.Is(err, errRefNotFound) {
+			return false, err
+		}
+		u.old = old
+		if u.old == u.new {
+			continue
+		}
+		flag, summary, ok, err := describeUpdate(u)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintf(&out, " %c %-17s %-10s -> %s\n", flag, summary, shortRefName(u.src), shortRefName(u.dst))
+		if !ok {
+			rejected = true
+			continue
+		}
+		if err := writeRef(u.dst, u.new); err != nil {
+			return false, err
+		}
+		reason := "fast-forward"
+		switch flag {
+		case '*':
+			reason = "storing head"
+		case '+':
+			reason = "forced-update"
+		}
+		if err := appendReflog(u.dst, u.old, u.new, action+": "+reason); err != nil {
+			return false, err
+		}
+	}
+
+	if prune {
+		if err := pruneTrackingRefs(adv, specs, &out); err != nil {
+			return false, err
+		}
+	}
+
+	if out.Len() > 0 && !quiet {
+		fmt.Fprintf(os.Stderr, "From %s\n%s", url, out.String())
+	}
+	return rejected, nil
+}
+
+// describeUpdate returns the flag and summary git prints for a ref update,
+// and whether the update is allowed: new refs and fast-forwards always
+// are, anything else only when forced. Existing tags are never moved.
+func describeUpdate(u refUpdate) (byte, string, bool, error) {
+	if u.old == "" {
+		if strings.HasPrefix(u.dst, "refs/tags/") {
+			return '*', "[new tag]", true, nil
+		}
+		return '*', "[new branch]", true, nil
+	}
+	if strings.HasPrefix(u.dst, "refs/tags/") && !u.force {
+		return '!', "[rejected]", false, nil
+	}
+	reachable, err := ancestors(u.new)
+	if err != nil {
+		return 0, "", false, err
+	}
+	switch {
+	case reachable[u.old]:
+		return ' ', u.old[:7] + ".." + u.new[:7], true, nil
+	case u.force:
+		return '+', u.old[:7] + "..." + u.new[:7], true, nil
+	}
+	return '!', "[rejected]", false, nil
+}
+
+// pruneTrackingRefs deletes local refs that a refspec maps a remote ref
+// to, when that remote ref no longer exists.
+func pruneTrackingRefs(adv *refAdvertisement, specs []string, out io.Writer) error {
+	refs, err := listRefs()
+	if err != nil {
+		return err
+	}
+	for _, local := range refs {
+		// Symbolic refs such as refs/remotes/origin/HEAD are left alone.
+		if value, err := readRefFile(local); err == nil && strings.HasPrefix(value, "ref: ") {
+			continue
+		}
+		for _, spec := range specs {
+			src, dst, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+			remoteName, ok := mapRefspec(dst+":"+src, local)
+			if !ok {
+				continue
+			}
+			if _, exists := adv.lookup(remoteName); !exists {
+				if err := deleteRef(local); err != nil {
+					return err
+				}
+				fmt.Fprintf(out, " - %-17s %-10s -> %s\n", "[deleted]", "(none)", shortRefName(local))
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// fetchHaves lists commits we have, for the server to find what we have in
+// common: the tip of every ref and some of their recent history.
+func fetchHaves() ([]string, error) {
+	refs, err := listRefs()
+	if err != nil {
+		return nil, err
+	}
+	var haves []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		hash, err := resolveRef(ref)
+		if err != nil {
+			continue
+		}
+		if hash, err = peelToCommit(hash); err != nil {
+			continue
+		}
+		walked := 0
+		err = walkCommits(hash, func(c *logCommit) bool {
+			if seen[c.Hash] || walked >= 32 || len(haves) >= 256 {
+				return false
+			}
+			seen[c.Hash] = true
+			haves = append(haves, c.Hash)
+			walked++
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return haves, nil
+}
+
+// shortRefName drops the refs/heads/, refs/tags/ or refs/remotes/ prefix
+// for display.
+func shortRefName(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			return name
+		}
+	}
+	return ref
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchDepth(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"depth attached", []string{"--depth=3"}, "3"},
+		{"depth separate", []string{"--depth", "3"}, "3"},
+		{"deepen attached", []string{"--deepen=1"}, "2"},
+		{"deepen separate", []string{"--deepen", "1"}, "2"},
+		{"deepen before remote", []string{"-q", "--deepen", "2", "origin"}, "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := newTestRemote(t)
+			src := newTestRepo(t)
+			for _, name := range []string{"one", "two", "three", "four"} {
+				commitFile(t, src, name, name+"\n", name)
+			}
+			runGit(t, src, "push", "-q", url, "main")
+
+			parent := t.TempDir()
+			runGot(t, parent, "clone", "-q", "--depth", "1", url, "clone")
+			dir := filepath.Join(parent, "clone")
+			runGot(t, dir, append([]string{"fetch"}, tt.args...)...)
+			if got := strings.TrimSpace(runGit(t, dir, "rev-list", "--count", "origin/main")); got != tt.want {
+				t.Errorf("fetch %s left %s commits, want %s", strings.Join(tt.args, " "), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchDepthMissingValue(t *testing.T) {
+	dir := newTestRepo(t)
+	for _, option := range []string{"--depth", "--deepen"} {
+		_, err := runCommand(dir, gotBinary, "fetch", option)
+		if err == nil || !strings.Contains(err.Error(), "requires a value") {
+			t.Errorf("fetch %s: %v, want a missing value error", option, err)
+		}
+	}
+}
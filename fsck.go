@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// fsckObject checks the loose object stored at path and returns its type
+// and the hashes of the objects it references.
+func fsckObject(hash, path string) (string, map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	objectType, content, err := object.Inflate(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if actual := object.Hash(objectType, content); actual != hash {
+		return "", nil, fmt.Errorf("hash mismatch, content hashes to %s", actual)
+	}
+	if err := checkObjectFormat(objectType, content); err != nil {
+		return "", nil, err
+	}
+	refs, err := objectReferences(hash, objectType, content)
+	return objectType, refs, err
+}
+
+// checkObjectFormat checks the parts of an object that parsing it
+// tolerates: the canonical form of trees, and the headers of commits and
+// tags, which must come in git's order with well-formed values.
+func checkObjectFormat(objectType string, content []byte) error {
+	switch objectType {
+	case "tree":
+		return object.VerifyTree(content)
+	case "commit":
+		return checkHeaders(content, []string{"tree", "parent*", "author", "committer"})
+	case "tag":
+		return checkHeaders(content, []string{"object", "type", "tag", "tagger?"})
+	}
+	return nil
+}
+
+// checkHeaders checks that an object's header lines start with the given
+// keys in order. A key ending in "*" may repeat or be absent, and one
+// ending in "?" may be absent. Later headers, such as gpgsig or
+// encoding, are not checked.
+func checkHeaders(content []byte, keys []string) error {
+	header, _, found := strings.Cut(string(content), "\n\n")
+	if !found && !strings.HasSuffix(string(content), "\n") {
+		return errors.New("unterminated header")
+	}
+	lines := strings.Split(header, "\n")
+	for _, key := range keys {
+		name := strings.TrimRight(key, "*?")
+		for {
+			var value string
+			present := false
+			if len(lines) > 0 {
+				value, present = strings.CutPrefix(lines[0], name+" ")
+			}
+			if !present {
+				if name == key {
+					return fmt.Errorf("missing %s header", name)
+				}
+				break
+			}
+			lines = lines[1:]
+			if err := checkHeaderValue(name, value); err != nil {
+				return err
+			}
+			if !strings.HasSuffix(key, "*") {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// checkHeaderValue checks one header value of a commit or tag.
+func checkHeaderValue(name, value string) error {
+	switch name {
+	case "tree", "parent", "object":
+		if !isFullHash(value) {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+	case "type":
+		if !isKnownType(value) {
+			return fmt.Errorf("invalid type %q", value)
+		}
+	case "author", "committer", "tagger":
+		if _, err := parseSignature(value); err != nil {
+			return fmt.Errorf("bad %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// objectReferences returns the hashes an object points at, keyed by the
+// type they are expected to be. Commits on the shallow boundary reference
+// no parents.
+func objectReferences(hash, objectType string, content []byte) (map[string][]string, error) {
+	refs := make(map[string][]string)
+	switch objectType {
+	case "blob":
+	case "tree":
+		entries, err := object.ParseTree(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch entry.Mode {
+			case "160000":
+				// Gitlinks point into another repository.
+			case "40000", "040000":
+				refs["tree"] = append(refs["tree"], entry.Hash)
+			default:
+				refs["blob"] = append(refs["blob"], entry.Hash)
+			}
+		}
+	case "commit":
+		commit, err := object.ParseCommit(content)
+		if err != nil {
+			return nil, err
+		}
+		refs["tree"] = append(refs["tree"], commit.Tree)
+		if !isShallow(hash) {
+			refs["commit"] = append(refs["commit"], commit.Parents...)
+		}
+	case "tag":
+		for _, line := range strings.Split(string(content), "\n") {
+			if target, found := strings.CutPrefix(line, "object "); found {
+				refs["object"] = append(refs["object"], target)
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown object type %q", objectType)
+	}
+	return refs, nil
+}
+
+// looseObjects returns the hashes of all loose objects mapped to their paths.
+func looseObjects() (map[string]string, error) {
+	dirs, err := os.ReadDir(commonPath("objects"))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]string)
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(commonPath("objects", dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if len(file.Name()) != 38 {
+				continue
+			}
+			objects[dir.Name()+file.Name()] = commonPath("objects", dir.Name(), file.Name())
+		}
+	}
+	return objects, nil
+}
+
+func runFsck(args []string) {
+	unreachable, dangling, reflogs := false, true, true
+	for _, arg := range args {
+		switch arg {
+		case "--unreachable":
+			unreachable = true
+		case "--no-dangling":
+			dangling = false
+		case "--no-reflogs":
+			reflogs = false
+		default:
+			handleError(errors.New("usage: got fsck [--unreachable] [--no-dangling] [--no-reflogs]"))
+		}
+	}
+
+	objects, err := looseObjects()
+	if err != nil {
+		handleError(err)
+	}
+
+	hashes := make([]string, 0, len(objects))
+	for hash := range objects {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	problems := 0
+	known := make(map[string]bool, len(objects))
+	types := make(map[string]string, len(objects))
+	references := make(map[string]map[string][]string)
+	for _, hash := range hashes {
+		known[hash] = true
+		objectType, refs, err := fsckObject(hash, objects[hash])
+		if err != nil {
+			fmt.Printf("error in object %s: %s\n", hash, err)
+			problems++
+			continue
+		}
+		types[hash] = objectType
+		references[hash] = refs
+	}
+
+	repo, err := currentRepo()
+	if err != nil {
+		handleError(err)
+	}
+	packs, err := repo.Packs()
+	if err != nil {
+		handleError(err)
+	}
+	repo.VerifyCRC = true
+	// Objects from a partial clone's promisor remote may reference objects
+	// it left out, which it can send later.
+	promised := make(map[string]bool)
+	for _, p := range packs {
+		isPromisor := isPromisorPack(p)
+		for i := 0; i < p.Count(); i++ {
+			known[p.HashAt(i)] = true
+			if isPromisor {
+				promised[p.HashAt(i)] = true
+			}
+		}
+		errs := verifyPack(p, func(i int, objectType string, content []byte) {
+			hash := p.HashAt(i)
+			err := checkObjectFormat(objectType, content)
+			var refs map[string][]string
+			if err == nil {
+				refs, err = objectReferences(hash, objectType, content)
+			}
+			if err != nil {
+				fmt.Printf("error in object %s: %s\n", hash, err)
+				problems++
+				return
+			}
+			types[hash] = objectType
+			references[hash] = refs
+		})
+		for _, err := range errs {
+			fmt.Printf("error: %s\n", err)
+			problems++
+		}
+	}
+
+	sources := make([]string, 0, len(references))
+	for hash := range references {
+		sources = append(sources, hash)
+	}
+	sort.Strings(sources)
+	for _, hash := range sources {
+		for refType, targets := range references[hash] {
+			for _, target := range targets {
+				if !known[target] && !promised[hash] {
+					fmt.Printf("missing %s %s (referenced by %s)\n", refType, target, hash)
+					problems++
+				}
+			}
+		}
+	}
+
+	refs, err := listRefs()
+	if err != nil {
+		handleError(err)
+	}
+	for _, ref := range append(refs, "HEAD") {
+		hash, err := resolveRef(ref)
+		if errors.Is(err, errRefNotFound) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("error: %s: %s\n", ref, err)
+			problems++
+			continue
+		}
+		if !known[hash] {
+			fmt.Printf("error: %s: invalid sha1 pointer %s\n", ref, hash)
+			problems++
+		}
+	}
+
+	if unreachable || dangling {
+		roots, blobs, err := reachabilityRoots(reflogs)
+		if err != nil {
+			handleError(err)
+		}
+		reachable := reachableFrom(append(roots, blobs...), references)
+		reportUnreachable(types, references, reachable, unreachable)
+	}
+
+	if problems > 0 {
+		exit(1)
+	}
+}
+
+// reachableFrom returns the objects reachable from roots by following
+// references, which holds the references of every object that was read.
+func reachableFrom(roots []string, references map[string]map[string][]string) map[string]bool {
+	reachable := make(map[string]bool)
+	pending := roots
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if hash == zeroHash || reachable[hash] {
+			continue
+		}
+		reachable[hash] = true
+		for _, targets := range references[hash] {
+			pending = append(pending, targets...)
+		}
+	}
+	return reachable
+}
+
+// reportUnreachable prints the objects that nothing reachable refers to.
+// By default only dangling ones are listed, those that no other
+// unreachable object refers to either; with all set, every unreachable
+// object is.
+func reportUnreachable(types map[string]string, references map[string]map[string][]string, reachable map[string]bool, all bool) {
+	referenced := make(map[string]bool)
+	for hash, refs := range references {
+		if reachable[hash] {
+			continue
+		}
+		for _, targets := range refs {
+			for _, target := range targets {
+				referenced[target] = true
+			}
+		}
+	}
+
+	hashes := make([]string, 0, len(types))
+	for hash := range types {
+		if !reachable[hash] {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		switch {
+		case all:
+			fmt.Printf("unreachable %s %s\n", types[hash], hash)
+		case !referenced[hash]:
+			fmt.Printf("dangling %s %s\n", types[hash], hash)
+		}
+	}
+}
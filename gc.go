@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piyushyadav1617/got/repo"
+)
+
+// runGc repacks every reachable object in the repository into a single new
+// pack, like "git gc". The loose copies are deleted, as are old packs that
+// hold nothing else. Unreachable loose objects older than the prune expiry
+// (two weeks unless gc.pruneExpire says otherwise) are then removed.
+func runGc(args []string) {
+	repo, err := currentRepo()
+	if err != nil {
+		handleError(err)
+	}
+	pruneExpire, ok := repo.Config.Get("gc", "pruneExpire")
+	if !ok {
+		pruneExpire = "2.weeks.ago"
+	}
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--prune="):
+			pruneExpire = strings.TrimPrefix(arg, "--prune=")
+		case arg == "--no-prune":
+			pruneExpire = "never"
+		default:
+			handleError(errors.New("usage: got gc [--prune=<date> | --no-prune]"))
+		}
+	}
+	expire, err := parseExpiry(pruneExpire, time.Now())
+	if err != nil {
+		handleError(err)
+	}
+
+	reachable, err := reachableObjects()
+	if err != nil {
+		handleError(err)
+	}
+	objects, err := looseObjects()
+	if err != nil {
+		handleError(err)
+	}
+	packs, err := localPacks()
+	if err != nil {
+		handleError(err)
+	}
+
+	// Only objects stored in this repository are packed; those found
+	// through alternates stay where they are.
+	local := make(map[string]bool)
+	for hash := range objects {
+		local[hash] = true
+	}
+	for _, p := range packs {
+		for i := 0; i < p.Count(); i++ {
+			local[p.HashAt(i)] = true
+		}
+	}
+	var hashes []string
+	for hash := range reachable {
+		if local[hash] {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+
+	if len(hashes) > 0 {
+		packPath, err := writePack(hashes)
+		if err != nil {
+			handleError(err)
+		}
+		// The new pack holds what promisor packs did, so it takes over
+		// their marking.
+		promisor := false
+		for _, p := range packs {
+			p.Close()
+			if p.Path() == packPath || !allReachable(p, reachable) {
+				continue
+			}
+			if isPromisorPack(p) {
+				promisor = true
+				if err := os.Remove(strings.TrimSuffix(p.Path(), ".pack") + ".promisor"); err != nil {
+					handleError(err)
+				}
+			}
+			for _, path := range []string{p.Path(), strings.TrimSuffix(p.Path(), ".pack") + ".idx"} {
+				if err := os.Remove(path); err != nil {
+					handleError(err)
+				}
+			}
+		}
+		if promisor {
+			if err := markPromisorPack(packPath); err != nil {
+				handleError(err)
+			}
+		}
+	}
+
+	for _, hash := range hashes {
+		path, ok := objects[hash]
+		if !ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			handleError(err)
+		}
+		// Fan-out directories left empty are removed too; Remove fails
+		// harmlessly on one that still has objects in it.
+		os.Remove(filepath.Dir(path))
+	}
+
+	if pruneExpire != "never" {
+		if err := pruneLooseObjects(reachable, &expire, false, false); err != nil {
+			handleError(err)
+		}
+	}
+}
+
+// localPacks opens the packs in .git/objects/pack, leaving out those of
+// alternates.
+func localPacks() ([]*repo.Pack, error) {
+	idxPaths, err := filepath.Glob(commonPath("objects", "pack", "pack-*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	var packs []*repo.Pack
+	for _, idxPath := range idxPaths {
+		p, err := openPack(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, p)
+	}
+	return packs, nil
+}
+
+// allReachable reports whether every object in p is reachable, so that
+// nothing is lost when p is deleted.
+func allReachable(p *repo.Pack, reachable map[string]bool) bool {
+	for i := 0; i < p.Count(); i++ {
+		if !reachable[p.HashAt(i)] {
+			return false
+		}
+	}
+	return true
+}
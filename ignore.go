@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is one line of a .gitignore file.
+type ignorePattern struct {
+	base    string // directory of the file it came from, "" for the top
+	negate  bool   // "!pattern" re-includes what earlier lines excluded
+	dirOnly bool   // "pattern/" matches only directories
+	// anchored patterns contain a slash and match the path relative to
+	// base; the rest match the last path component at any depth.
+	anchored bool
+	re       *regexp.Regexp
+}
+
+// ignoreMatcher decides which untracked paths are ignored, from the global
+// excludes file, .git/info/exclude and the .gitignore file of every
+// directory, which is read the first time a path below it is checked.
+type ignoreMatcher struct {
+	patterns []ignorePattern
+	loaded   map[string]bool
+}
+
+// newIgnoreMatcher reads the excludes files that apply to the whole
+// repository. Per-directory .gitignore files are read as needed.
+func newIgnoreMatcher() (*ignoreMatcher, error) {
+	m := &ignoreMatcher{loaded: make(map[string]bool)}
+	repo, err := currentRepo()
+	if err != nil {
+		return nil, err
+	}
+	global, ok := repo.Config.Get("core", "excludesFile")
+	if ok {
+		if rest, found := strings.CutPrefix(global, "~/"); found {
+			home, _ := os.UserHomeDir()
+			global = filepath.Join(home, rest)
+		}
+	} else if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		global = filepath.Join(dir, "git", "ignore")
+	} else if home, err := os.UserHomeDir(); err == nil {
+		global = filepath.Join(home, ".config", "git", "ignore")
+	}
+	for _, file := range []string{global, commonPath("info", "exclude")} {
+		if file == "" {
+			continue
+		}
+		if err := m.readFile(file, ""); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// readFile appends the patterns of an ignore file whose paths are relative
+// to base. A missing file has no patterns.
+func (m *ignoreMatcher) readFile(file, base string) error {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseIgnorePattern(scanner.Text(), base); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return scanner.Err()
+}
+
+// load reads the .gitignore of dir and of every directory above it that
+// has not been read yet, top down so that deeper files take precedence.
+func (m *ignoreMatcher) load(dir string) error {
+	if m.loaded[dir] {
+		return nil
+	}
+	if dir != "" {
+		parent := path.Dir(dir)
+		if parent == "." {
+			parent = ""
+		}
+		if err := m.load(parent); err != nil {
+			return err
+		}
+	}
+	m.loaded[dir] = true
+	return m.readFile(filepath.Join(filepath.FromSlash(dir), ".gitignore"), dir)
+}
+
+// ignored reports whether the slash-separated path, relative to the top
+// of the working tree, is ignored. Anything inside an ignored directory is
+// ignored too, since git never looks inside one.
+func (m *ignoreMatcher) ignored(name string, isDir bool) (bool, error) {
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return false, nil
+	}
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		sub := strings.Join(parts[:i+1], "/")
+		dir := strings.Join(parts[:i], "/")
+		if err := m.load(dir); err != nil {
+			return false, err
+		}
+		if m.match(sub, i < len(parts)-1 || isDir) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// match applies the patterns to one path; the last one that matches
+// decides.
+func (m *ignoreMatcher) match(name string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		rel := name
+		if p.base != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(name, p.base+"/"); !ok {
+				continue
+			}
+		}
+		if !p.anchored {
+			rel = path.Base(rel)
+		}
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnorePattern parses one line of an ignore file. Blank lines and
+// comments yield no pattern.
+func parseIgnorePattern(line, base string) (ignorePattern, bool) {
+	p := ignorePattern{base: base}
+	// Trailing spaces are dropped unless escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	re, err := regexp.Compile("^" + wildmatchRegexp(line) + "$")
+	if err != nil {
+		return p, false
+	}
+	p.re = re
+	return p, true
+}
+
+// wildmatchRegexp translates a gitignore glob to a regular expression: "*"
+// and "?" stay within one path component, "**" spans directories, and
+// "[...]" is a character class.
+func wildmatchRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			// Leading or inner "**/": zero or more directories.
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && i > 0 && glob[i-1] == '/':
+			// Trailing "/**": everything inside.
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+			// A "]" right after "[" or "[!" is part of the class.
+			if end == 0 || (end == 1 && glob[i+1] == '!') {
+				if next := strings.IndexByte(glob[i+end+2:], ']'); next != -1 {
+					end += next + 1
+				}
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
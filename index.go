@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// indexPath returns the path of the index file.
+func indexPath() string {
+	return gitPath("index")
+}
+
+const (
+	indexFlagExtended = 0x4000
+	indexFlagStage    = 0x3000
+	indexNameMask     = 0x0fff
+)
+
+// IndexEntry is one path in .git/index together with the stat data git
+// uses to tell whether the working tree copy has changed.
+type IndexEntry struct {
+	CtimeSec  uint32
+	CtimeNsec uint32
+	MtimeSec  uint32
+	MtimeNsec uint32
+	Dev       uint32
+	Ino       uint32
+	Mode      uint32
+	Uid       uint32
+	Gid       uint32
+	Size      uint32
+	Hash      string
+	// Flags holds the assume-valid and stage bits; the name length is
+	// recomputed when the index is written.
+	Flags         uint16
+	ExtendedFlags uint16
+	Path          string
+}
+
+func (e *IndexEntry) Stage() int {
+	return int(e.Flags&indexFlagStage) >> 12
+}
+
+// ModeString returns the entry mode in the octal form used in trees.
+func (e *IndexEntry) ModeString() string {
+	return strconv.FormatUint(uint64(e.Mode), 8)
+}
+
+// Index is the parsed staging area. Entries are kept sorted by path and
+// stage, as git requires.
+type Index struct {
+	Version uint32
+	Entries []*IndexEntry
+
+	// cacheTrees holds the tree hashes recorded in the cached tree
+	// extension, which git counts as reachable. The extension itself is
+	// not written back.
+	cacheTrees []string
+}
+
+// readIndex parses .git/index. A missing index is returned as empty.
+func readIndex() (*Index, error) {
+	data, err := os.ReadFile(indexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &Index{Version: 2}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseIndex(data)
+}
+
+func parseIndex(data []byte) (*Index, error) {
+	if len(data) < 12+20 || !bytes.Equal(data[:4], []byte("DIRC")) {
+		return nil, errors.New("index: bad signature")
+	}
+	body, checksum := data[:len(data)-20], data[len(data)-20:]
+	if sum := sha1.Sum(body); !bytes.Equal(sum[:], checksum) {
+		return nil, errors.New("index: checksum mismatch")
+	}
+
+	idx := &Index{Version: binary.BigEndian.Uint32(data[4:8])}
+	if idx.Version != 2 && idx.Version != 3 {
+		return nil, fmt.Errorf("index: unsupported version %d", idx.Version)
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	pos := 12
+	for i := uint32(0); i < count; i++ {
+		if pos+62 > len(body) {
+			return nil, errors.New("index: truncated entry")
+		}
+		field := func(n int) uint32 {
+			return binary.BigEndian.Uint32(body[pos+n*4:])
+		}
+		entry := &IndexEntry{
+			CtimeSec:  field(0),
+			CtimeNsec: field(1),
+			MtimeSec:  field(2),
+			MtimeNsec: field(3),
+			Dev:       field(4),
+			Ino:       field(5),
+			Mode:      field(6),
+			Uid:       field(7),
+			Gid:       field(8),
+			Size:      field(9),
+			Hash:      hex.EncodeToString(body[pos+40 : pos+60]),
+		}
+		flags := binary.BigEndian.Uint16(body[pos+60:])
+		entry.Flags = flags &^ indexNameMask &^ indexFlagExtended
+		start := pos
+		pos += 62
+
+		if flags&indexFlagExtended != 0 {
+			if idx.Version < 3 {
+				return nil, errors.New("index: extended flags in a version 2 index")
+			}
+			if pos+2 > len(body) {
+				return nil, errors.New("index: truncated entry")
+			}
+			entry.ExtendedFlags = binary.BigEndian.Uint16(body[pos:])
+			pos += 2
+		}
+
+		nul := bytes.IndexByte(body[pos:], 0)
+		if nul == -1 {
+			return nil, errors.New("index: unterminated path")
+		}
+		entry.Path = string(body[pos : pos+nul])
+		pos += nul
+
+		// Entries are padded with 1-8 NUL bytes to a multiple of 8.
+		pos = start + (pos-start+8)&^7
+		idx.Entries = append(idx.Entries, entry)
+	}
+
+	// Extensions follow the entries. Optional ones (uppercase signature)
+	// such as the cached tree are dropped; they are rebuilt by git.
+	for pos+8 <= len(body) {
+		sig := body[pos : pos+4]
+		size := int(binary.BigEndian.Uint32(body[pos+4:]))
+		if sig[0] < 'A' || sig[0] > 'Z' {
+			return nil, fmt.Errorf("index: unsupported extension %q", sig)
+		}
+		if pos+8+size > len(body) {
+			return nil, errors.New("index: truncated extension")
+		}
+		if string(sig) == "TREE" {
+			idx.cacheTrees = parseCacheTree(body[pos+8 : pos+8+size])
+		}
+		pos += 8 + size
+	}
+	return idx, nil
+}
+
+// parseCacheTree returns the tree hashes of the valid entries in a TREE
+// extension. Each entry is "<path>\0<entry count> <subtrees>\n" followed
+// by the tree hash, which is omitted when the count is -1 (invalidated).
+func parseCacheTree(data []byte) []string {
+	var trees []string
+	for len(data) > 0 {
+		nul := bytes.IndexByte(data, 0)
+		if nul == -1 {
+			break
+		}
+		data = data[nul+1:]
+		newline := bytes.IndexByte(data, '\n')
+		if newline == -1 {
+			break
+		}
+		count, _, _ := bytes.Cut(data[:newline], []byte(" "))
+		data = data[newline+1:]
+		if string(count) == "-1" {
+			continue
+		}
+		if len(data) < 20 {
+			break
+		}
+		trees = append(trees, hex.EncodeToString(data[:20]))
+		data = data[20:]
+	}
+	return trees
+}
+
+func (idx *Index) sort() {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		a, b := idx.Entries[i], idx.Entries[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Stage() < b.Stage()
+	})
+}
+
+// write serializes the index to .git/index through a lock file.
+func (idx *Index) write() error {
+	idx.sort()
+
+	version := uint32(2)
+	for _, entry := range idx.Entries {
+		if entry.ExtendedFlags != 0 {
+			version = 3
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	for _, entry := range idx.Entries {
+		start := buf.Len()
+		for _, v := range []uint32{
+			entry.CtimeSec, entry.CtimeNsec, entry.MtimeSec, entry.MtimeNsec,
+			entry.Dev, entry.Ino, entry.Mode, entry.Uid, entry.Gid, entry.Size,
+		} {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+		hash, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(hash) != 20 {
+			return fmt.Errorf("index: bad hash for %s", entry.Path)
+		}
+		buf.Write(hash)
+
+		flags := entry.Flags &^ indexNameMask &^ indexFlagExtended
+		if len(entry.Path) < indexNameMask {
+			flags |= uint16(len(entry.Path))
+		} else {
+			flags |= indexNameMask
+		}
+		if entry.ExtendedFlags != 0 {
+			flags |= indexFlagExtended
+		}
+		binary.Write(&buf, binary.BigEndian, flags)
+		if entry.ExtendedFlags != 0 {
+			binary.Write(&buf, binary.BigEndian, entry.ExtendedFlags)
+		}
+
+		buf.WriteString(entry.Path)
+		padding := 8 - (buf.Len()-start)%8
+		buf.Write(make([]byte, padding))
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	lock := indexPath() + ".lock"
+	if err := os.WriteFile(lock, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(lock, indexPath())
+}
+
+// entry returns the stage 0 entry for path, or nil.
+func (idx *Index) entry(path string) *IndexEntry {
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return idx.Entries[i].Path >= path
+	})
+	for ; i < len(idx.Entries) && idx.Entries[i].Path == path; i++ {
+		if idx.Entries[i].Stage() == 0 {
+			return idx.Entries[i]
+		}
+	}
+	return nil
+}
+
+// add inserts entry, replacing any existing entries for its path.
+func (idx *Index) add(entry *IndexEntry) {
+	idx.remove(entry.Path)
+	idx.Entries = append(idx.Entries, entry)
+	idx.sort()
+}
+
+// newIndexEntry returns a stage 0 entry for a blob written to the working
+// tree at path, with its stat data filled in from info.
+func newIndexEntry(path, mode, hash string, info os.FileInfo) (*IndexEntry, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("index: bad mode %s for %s", mode, path)
+	}
+	entry := &IndexEntry{Mode: uint32(m), Hash: hash, Path: path}
+	if info != nil {
+		setStat(entry, info)
+	}
+	return entry, nil
+}
+
+// setStat records the stat data git compares to detect changed files.
+func setStat(entry *IndexEntry, info os.FileInfo) {
+	mtime := info.ModTime()
+	entry.MtimeSec = uint32(mtime.Unix())
+	entry.MtimeNsec = uint32(mtime.Nanosecond())
+	entry.CtimeSec = entry.MtimeSec
+	entry.CtimeNsec = entry.MtimeNsec
+	entry.Size = uint32(info.Size())
+	fileStat(entry, info)
+}
+
+// statMatches reports whether info has the same stat data and file type
+// as recorded in entry, meaning the file can be assumed unchanged.
+func statMatches(entry *IndexEntry, info os.FileInfo) bool {
+	var current IndexEntry
+	setStat(&current, info)
+	return current.MtimeSec == entry.MtimeSec &&
+		current.MtimeNsec == entry.MtimeNsec &&
+		current.CtimeSec == entry.CtimeSec &&
+		current.CtimeNsec == entry.CtimeNsec &&
+		current.Size == entry.Size &&
+		current.Ino == entry.Ino &&
+		current.Dev == entry.Dev &&
+		worktreeMode(info) == entry.ModeString()
+}
+
+// worktreeMode returns the tree mode a file with info would be stored as.
+func worktreeMode(info os.FileInfo) string {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return "120000"
+	case info.Mode()&0111 != 0:
+		return "100755"
+	}
+	return "100644"
+}
+
+// cachedIndex is the index as it was when first needed, with its mtime,
+// for looking up stat data of unchanged files.
+type cachedIndex struct {
+	*Index
+	mtime time.Time
+}
+
+var loadCachedIndex = sync.OnceValues(func() (*cachedIndex, error) {
+	idx, err := readIndex()
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedIndex{Index: idx}
+	if info, err := os.Stat(indexPath()); err == nil {
+		cached.mtime = info.ModTime()
+	}
+	return cached, nil
+})
+
+// unchangedHash returns the staged hash for the file at path when its
+// stat data shows it has not changed since it was staged. Entries written
+// in the same second as the index, or later, are "racily clean" and are
+// never trusted, since the file could have changed again unnoticed.
+func unchangedHash(path string, info os.FileInfo) (string, bool) {
+	idx, err := loadCachedIndex()
+	if err != nil {
+		return "", false
+	}
+	entry := idx.entry(path)
+	if entry == nil || !statMatches(entry, info) {
+		return "", false
+	}
+	if int64(entry.MtimeSec) >= idx.mtime.Unix() {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// remove drops every entry (at any stage) for path and reports whether
+// there were any.
+func (idx *Index) remove(path string) bool {
+	kept := idx.Entries[:0]
+	for _, entry := range idx.Entries {
+		if entry.Path != path {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(kept) != len(idx.Entries)
+	idx.Entries = kept
+	return removed
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// scannedObject is one entry of a pack read front to back, without an
+// index. Deltas are resolved by resolvePack.
+type scannedObject struct {
+	offset uint64
+	crc    uint32
+	header object.PackEntryHeader
+	data   []byte // inflated entry data: the object, or the delta
+
+	objectType string
+	content    []byte
+	hash       string
+}
+
+// scanPack parses a whole pack held in memory, checking its header and
+// trailing checksum, and inflates every entry.
+func scanPack(pack []byte) ([]*scannedObject, error) {
+	if len(pack) < 12+20 || !bytes.Equal(pack[:4], []byte("PACK")) {
+		return nil, errors.New("not a pack file")
+	}
+	if version := binary.BigEndian.Uint32(pack[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	count := binary.BigEndian.Uint32(pack[8:12])
+	body, trailer := pack[:len(pack)-20], pack[len(pack)-20:]
+	if sum := sha1.Sum(body); !bytes.Equal(sum[:], trailer) {
+		return nil, errors.New("pack checksum mismatch")
+	}
+
+	objects := make([]*scannedObject, 0, count)
+	offset := uint64(12)
+	for i := uint32(0); i < count; i++ {
+		if offset >= uint64(len(body)) {
+			return nil, fmt.Errorf("pack is truncated: %d of %d objects", i, count)
+		}
+		r := bytes.NewReader(body[offset:])
+		h, err := object.ReadPackEntryHeader(r, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		// bytes.Reader is an io.ByteReader, so zlib reads exactly up to the
+		// end of the stream and r is left at the next entry.
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("entry at offset %d: %w", offset, err)
+		}
+		data := make([]byte, h.Size)
+		if _, err := io.ReadFull(zr, data); err != nil {
+			return nil, fmt.Errorf("entry at offset %d: %w", offset, err)
+		}
+		if n, err := zr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+			return nil, fmt.Errorf("entry at offset %d: size mismatch or bad checksum", offset)
+		}
+
+		end := uint64(len(body)) - uint64(r.Len())
+		objects = append(objects, &scannedObject{
+			offset: offset,
+			crc:    crc32.ChecksumIEEE(body[offset:end]),
+			header: h,
+			data:   data,
+		})
+		offset = end
+	}
+	if offset != uint64(len(body)) {
+		return nil, errors.New("pack has junk after the last object")
+	}
+	return objects, nil
+}
+
+// resolvePack fills in the type, content and hash of every scanned object,
+// applying deltas. With thin set, a ref-delta base missing from the pack
+// may come from the repository instead.
+func resolvePack(objects []*scannedObject, thin bool) error {
+	byOffset := make(map[uint64]*scannedObject, len(objects))
+	byHash := make(map[string]*scannedObject, len(objects))
+	for _, o := range objects {
+		byOffset[o.offset] = o
+	}
+
+	var resolve func(o *scannedObject, depth int) error
+	resolve = func(o *scannedObject, depth int) error {
+		if o.hash != "" {
+			return nil
+		}
+		if depth > 10000 {
+			return fmt.Errorf("delta chain too deep at offset %d", o.offset)
+		}
+
+		var baseType string
+		var base []byte
+		switch o.header.Type {
+		case object.PackOfsDelta:
+			b, ok := byOffset[o.header.BaseOffset]
+			if !ok {
+				return fmt.Errorf("entry at offset %d: no object at delta base offset %d", o.offset, o.header.BaseOffset)
+			}
+			if err := resolve(b, depth+1); err != nil {
+				return err
+			}
+			baseType, base = b.objectType, b.content
+		case object.PackRefDelta:
+			b, ok := byHash[o.header.BaseHash]
+			if !ok {
+				// The base may be a later entry that is not resolved yet.
+				return errDeferred
+			}
+			baseType, base = b.objectType, b.content
+		default:
+			o.objectType, o.content = object.PackTypeNames[o.header.Type], o.data
+		}
+		if o.content == nil {
+			content, err := object.ApplyDelta(base, o.data)
+			if err != nil {
+				return fmt.Errorf("entry at offset %d: %w", o.offset, err)
+			}
+			o.objectType, o.content = baseType, content
+		}
+		o.hash = object.Hash(o.objectType, o.content)
+		byHash[o.hash] = o
+		return nil
+	}
+
+	// Ref-deltas can name a base that appears later in the pack, so keep
+	// passing over the objects while that makes progress.
+	pending := objects
+	for len(pending) > 0 {
+		var next []*scannedObject
+		for _, o := range pending {
+			err := resolve(o, 0)
+			if errors.Is(err, errDeferred) {
+				next = append(next, o)
+			} else if err != nil {
+				return err
+			}
+		}
+		if len(next) == len(pending) {
+			if !thin {
+				return fmt.Errorf("pack has %d unresolved deltas", len(next))
+			}
+			// Take the first missing base from the repository.
+			o := next[0]
+			baseType, base, err := readObject(o.header.BaseHash)
+			if err != nil {
+				return fmt.Errorf("pack has %d unresolved deltas: base %s: %w", len(next), o.header.BaseHash, err)
+			}
+			byHash[o.header.BaseHash] = &scannedObject{objectType: baseType, content: base, hash: o.header.BaseHash}
+		}
+		pending = next
+	}
+	return nil
+}
+
+var errDeferred = errors.New("delta base not resolved yet")
+
+// fixThinPack completes a thin pack, whose deltas may use bases it does
+// not contain, by appending those bases from the repository, as
+// "git index-pack --fix-thin" does. A pack that is not thin is returned
+// unchanged.
+func fixThinPack(pack []byte) ([]byte, error) {
+	objects, err := scanPack(pack)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolvePack(objects, true); err != nil {
+		return nil, err
+	}
+	inPack := make(map[string]bool, len(objects))
+	for _, o := range objects {
+		inPack[o.hash] = true
+	}
+	var missing []string
+	for _, o := range objects {
+		if base := o.header.BaseHash; o.header.Type == object.PackRefDelta && !inPack[base] {
+			inPack[base] = true
+			missing = append(missing, base)
+		}
+	}
+	if len(missing) == 0 {
+		return pack, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pack[:len(pack)-20])
+	for _, hash := range missing {
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writePackObject(&buf, packTypeCodes[objectType], content); err != nil {
+			return nil, err
+		}
+	}
+	fixed := buf.Bytes()
+	binary.BigEndian.PutUint32(fixed[8:12], uint32(len(objects)+len(missing)))
+	sum := sha1.Sum(fixed)
+	return append(fixed, sum[:]...), nil
+}
+
+// readPackInput reads a pack from the named file, or from stdin for "-" or
+// no name.
+func readPackInput(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func runUnpackObjects(args []string) {
+	quiet := false
+	for _, arg := range args {
+		switch arg {
+		case "-n", "--dry-run":
+			dryRun = true
+		case "-q", "--quiet":
+			quiet = true
+		default:
+			handleError(errors.New("usage: got unpack-objects [-n] [-q] < <pack>"))
+		}
+	}
+
+	pack, err := readPackInput("")
+	if err != nil {
+		handleError(err)
+	}
+	objects, err := scanPack(pack)
+	if err != nil {
+		handleError(err)
+	}
+	if err := resolvePack(objects, true); err != nil {
+		handleError(err)
+	}
+
+	repo, err := currentRepo()
+	if err != nil {
+		handleError(err)
+	}
+	written := 0
+	for _, o := range objects {
+		if repo.ObjectExists(o.hash) {
+			continue
+		}
+		if _, err := writeObject(o.objectType, o.content); err != nil {
+			handleError(err)
+		}
+		written++
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Unpacking objects: 100%% (%d/%d), done.\n", len(objects), len(objects))
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "%d objects would be written.\n", written)
+		}
+	}
+}
+
+func runIndexPack(args []string) {
+	usage := errors.New("usage: got index-pack [-o <index>] (<pack> | --stdin)")
+	var output, packPath string
+	stdin := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-o":
+			if i+1 >= len(args) {
+				handleError(errors.New("option -o requires a value"))
+			}
+			i++
+			output = prefixFilename(args[i])
+		case args[i] == "--stdin":
+			stdin = true
+		case packPath == "" && !strings.HasPrefix(args[i], "-"):
+			packPath = prefixFilename(args[i])
+		default:
+			handleError(usage)
+		}
+	}
+	if stdin == (packPath != "") {
+		handleError(usage)
+	}
+	if packPath != "" && !strings.HasSuffix(packPath, ".pack") {
+		handleError(fmt.Errorf("packfile name '%s' does not end with '.pack'", packPath))
+	}
+
+	pack, err := readPackInput(packPath)
+	if err != nil {
+		handleError(err)
+	}
+	if stdin {
+		// The pack is stored in the repository under its checksum, as
+		// "git index-pack --stdin" does.
+		if _, err := storePack(pack); err != nil {
+			handleError(err)
+		}
+		fmt.Println(hex.EncodeToString(pack[len(pack)-20:]))
+		return
+	}
+	if output == "" {
+		output = strings.TrimSuffix(packPath, ".pack") + ".idx"
+	}
+	if err := indexPack(pack, output); err != nil {
+		handleError(err)
+	}
+	fmt.Println(hex.EncodeToString(pack[len(pack)-20:]))
+}
+
+// indexPack checks a whole pack and writes its .idx to idxPath.
+func indexPack(pack []byte, idxPath string) error {
+	objects, err := scanPack(pack)
+	if err != nil {
+		return err
+	}
+	if err := resolvePack(objects, false); err != nil {
+		return err
+	}
+	entries := make([]packEntry, len(objects))
+	for i, o := range objects {
+		raw, _ := hex.DecodeString(o.hash)
+		entries[i] = packEntry{hash: raw, offset: o.offset, crc: o.crc}
+	}
+	return writePackIndex(idxPath, entries, pack[len(pack)-20:])
+}
+
+// storePack indexes a pack received from elsewhere and installs it with
+// its index under .git/objects/pack, returning the pack's path.
+func storePack(pack []byte) (string, error) {
+	if len(pack) < 12+20 {
+		return "", errors.New("not a pack file")
+	}
+	dir := commonPath("objects", "pack")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(dir, "pack-"+hex.EncodeToString(pack[len(pack)-20:]))
+	// The index is written first and checks the pack, so a bad pack is
+	// never installed.
+	if err := indexPack(pack, base+".idx"); err != nil {
+		return "", err
+	}
+	tmp := base + ".pack.tmp"
+	if err := os.WriteFile(tmp, pack, 0444); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, base+".pack"); err != nil {
+		return "", err
+	}
+	// Later reads must see the new pack.
+	if r, err := currentRepo(); err == nil {
+		r.ReloadPacks()
+	}
+	return base + ".pack", nil
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// signature is a parsed author or committer line.
+type signature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	When  time.Time `json:"date"`
+}
+
+// parseSignature parses "Name <email> <unix-time> <+hhmm>".
+func parseSignature(line string) (signature, error) {
+	open := strings.IndexByte(line, '<')
+	closing := strings.LastIndexByte(line, '>')
+	if open == -1 || closing < open {
+		return signature{}, fmt.Errorf("malformed signature %q", line)
+	}
+	sig := signature{
+		Name:  strings.TrimSpace(line[:open]),
+		Email: line[open+1 : closing],
+	}
+
+	fields := strings.Fields(line[closing+1:])
+	if len(fields) != 2 {
+		return signature{}, fmt.Errorf("malformed signature %q", line)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return signature{}, fmt.Errorf("malformed signature %q", line)
+	}
+	tz := fields[1]
+	if len(tz) != 5 {
+		return signature{}, fmt.Errorf("malformed signature %q", line)
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return signature{}, fmt.Errorf("malformed signature %q", line)
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	sig.When = time.Unix(seconds, 0).In(time.FixedZone(tz, offset))
+	return sig, nil
+}
+
+// splitMessage returns the first paragraph of a commit message joined into
+// one line as the subject, and the rest as the body, as git's %s and %b do.
+func splitMessage(message string) (subject, body string) {
+	message = strings.TrimLeft(message, "\n")
+	subject, body, _ = strings.Cut(message, "\n\n")
+	subject = strings.Join(strings.Fields(strings.ReplaceAll(subject, "\n", " ")), " ")
+	return subject, strings.TrimLeft(body, "\n")
+}
+
+type logCommit struct {
+	Hash   string
+	Commit *Commit
+	When   time.Time
+	// Decoration names the refs pointing at the commit, as in
+	// "HEAD -> main", when log shows them.
+	Decoration string
+}
+
+func readLogCommit(hash string) (*logCommit, error) {
+	objectType, content, err := readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "commit" {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", hash, objectType)
+	}
+	commit, err := object.ParseCommit(content)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", hash, err)
+	}
+	if isShallow(hash) {
+		commit.Parents = nil
+	}
+	committer, err := parseSignature(commit.Committer)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", hash, err)
+	}
+	return &logCommit{Hash: hash, Commit: commit, When: committer.When}, nil
+}
+
+// walkCommits calls fn for start and each of its ancestors, newest commit
+// date first, until fn returns false.
+func walkCommits(start string, fn func(c *logCommit) bool) error {
+	first, err := readLogCommit(start)
+	if err != nil {
+		return err
+	}
+	queue := []*logCommit{first}
+	seen := map[string]bool{start: true}
+
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if !fn(c) {
+			return nil
+		}
+		for _, parent := range c.Commit.Parents {
+			if seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			p, err := readLogCommit(parent)
+			if err != nil {
+				return err
+			}
+			i := sort.Search(len(queue), func(i int) bool {
+				return queue[i].When.Before(p.When)
+			})
+			queue = append(queue, nil)
+			copy(queue[i+1:], queue[i:])
+			queue[i] = p
+		}
+	}
+	return nil
+}
+
+// formatCommit expands the placeholders of a --format string.
+func formatCommit(format string, c *logCommit) (string, error) {
+	author, err := parseSignature(c.Commit.Author)
+	if err != nil {
+		return "", fmt.Errorf("object %s: %w", c.Hash, err)
+	}
+	subject, body := splitMessage(c.Commit.Message)
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		placeholder := format[i+1:]
+		switch {
+		case strings.HasPrefix(placeholder, "H"):
+			b.WriteString(c.Hash)
+		case strings.HasPrefix(placeholder, "h"):
+			b.WriteString(c.Hash[:7])
+		case strings.HasPrefix(placeholder, "T"):
+			b.WriteString(c.Commit.Tree)
+		case strings.HasPrefix(placeholder, "P"):
+			b.WriteString(strings.Join(c.Commit.Parents, " "))
+		case strings.HasPrefix(placeholder, "an"):
+			b.WriteString(author.Name)
+		case strings.HasPrefix(placeholder, "ae"):
+			b.WriteString(author.Email)
+		case strings.HasPrefix(placeholder, "at"):
+			b.WriteString(strconv.FormatInt(author.When.Unix(), 10))
+		case strings.HasPrefix(placeholder, "ad"):
+			b.WriteString(author.When.Format(gitDateLayout))
+		case strings.HasPrefix(placeholder, "d"):
+			if c.Decoration != "" {
+				b.WriteString(" (" + c.Decoration + ")")
+			}
+		case strings.HasPrefix(placeholder, "s"):
+			b.WriteString(subject)
+		case strings.HasPrefix(placeholder, "b"):
+			b.WriteString(body)
+		case strings.HasPrefix(placeholder, "n"):
+			b.WriteByte('\n')
+		case strings.HasPrefix(placeholder, "%"):
+			b.WriteByte('%')
+		default:
+			// Unknown placeholders are printed as they are, like git.
+			b.WriteByte('%')
+			continue
+		}
+		if placeholder[0] == 'a' {
+			i += 2
+		} else {
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// logEntry is the --json form of a commit.
+type logEntry struct {
+	Hash      string    `json:"hash"`
+	Tree      string    `json:"tree"`
+	Parents   []string  `json:"parents"`
+	Author    signature `json:"author"`
+	Committer signature `json:"committer"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+}
+
+func newLogEntry(c *logCommit) (logEntry, error) {
+	author, err := parseSignature(c.Commit.Author)
+	if err != nil {
+		return logEntry{}, fmt.Errorf("object %s: %w", c.Hash, err)
+	}
+	committer, err := parseSignature(c.Commit.Committer)
+	if err != nil {
+		return logEntry{}, fmt.Errorf("object %s: %w", c.Hash, err)
+	}
+	subject, body := splitMessage(c.Commit.Message)
+	parents := c.Commit.Parents
+	if parents == nil {
+		parents = []string{}
+	}
+	return logEntry{
+		Hash:      c.Hash,
+		Tree:      c.Commit.Tree,
+		Parents:   parents,
+		Author:    author,
+		Committer: committer,
+		Subject:   subject,
+		Body:      body,
+	}, nil
+}
+
+const gitDateLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+// writeMediumLog writes a commit in git's default log format.
+func writeMediumLog(w io.Writer, c *logCommit) error {
+	author, err := parseSignature(c.Commit.Author)
+	if err != nil {
+		return fmt.Errorf("object %s: %w", c.Hash, err)
+	}
+	if c.Decoration != "" {
+		fmt.Fprintf(w, "commit %s (%s)\n", c.Hash, c.Decoration)
+	} else {
+		fmt.Fprintf(w, "commit %s\n", c.Hash)
+	}
+	if len(c.Commit.Parents) > 1 {
+		short := make([]string, len(c.Commit.Parents))
+		for i, parent := range c.Commit.Parents {
+			short[i] = parent[:7]
+		}
+		fmt.Fprintf(w, "Merge: %s\n", strings.Join(short, " "))
+	}
+	fmt.Fprintf(w, "Author: %s <%s>\n", author.Name, author.Email)
+	fmt.Fprintf(w, "Date:   %s\n\n", author.When.Format(gitDateLayout))
+	for _, line := range strings.Split(strings.TrimRight(c.Commit.Message, "\n"), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+	return nil
+}
+
+func runLog(args []string) {
+	format := ""
+	// A "format:" string separates commits, while "tformat:", --format and
+	// --oneline terminate each one, as in git.
+	separator := false
+	decorate := true
+	maxCount := -1
+	revision := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--oneline":
+			format = "%h%d %s"
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "--pretty="):
+			pretty := strings.TrimPrefix(arg, "--pretty=")
+			switch {
+			case pretty == "oneline":
+				format = "%H %s"
+			case pretty == "medium":
+				format = ""
+			case strings.HasPrefix(pretty, "format:"):
+				format = strings.TrimPrefix(pretty, "format:")
+				separator = true
+			case strings.HasPrefix(pretty, "tformat:"):
+				format = strings.TrimPrefix(pretty, "tformat:")
+			default:
+				handleError(fmt.Errorf("invalid --pretty format: %s", pretty))
+			}
+		case arg == "--decorate":
+			decorate = true
+		case arg == "--no-decorate":
+			decorate = false
+		case arg == "-n" || arg == "--max-count":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil {
+				handleError(fmt.Errorf("invalid count '%s'", args[i]))
+			}
+			maxCount = n
+		case strings.HasPrefix(arg, "--max-count="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--max-count="))
+			if err != nil {
+				handleError(fmt.Errorf("invalid count '%s'", arg))
+			}
+			maxCount = n
+		case len(arg) > 2 && strings.HasPrefix(arg, "-n"):
+			n, err := strconv.Atoi(arg[2:])
+			if err != nil {
+				handleError(fmt.Errorf("invalid count '%s'", arg[2:]))
+			}
+			maxCount = n
+		case len(arg) > 1 && arg[0] == '-' && isDigits(arg[1:]):
+			// -<n> is git's shorthand for -n <n>.
+			maxCount, _ = strconv.Atoi(arg[1:])
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			handleError(errors.New("usage: got log [--oneline] [--format=<fmt>] [--[no-]decorate] [-n <count> | -<count>] [<revision>]"))
+		default:
+			if revision != "" {
+				handleError(errors.New("usage: got log [--oneline] [--format=<fmt>] [--[no-]decorate] [-n <count> | -<count>] [<revision>]"))
+			}
+			revision = arg
+		}
+	}
+
+	var start string
+	var err error
+	if revision == "" {
+		start, err = headCommit()
+	} else {
+		start, err = resolveRevision(revision)
+	}
+	if errors.Is(err, errNoCommits) {
+		// An unborn branch is not a failure of got's, so this is reported
+		// as a plain message, with git's exit status.
+		fmt.Fprintln(os.Stderr, err)
+		exit(128)
+	}
+	if err != nil {
+		handleError(err)
+	}
+
+	var head, headDecoration string
+	if decorate {
+		head, headDecoration = decorateHead()
+	}
+
+	count := 0
+	var walkErr error
+	entries := []logEntry{}
+	err = walkCommits(start, func(c *logCommit) bool {
+		if maxCount >= 0 && count >= maxCount {
+			return false
+		}
+		if c.Hash == head {
+			c.Decoration = headDecoration
+		}
+		if jsonOutput {
+			var entry logEntry
+			entry, walkErr = newLogEntry(c)
+			entries = append(entries, entry)
+		} else if format == "" {
+			if count > 0 {
+				fmt.Println()
+			}
+			walkErr = writeMediumLog(os.Stdout, c)
+		} else {
+			var line string
+			line, walkErr = formatCommit(format, c)
+			if separator && count > 0 {
+				fmt.Println()
+			}
+			fmt.Print(line)
+			if !separator {
+				fmt.Println()
+			}
+		}
+		count++
+		return walkErr == nil
+	})
+	if err == nil {
+		err = walkErr
+	}
+	if err != nil {
+		handleError(err)
+	}
+	if jsonOutput {
+		writeJSON(entries)
+	}
+}
+
+// decorateHead returns the commit HEAD is at and how log labels it: as in
+// git, "HEAD -> <branch>" on a branch and a bare "HEAD" when detached.
+func decorateHead() (string, string) {
+	hash, err := resolveRef("HEAD")
+	if err != nil {
+		return "", ""
+	}
+	branch, onBranch, err := currentBranch()
+	if err != nil || !onBranch {
+		return hash, "HEAD"
+	}
+	return hash, "HEAD -> " + branch
+}
+
+// isDigits reports whether s is a non-empty run of decimal digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
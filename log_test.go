@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLogUnbornBranch(t *testing.T) {
+	dir := newTestRepo(t)
+	out, stderr, err := runCommandStderr(dir, gotBinary, "log")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 128 {
+		t.Fatalf("log on an unborn branch: %v, want exit status 128", err)
+	}
+	if want := "your current branch 'main' does not have any commits yet\n"; stderr != want || out != "" {
+		t.Errorf("log on an unborn branch printed %q and %q, want only %q", out, stderr, want)
+	}
+}
+
+func TestLogDetachedHead(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "first")
+	commitFile(t, dir, "a", "b\n", "second")
+	first := strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD~1"))
+	second := strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD"))
+
+	if got, want := runGot(t, dir, "log", "--oneline"), second[:7]+" (HEAD -> main) second\n"+first[:7]+" first\n"; got != want {
+		t.Errorf("log --oneline on main:\n%s\nwant:\n%s", got, want)
+	}
+	runGot(t, dir, "checkout", first)
+	got := runGot(t, dir, "log")
+	if want := "commit " + first + " (HEAD)\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("log with a detached HEAD starts:\n%s\nwant %q", got, want)
+	}
+	if got := runGot(t, dir, "log", "--oneline", "main"); got != second[:7]+" second\n"+first[:7]+" (HEAD) first\n" {
+		t.Errorf("log --oneline main with a detached HEAD:\n%s", got)
+	}
+	if got := runGot(t, dir, "log", "--no-decorate", "-1"); strings.Contains(got, "(HEAD") {
+		t.Errorf("log --no-decorate decorated HEAD:\n%s", got)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func runLsFiles(args []string) {
+	usage := errors.New("usage: got ls-files [-c] [-m] [-o] [-i] [--exclude-standard] [-s] [--debug]")
+	var cached, modified, others, ignored, excludeStandard, stage, debug bool
+	for _, arg := range args {
+		switch arg {
+		case "-c", "--cached":
+			cached = true
+		case "-m", "--modified":
+			modified = true
+		case "-o", "--others":
+			others = true
+		case "-i", "--ignored":
+			ignored = true
+		case "--exclude-standard":
+			excludeStandard = true
+		case "-s", "--stage":
+			stage = true
+		case "--debug":
+			debug = true
+		default:
+			handleError(usage)
+		}
+	}
+	if ignored && !others && !cached {
+		handleError(errors.New("ls-files -i must be used with either -o or -c"))
+	}
+	if ignored && !excludeStandard {
+		handleError(errors.New("ls-files -i needs some exclude pattern; pass --exclude-standard"))
+	}
+	// The index is listed unless only other kinds of files were asked for;
+	// as in git, --stage always lists it.
+	if stage || (!modified && !others) {
+		cached = true
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+	var ignore *ignoreMatcher
+	if excludeStandard {
+		if ignore, err = newIgnoreMatcher(); err != nil {
+			handleError(err)
+		}
+	}
+
+	// As in git, run from a subdirectory ls-files lists only what is
+	// under it, with paths relative to it.
+	show := func(entry *IndexEntry) {
+		if stage {
+			fmt.Printf("%06o %s %d\t%s\n", entry.Mode, entry.Hash, entry.Stage(), relativePath(entry.Path))
+		} else {
+			fmt.Println(relativePath(entry.Path))
+		}
+		if debug {
+			fmt.Printf("  ctime: %d:%d\n", entry.CtimeSec, entry.CtimeNsec)
+			fmt.Printf("  mtime: %d:%d\n", entry.MtimeSec, entry.MtimeNsec)
+			fmt.Printf("  dev: %d\tino: %d\n", entry.Dev, entry.Ino)
+			fmt.Printf("  uid: %d\tgid: %d\n", entry.Uid, entry.Gid)
+			fmt.Printf("  size: %d\tflags: %x\n", entry.Size, entry.Flags)
+		}
+	}
+
+	// As in git, untracked files come first, then each index entry is
+	// shown once for -c and again for -m when it has changed.
+	if others {
+		paths, err := otherFiles(idx, ignore, ignored)
+		if err != nil {
+			handleError(err)
+		}
+		for _, p := range paths {
+			if strings.HasPrefix(p, cwdPrefix) {
+				fmt.Println(relativePath(p))
+			}
+		}
+	}
+	for _, entry := range idx.Entries {
+		if !strings.HasPrefix(entry.Path, cwdPrefix) {
+			continue
+		}
+		if cached {
+			// With -c, -i keeps only the tracked files that an ignore
+			// pattern would match.
+			match := true
+			if ignored {
+				if match, err = ignore.ignored(entry.Path, false); err != nil {
+					handleError(err)
+				}
+			}
+			if match {
+				show(entry)
+			}
+		}
+		if modified {
+			change, err := worktreeChange(entry)
+			if err != nil {
+				handleError(err)
+			}
+			if change != "" {
+				show(entry)
+			}
+		}
+	}
+}
+
+// otherFiles lists every file in the working tree that is not in the
+// index, each by its full path. Nested repositories are listed once as
+// "dir/". With an ignore matcher, ignored files are left out, or when
+// onlyIgnored is set, they are the only ones listed.
+func otherFiles(idx *Index, ignore *ignoreMatcher, onlyIgnored bool) ([]string, error) {
+	tracked := make(map[string]bool)
+	trackedDirs := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		tracked[entry.Path] = true
+		for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	others := []string{}
+	var walk func(dir string, inIgnored bool) error
+	walk = func(dir string, inIgnored bool) error {
+		entries, err := os.ReadDir(filepath.FromSlash(dir))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Name() == ".git" {
+				continue
+			}
+			p := path.Join(dir, entry.Name())
+			if tracked[p] {
+				continue
+			}
+			isIgnored := inIgnored
+			if ignore != nil && !isIgnored && !trackedDirs[p] {
+				if isIgnored, err = ignore.ignored(p, entry.IsDir()); err != nil {
+					return err
+				}
+			}
+			// Ignored directories are only worth walking for -i.
+			if isIgnored && !onlyIgnored {
+				continue
+			}
+			switch {
+			case entry.IsDir() && !trackedDirs[p] && isSubmodule(filepath.FromSlash(p)):
+				if isIgnored == onlyIgnored {
+					others = append(others, p+"/")
+				}
+			case entry.IsDir():
+				if err := walk(p, isIgnored); err != nil {
+					return err
+				}
+			case isIgnored == onlyIgnored:
+				others = append(others, p)
+			}
+		}
+		return nil
+	}
+	if err := walk(".", false); err != nil {
+		return nil, err
+	}
+	sort.Strings(others)
+	return others, nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLsFilesInSubdirectory(t *testing.T) {
+	dir := newStatusRepo(t)
+	for _, subdir := range []string{"", "a", "a/b"} {
+		for _, args := range [][]string{nil, {"-s"}, {"-m"}, {"-o"}} {
+			cwd := filepath.Join(dir, filepath.FromSlash(subdir))
+			args := append([]string{"ls-files"}, args...)
+			want := runGit(t, cwd, args...)
+			if got := runGot(t, cwd, args...); got != want {
+				t.Errorf("got %s in %q:\n%s\ngit has:\n%s", strings.Join(args, " "), subdir, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+func runLsRemote(args []string) {
+	usage := errors.New("usage: got ls-remote [--heads] [--tags] [--refs] [--symref] [-q] [--exit-code] [<repository> [<patterns>...]]")
+	var heads, tags, refsOnly, symref, quiet, exitCode bool
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "-h" || arg == "--heads" || arg == "--branches":
+			heads = true
+		case arg == "-t" || arg == "--tags":
+			tags = true
+		case arg == "--refs":
+			refsOnly = true
+		case arg == "--symref":
+			symref = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "--exit-code":
+			exitCode = true
+		case strings.HasPrefix(arg, "-"):
+			handleError(usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	var name string
+	var patterns []string
+	if len(positional) > 0 {
+		name, patterns = positional[0], positional[1:]
+	}
+	url, err := lsRemoteURL(name)
+	if err != nil {
+		handleError(err)
+	}
+	remote, err := openRemote(url)
+	if err != nil {
+		handleError(err)
+	}
+	defer remote.close()
+	adv, err := remote.discoverRefs("git-upload-pack", protocolVersion())
+	if err != nil {
+		handleError(err)
+	}
+	var prefixes []string
+	if heads {
+		prefixes = append(prefixes, "refs/heads/")
+	}
+	if tags {
+		prefixes = append(prefixes, "refs/tags/")
+	}
+	refs, err := listRemoteRefs(remote, adv, prefixes)
+	if err != nil {
+		handleError(err)
+	}
+
+	if name == "" && !quiet {
+		fmt.Fprintf(os.Stderr, "From %s\n", url)
+	}
+	matched := false
+	for _, ref := range refs {
+		if ref.Hash == "" || (refsOnly && !strings.HasPrefix(ref.Name, "refs/")) || !matchesRefTail(ref.Name, patterns) {
+			continue
+		}
+		matched = true
+		if symref && ref.Symref != "" {
+			fmt.Printf("ref: %s\t%s\n", ref.Symref, ref.Name)
+		}
+		fmt.Printf("%s\t%s\n", ref.Hash, ref.Name)
+		if ref.Peeled != "" && !refsOnly {
+			fmt.Printf("%s\t%s^{}\n", ref.Peeled, ref.Name)
+		}
+	}
+	if exitCode && !matched {
+		exit(2)
+	}
+}
+
+// lsRemoteURL returns the URL of the repository named on the command line,
+// a remote or a URL, or without one that of the current branch's remote or
+// origin.
+func lsRemoteURL(name string) (string, error) {
+	repo, err := currentRepo()
+	if name != "" {
+		if err != nil {
+			return name, nil
+		}
+		return remoteURL(repo, name), nil
+	}
+	if err != nil {
+		return "", errors.New("no remote configured to list refs from")
+	}
+	name = "origin"
+	if branch, onBranch, _ := currentBranch(); onBranch {
+		if remote, ok := repo.Config.Get("branch."+branch, "remote"); ok && remote != "." {
+			name = remote
+		}
+	}
+	url, ok := repo.Config.Get("remote."+name, "url")
+	if !ok {
+		return "", errors.New("no remote configured to list refs from")
+	}
+	return url, nil
+}
+
+// matchesRefTail reports whether one of patterns matches the whole of ref
+// or the part after one of its slashes, as ls-remote patterns do. No
+// patterns match everything.
+func matchesRefTail(ref string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	name := "/" + ref
+	for _, pattern := range patterns {
+		for i := 0; i < len(name); i++ {
+			if name[i] != '/' {
+				continue
+			}
+			if ok, _ := path.Match(pattern, name[i+1:]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
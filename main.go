@@ -1,18 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
-	"crypto/sha1"
-	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/piyushyadav1617/got/object"
+	"github.com/piyushyadav1617/got/repo"
+)
+
+// TreeEntry and Commit are the object package's types, used throughout
+// the commands.
+type (
+	TreeEntry = object.TreeEntry
+	Commit    = object.Commit
 )
 
 var gitModes = map[string]string{
@@ -24,342 +37,1016 @@ var gitModes = map[string]string{
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "usage: got <command> [<args>...]\n")
+	args := os.Args[1:]
+	usage := "usage: got [--json] [--debug-stats] [--git-dir=<path>] [--work-tree=<path>] <command> [<args>...]\n"
+	var gitDirOption, workTreeOption string
+	for ; len(args) > 0 && strings.HasPrefix(args[0], "--"); args = args[1:] {
+		switch arg := args[0]; {
+		case arg == "--json":
+			jsonOutput = true
+		case arg == "--debug-stats":
+			debugStats = true
+		case strings.HasPrefix(arg, "--git-dir="):
+			gitDirOption = strings.TrimPrefix(arg, "--git-dir=")
+		case strings.HasPrefix(arg, "--work-tree="):
+			workTreeOption = strings.TrimPrefix(arg, "--work-tree=")
+		case (arg == "--git-dir" || arg == "--work-tree") && len(args) > 1:
+			if arg == "--git-dir" {
+				gitDirOption = args[1]
+			} else {
+				workTreeOption = args[1]
+			}
+			args = args[1:]
+		default:
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, usage)
 		os.Exit(1)
 	}
 
-	switch command := os.Args[1]; command {
-	case "init":
-		for _, dir := range []string{".git", ".git/objects", ".git/refs"} {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				fmt.Fprintf(os.Stderr, "Error creating directory: %s\n", err)
-			}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n", args[0])
+		os.Exit(1)
+	}
+	if !cmd.noRepo {
+		if err := setupRepository(gitDirOption, workTreeOption); err != nil {
+			handleError(err)
 		}
-
-		headFileContents := []byte("ref: refs/heads/main\n")
-		if err := os.WriteFile(".git/HEAD", headFileContents, 0644); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing file: %s\n", err)
+		if bareRepo && cmd.workTree {
+			handleError(errors.New("this operation must be run in a work tree"))
 		}
+	}
+	cmd.run(args[1:])
+	exit(0)
+}
 
-		fmt.Println("Initialized git directory")
-	case "cat-file":
-		if len(os.Args) < 4 {
-			handleError(errors.New("usage: got cat-file -p [<args>...]"))
+func runInit(args []string) {
+	usage := errors.New("usage: got init [-q] [--bare] [<directory>]")
+	var bare, quiet bool
+	var dir string
+	for _, arg := range args {
+		switch {
+		case arg == "--bare":
+			bare = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case dir == "" && !strings.HasPrefix(arg, "-"):
+			dir = arg
+		default:
+			handleError(usage)
 		}
-		if os.Args[2] != "-p" {
-			handleError(errors.New("usage: got cat-file -p [<args>...]"))
+	}
+	// A bare repository is the git directory itself, with no work tree
+	// around it.
+	gitDir := filepath.Join(dir, ".git")
+	if bare {
+		gitDir = filepath.Join(dir, ".")
+	}
+	if err := createGitDir(gitDir, bare); err != nil {
+		handleError(err)
+	}
+	if quiet {
+		return
+	}
+	if bare {
+		fmt.Println("Initialized bare git directory")
+	} else {
+		fmt.Println("Initialized git directory")
+	}
+}
+
+// createGitDir lays out an empty repository in dir with HEAD on main. A
+// bare one is marked so in its config.
+func createGitDir(dir string, bare bool) error {
+	for _, sub := range []string{"objects", "refs/heads", "refs/tags"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return err
 		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "HEAD"), []byte("ref: refs/heads/main\n"), 0644); err != nil {
+		return err
+	}
+	if bare {
+		return setConfigValue(filepath.Join(dir, "config"), "core.bare", "true", false)
+	}
+	return nil
+}
 
-		hash := os.Args[3]
-		if len(hash) < 40 {
-			handleError(errors.New("invalid hash"))
+func runCatFile(args []string) {
+	usage := errors.New("usage: got cat-file (-p | -t | -s | -e [--full] | --raw) [--allow-unknown-type] <object>\n   or: got cat-file (--batch | --batch-check) [--allow-unknown-type]")
+	var mode, name string
+	full, allowUnknown := false, false
+	for _, arg := range args {
+		switch {
+		case arg == "-p" || arg == "-e" || arg == "-t" || arg == "-s" || arg == "--raw" || arg == "--batch" || arg == "--batch-check":
+			mode = arg
+		case arg == "--full":
+			full = true
+		case arg == "--allow-unknown-type":
+			allowUnknown = true
+		case name == "":
+			name = arg
+		default:
+			handleError(usage)
 		}
-		filePath := fmt.Sprintf(".git/objects/%s/%s", hash[:2], hash[2:])
-		b, err := os.ReadFile(filePath)
-		if err != nil {
-			handleError(err)
+	}
+	if mode == "--batch" || mode == "--batch-check" {
+		if name != "" || full {
+			handleError(usage)
 		}
-		reader := bytes.NewReader(b)
-		r, err := zlib.NewReader(reader)
-		if err != nil {
+		if err := catFileBatch(os.Stdin, os.Stdout, mode == "--batch", allowUnknown); err != nil {
 			handleError(err)
 		}
-		defer r.Close()
+		return
+	}
+	if mode == "" || name == "" || (full && mode != "-e") {
+		handleError(usage)
+	}
 
-		content, err := io.ReadAll(r)
+	hash, err := resolveRevision(name)
+	if err != nil {
+		handleError(err)
+	}
+
+	switch mode {
+	case "-e":
+		// Only the status matters, so failures are not reported.
+		if !objectValid(hash, full) {
+			exit(1)
+		}
+		return
+	case "--raw":
+		// Whatever could be inflated is printed even if the object turns
+		// out to be corrupt, since that is what --raw is for.
+		raw, err := rawObject(hash)
+		os.Stdout.Write(raw)
 		if err != nil {
 			handleError(err)
 		}
-		nullIndex := bytes.IndexByte(content, byte(0))
-		if nullIndex == -1 {
-			handleError(errors.New("invalid git object format"))
+		return
+	case "-t", "-s":
+		objectType, size, err := objectHeader(hash)
+		if err != nil {
+			handleError(err)
 		}
-		// typeIndex := bytes.IndexByte(content, byte(' '))
-		// sizeIndex := typeIndex + 1
-		// objectType := string(content[:typeIndex])
-		// contentSize := string(content[sizeIndex:nullIndex])
-		fmt.Print(string(content[nullIndex+1:]))
-	case "hash-object":
-		if len(os.Args) < 4 {
-			handleError(errors.New("usage: got hash-object [<args>...]"))
+		if !allowUnknown && !isKnownType(objectType) {
+			handleError(fmt.Errorf("object %s has unknown type \"%s\"", hash, objectType))
 		}
-		if os.Args[2] != "-w" {
-			handleError(errors.New("usage: got hash-object -w [<args>...]"))
+		if mode == "-t" {
+			fmt.Println(objectType)
+		} else {
+			fmt.Println(size)
 		}
+		return
+	}
 
-		filepath := os.Args[3]
+	objectType, size, r, err := openObject(hash)
+	if err != nil {
+		handleError(err)
+	}
+	defer r.Close()
+	if !allowUnknown && !isKnownType(objectType) {
+		handleError(fmt.Errorf("object %s has unknown type \"%s\"", hash, objectType))
+	}
 
-		b, err := os.ReadFile(filepath)
+	// Trees are binary, so show them the way ls-tree does.
+	if objectType == "tree" {
+		content, err := io.ReadAll(r)
 		if err != nil {
 			handleError(err)
 		}
-
-		hash, err := writeObject("blob", b)
+		entries, err := object.ParseTree(content)
 		if err != nil {
 			handleError(err)
 		}
-		fmt.Println(hash)
-	case "ls-tree":
-		if len(os.Args) < 3 {
-			handleError(errors.New("usage: got ls-tree [<args>...] [hash]"))
+		for _, entry := range entries {
+			fmt.Println(formatTreeEntry(entry))
 		}
+		return
+	}
+	n, err := io.Copy(os.Stdout, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("object %s: truncated: expected %d bytes, got %d", hash, size, n)
+	}
+	if err != nil {
+		handleError(err)
+	}
+}
 
-		var nameOnly bool
-		var hash string
+func isKnownType(objectType string) bool {
+	switch objectType {
+	case "blob", "tree", "commit", "tag":
+		return true
+	}
+	return false
+}
 
-		if os.Args[2] == "--name-only" {
-			nameOnly = true
-			hash = os.Args[3]
-		} else {
-			hash = os.Args[2]
+// objectValid reports whether the object exists with a readable header.
+// With full set the whole object is inflated and its hash checked too.
+func objectValid(hash string, full bool) bool {
+	repo, err := currentRepo()
+	if err != nil {
+		return false
+	}
+	if !full {
+		return repo.ObjectExists(hash)
+	}
+	objectType, content, err := readObject(hash)
+	if err != nil {
+		return false
+	}
+	return object.Hash(objectType, content) == hash
+}
+
+func runHashObject(args []string) {
+	write, stdinPaths := false, false
+	var paths []string
+	for _, arg := range args {
+		switch arg {
+		case "-w":
+			write = true
+		case "--stdin-paths":
+			stdinPaths = true
+		default:
+			paths = append(paths, arg)
 		}
+	}
+	if stdinPaths == (len(paths) > 0) {
+		handleError(errors.New("usage: got hash-object [-w] (--stdin-paths | <file>...)"))
+	}
 
-		if len(hash) != 40 {
-			handleError(errors.New("invalid hash"))
+	if !stdinPaths {
+		for _, path := range paths {
+			hash, err := hashFile(prefixFilename(path), write)
+			if err != nil {
+				handleError(err)
+			}
+			fmt.Println(hash)
 		}
+		return
+	}
 
-		filePath := fmt.Sprintf(".git/objects/%s/%s", hash[:2], hash[2:])
-		b, err := os.ReadFile(filePath)
+	// A bad path is reported and skipped so one failure does not stop
+	// the rest of the batch.
+	failed := false
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		path := scanner.Text()
+		if path == "" {
+			continue
+		}
+		hash, err := hashFile(prefixFilename(path), write)
 		if err != nil {
-			handleError(err)
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			failed = true
+			continue
 		}
+		fmt.Println(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		handleError(err)
+	}
+	if failed {
+		exit(1)
+	}
+}
 
-		r, err := zlib.NewReader(bytes.NewReader(b))
+// hashFile returns the blob hash of the file at path, storing the blob
+// when write is set. The file is streamed unless core.autocrlf needs its
+// whole content to convert line endings.
+func hashFile(path string, write bool) (string, error) {
+	// As in git, a file can be hashed outside a repository as long as it
+	// is not stored; its content is then taken as it is.
+	repo, err := currentRepo()
+	if err != nil && write {
+		return "", err
+	}
+	if err != nil || !autocrlfEnabled(repo.Config) {
+		f, err := os.Open(path)
 		if err != nil {
-			handleError(err)
+			return "", err
 		}
-		defer r.Close()
-
-		content, err := io.ReadAll(r)
+		defer f.Close()
+		info, err := f.Stat()
 		if err != nil {
-			handleError(err)
+			return "", err
 		}
+		return streamObject("blob", info.Size(), f, write && !dryRun)
+	}
 
-		nullIndex := bytes.IndexByte(content, 0)
-		if nullIndex == -1 {
-			handleError(errors.New("invalid git object format"))
-		}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	b, err = convertToGit(b)
+	if err != nil {
+		return "", err
+	}
+	if !write {
+		return hashBlob(b), nil
+	}
+	return writeObject("blob", b)
+}
+
+func runLsTree(args []string) {
+	if len(args) < 1 {
+		handleError(errors.New("usage: got ls-tree [-r] [-t] [-d] [--name-only] [-l] <tree-ish>"))
+	}
+
+	var nameOnly, long, recurse, showTrees, onlyTrees bool
+	var hash string
 
-		type TreeEntry struct {
-			Mode string
-			Type string
-			Hash string
-			Name string
+	for _, arg := range args {
+		switch arg {
+		case "--name-only":
+			nameOnly = true
+		case "-l", "--long":
+			long = true
+		case "-r":
+			recurse = true
+		case "-t":
+			showTrees = true
+		case "-d":
+			onlyTrees = true
+		default:
+			hash = arg
 		}
+	}
 
-		// Parse tree entries
-		var entries []TreeEntry
-		data := content[nullIndex+1:]
-		for len(data) > 0 {
-			// Parse mode
-			spaceIdx := bytes.IndexByte(data, ' ')
-			if spaceIdx == -1 {
-				handleError(errors.New("malformed entry: missing mode"))
-			}
-			mode := string(data[:spaceIdx])
-			data = data[spaceIdx+1:]
+	hash, err := resolveRevision(hash)
+	if err != nil {
+		handleError(err)
+	}
+	tree, err := peelToTree(hash)
+	if err != nil {
+		handleError(err)
+	}
 
-			nullIdx := bytes.IndexByte(data, 0)
-			if nullIdx == -1 {
-				handleError(errors.New("malformed entry: missing name terminator"))
-			}
-			name := string(data[:nullIdx])
-			data = data[nullIdx+1:]
+	entries, err := listTree(tree, "", recurse, showTrees || onlyTrees, onlyTrees)
+	if err != nil {
+		handleError(err)
+	}
 
-			if len(data) < 20 {
-				handleError(errors.New("malformed entry: incomplete hash"))
-			}
-			hashBytes := data[:20]
-			data = data[20:]
+	if jsonOutput {
+		writeLsTreeJSON(entries, long)
+		return
+	}
 
-			entries = append(entries, TreeEntry{
-				Mode: mode,
-				Type: gitModes[mode],
-				Hash: sha1toHex(hashBytes),
-				Name: name,
-			})
+	for _, entry := range entries {
+		if nameOnly {
+			fmt.Println(entry.Name)
+		} else if long {
+			size, err := treeEntrySize(entry)
+			if err != nil {
+				handleError(err)
+			}
+			mode := displayMode(entry.Mode)
+			fmt.Printf("%s %s %s %7s\t%s\n", mode, gitModes[mode], entry.Hash, size, entry.Name)
+		} else {
+			fmt.Println(formatTreeEntry(entry))
 		}
+	}
+}
 
-		for _, entry := range entries {
-			if nameOnly {
-				fmt.Println(entry.Name)
-			} else {
-				fmt.Printf("%s %s %s %s\n", entry.Mode, entry.Type, entry.Hash, entry.Name)
-			}
+// listTree returns the entries of a tree, named by their path under
+// prefix. With recurse set, subtrees are descended into and listed only
+// when showTrees is set too. onlyTrees leaves out everything but trees.
+func listTree(tree, prefix string, recurse, showTrees, onlyTrees bool) ([]TreeEntry, error) {
+	_, content, err := readObject(tree)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := object.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []TreeEntry
+	for _, entry := range entries {
+		entry.Name = prefix + entry.Name
+		isTree := object.IsTreeMode(entry.Mode)
+		if !isTree && onlyTrees {
+			continue
+		}
+		if !isTree || !recurse {
+			list = append(list, entry)
+			continue
+		}
+		if showTrees {
+			list = append(list, entry)
 		}
-	case "write-tree":
-		hash, err := writeTree(".")
+		sub, err := listTree(entry.Hash, entry.Name+"/", recurse, showTrees, onlyTrees)
 		if err != nil {
-			handleError(err)
+			return nil, err
 		}
-		fmt.Println(hash)
-	case "commit-tree":
-		if len(os.Args) < 3 {
-			handleError(errors.New("tree hash is required"))
-			os.Exit(1)
+		list = append(list, sub...)
+	}
+	return list, nil
+}
+
+func runWriteTree(args []string) {
+	prefix := ""
+	missingOK := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--prefix":
+			if i+1 >= len(args) {
+				handleError(errors.New("option --prefix requires a value"))
+			}
+			i++
+			prefix = args[i]
+		case strings.HasPrefix(args[i], "--prefix="):
+			prefix = strings.TrimPrefix(args[i], "--prefix=")
+		case args[i] == "-n" || args[i] == "--dry-run":
+			dryRun = true
+		case args[i] == "--missing-ok":
+			missingOK = true
+		default:
+			handleError(errors.New("usage: got write-tree [-n] [--missing-ok] [--prefix <path>]"))
 		}
-		commitTreeCmd := flag.NewFlagSet("commit-tree", flag.ExitOnError)
+	}
+	if prefix = path.Clean(filepath.ToSlash(prefix)); prefix == "." {
+		prefix = ""
+	} else {
+		prefix += "/"
+	}
 
-		parent := commitTreeCmd.String("p", "", "parent commit hash")
-		message := commitTreeCmd.String("m", "", "commit message")
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+	hash, err := writeIndexTree(idx, prefix, missingOK)
+	if err != nil {
+		handleError(err)
+	}
+	fmt.Println(hash)
+}
 
-		treeHash := os.Args[2]
-		commitTreeCmd.Parse(os.Args[3:])
-		commitMessage := *message
+// stringList is a flag that can be given more than once.
+type stringList []string
 
-		if commitMessage == "" {
-			handleError(errors.New("commit message is required"))
-		}
-		parentHash := *parent
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
 
-		var commitContent bytes.Buffer
-		commitContent.WriteString(fmt.Sprintf("tree %s\n", treeHash))
-		if parentHash != "" {
-			commitContent.WriteString(fmt.Sprintf("parent %s\n", parentHash))
+func runCommitTree(args []string) {
+	commitTreeCmd := flag.NewFlagSet("commit-tree", flag.ExitOnError)
+	var parents, messages stringList
+	commitTreeCmd.Var(&parents, "p", "parent commit (may be repeated)")
+	commitTreeCmd.Var(&messages, "m", "commit message paragraph (may be repeated)")
+
+	// The tree may come before or after the options, as with git.
+	var treeName string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		treeName, args = args[0], args[1:]
+	}
+	commitTreeCmd.Parse(args)
+	if treeName == "" && commitTreeCmd.NArg() == 1 {
+		treeName = commitTreeCmd.Arg(0)
+	} else if commitTreeCmd.NArg() != 0 {
+		treeName = ""
+	}
+	if treeName == "" {
+		handleError(errors.New("usage: got commit-tree <tree> [-p <parent>]... [-m <message>]..."))
+	}
+
+	tree, err := resolveRevision(treeName)
+	if err != nil {
+		handleError(err)
+	}
+	if tree, err = peelToTree(tree); err != nil {
+		handleError(err)
+	}
+	var parentHashes []string
+	for _, parent := range parents {
+		hash, err := resolveRevision(parent)
+		if err != nil {
+			handleError(err)
+		}
+		if hash, err = peelToCommit(hash); err != nil {
+			handleError(err)
 		}
-		commitContent.WriteString(fmt.Sprintf("author %s <%s> %s\n", "Piyush Yadav", "yadavpiyush222@gmail.com", formatGitTimestamp(time.Now())))
-		commitContent.WriteString(fmt.Sprintf("committer %s <%s> %s\n", "Piyush Yadav", "yadavpiyush222@gmail.com", formatGitTimestamp(time.Now())))
-		commitContent.WriteString("\n")
-		commitContent.WriteString(commitMessage)
-		commitContent.WriteString("\n")
+		parentHashes = append(parentHashes, hash)
+	}
 
-		commitHash, err := writeObject("commit", commitContent.Bytes())
+	// Without -m the message is read from stdin.
+	var message string
+	if len(messages) > 0 {
+		message = strings.Join(messages, "\n\n") + "\n"
+	} else {
+		b, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			handleError(err)
 		}
-		fmt.Println(commitHash)
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown command %s\n", command)
-		os.Exit(1)
+		message = string(b)
 	}
+
+	commitHash, err := createCommit(tree, parentHashes, message)
+	if err != nil {
+		handleError(err)
+	}
+	fmt.Println(commitHash)
 }
 
-func handleError(err error) {
+// createCommit writes a commit object for tree with the given parents,
+// using the configured author and committer identities.
+func createCommit(tree string, parents []string, message string) (string, error) {
+	repo, err := currentRepo()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-		os.Exit(1)
+		return "", err
+	}
+	now := time.Now()
+	author, err := repo.identity("author", now)
+	if err != nil {
+		return "", err
+	}
+	committer, err := repo.identity("committer", now)
+	if err != nil {
+		return "", err
+	}
+
+	var commitContent bytes.Buffer
+	commitContent.WriteString(fmt.Sprintf("tree %s\n", tree))
+	for _, parent := range parents {
+		commitContent.WriteString(fmt.Sprintf("parent %s\n", parent))
 	}
+	commitContent.WriteString(fmt.Sprintf("author %s\n", author))
+	commitContent.WriteString(fmt.Sprintf("committer %s\n", committer))
+	commitContent.WriteString("\n")
+	commitContent.WriteString(message)
+
+	return writeObject("commit", commitContent.Bytes())
 }
-func computeHash(data []byte) string {
-	h := sha1.Sum(data)
-	return hex.EncodeToString(h[:])
+
+// exit ends the command with code. Commands exit through it rather than
+// os.Exit, so --debug-stats is reported however the command finishes.
+func exit(code int) {
+	if debugStats {
+		writeDebugStats()
+	}
+	os.Exit(code)
 }
-func sha1toHex(sha1sum []byte) string {
-	return hex.EncodeToString(sha1sum)
+
+func handleError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		exit(1)
+	}
 }
 
-type TreeEntry struct {
-	Mode string
-	Name string
-	Hash string
+// maxBlobSize, when positive, is the largest file writeBlobs will store.
+// Larger files are left out and collected in largeBlobs so the command can
+// report all of them at once.
+var (
+	maxBlobSize int64
+	largeBlobs  []string
+	largeMu     sync.Mutex
+)
+
+// blobWorkers is how many files writeBlobs hashes and stores at once.
+var blobWorkers = runtime.GOMAXPROCS(0)
+
+// writeBlobs hashes and stores the named files in dirPath using a pool of
+// blobWorkers workers. The returned entries are in no particular order.
+func writeBlobs(dirPath string, names []string) ([]TreeEntry, error) {
+	results := make([]TreeEntry, len(names))
+	errs := make([]error, len(names))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	workers := blobWorkers
+	if workers > len(names) {
+		workers = len(names)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fullPath := filepath.Join(dirPath, names[i])
+				info, err := os.Lstat(fullPath)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				if maxBlobSize > 0 && info.Mode().IsRegular() && info.Size() > maxBlobSize {
+					largeMu.Lock()
+					largeBlobs = append(largeBlobs, filepath.ToSlash(fullPath))
+					largeMu.Unlock()
+					continue
+				}
+				if hash, ok := unchangedHash(filepath.ToSlash(fullPath), info); ok {
+					results[i] = TreeEntry{Mode: worktreeMode(info), Name: names[i], Hash: hash}
+					continue
+				}
+				mode, hash, err := writeWorktreeBlob(fullPath, info)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				results[i] = TreeEntry{Mode: mode, Name: names[i], Hash: hash}
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
 }
 
-func writeTree(dirPath string) (string, error) {
-	entries, err := os.ReadDir(dirPath)
+// writeIndexTree stores the trees for the staged entries under prefix, a
+// directory given with a trailing slash or "" for the whole index, and
+// returns the hash of the top one. Unless missingOK is set, every staged
+// object must already exist.
+func writeIndexTree(idx *Index, prefix string, missingOK bool) (string, error) {
+	repo, err := currentRepo()
 	if err != nil {
 		return "", err
 	}
-
-	var treeEntries []TreeEntry
-
-	for _, entry := range entries {
-		if entry.Name() == ".git" {
+	var entries []*IndexEntry
+	for _, entry := range idx.Entries {
+		if entry.Stage() != 0 {
+			return "", fmt.Errorf("%s: unmerged (%s)", entry.Path, entry.Hash)
+		}
+		if !strings.HasPrefix(entry.Path, prefix) {
 			continue
 		}
+		if !missingOK && entry.Mode != 0160000 && !repo.ObjectExists(entry.Hash) {
+			return "", fmt.Errorf("invalid object %s %s for '%s'", entry.ModeString(), entry.Hash, entry.Path)
+		}
+		entries = append(entries, entry)
+	}
+	if prefix != "" && len(entries) == 0 {
+		return "", fmt.Errorf("prefix %s not found", prefix)
+	}
+	// The caller's goroutine is one of the treeWorkers writers.
+	workers := make(chan struct{}, max(treeWorkers-1, 0))
+	hash, lines, err := writeIndexSubtree(entries, prefix, workers)
+	if err != nil {
+		return "", err
+	}
+	if dryRun {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+	return hash, nil
+}
 
-		fullPath := filepath.Join(dirPath, entry.Name())
+// treeWorkers is how many subtrees writeIndexTree writes at once.
+var treeWorkers = runtime.GOMAXPROCS(0)
 
-		if entry.IsDir() {
-			hash, err := writeTree(fullPath)
-			if err != nil {
-				return "", err
-			}
+// writeIndexSubtree writes the tree for entries, which all lie under dir
+// and are sorted by path, so each subdirectory's entries are contiguous.
+// Subdirectories are written concurrently while workers has a free slot,
+// and inline otherwise, so a deep tree cannot starve itself of workers.
+// Their results land in fixed slots, so the tree and the dry-run lines it
+// returns come out the same on every run.
+func writeIndexSubtree(entries []*IndexEntry, dir string, workers chan struct{}) (string, []string, error) {
+	type subtree struct {
+		hash  string
+		lines []string
+		err   error
+	}
+	var treeEntries []TreeEntry
+	var subtrees []*subtree
+	var wg sync.WaitGroup
+	for i := 0; i < len(entries); {
+		name, _, isDir := strings.Cut(entries[i].Path[len(dir):], "/")
+		if !isDir {
 			treeEntries = append(treeEntries, TreeEntry{
-				Mode: "40000",
-				Name: entry.Name(),
-				Hash: hash,
+				Mode: entries[i].ModeString(),
+				Name: name,
+				Hash: entries[i].Hash,
 			})
-		} else {
-			fileContent, err := os.ReadFile(fullPath)
-			if err != nil {
-				return "", err
-			}
+			i++
+			continue
+		}
 
-			hash, err := writeObject("blob", fileContent)
-			if err != nil {
-				return "", err
+		subdir := dir + name + "/"
+		j := i
+		for j < len(entries) && strings.HasPrefix(entries[j].Path, subdir) {
+			j++
+		}
+		s := &subtree{}
+		subtrees = append(subtrees, s)
+		treeEntries = append(treeEntries, TreeEntry{Mode: "40000", Name: name})
+		write := func(entries []*IndexEntry) {
+			s.hash, s.lines, s.err = writeIndexSubtree(entries, subdir, workers)
+			if s.err == nil {
+				s.lines = append(s.lines, fmt.Sprintf("tree %s\t%s", s.hash, strings.TrimSuffix(subdir, "/")))
 			}
+		}
+		select {
+		case workers <- struct{}{}:
+			wg.Add(1)
+			go func(entries []*IndexEntry) {
+				defer wg.Done()
+				defer func() { <-workers }()
+				write(entries)
+			}(entries[i:j])
+		default:
+			write(entries[i:j])
+		}
+		i = j
+	}
+	wg.Wait()
 
-			mode := "100644"
-			info, err := entry.Info()
-			if err == nil && info.Mode()&0111 != 0 {
-				mode = "100755"
-			}
+	var lines []string
+	n := 0
+	for k := range treeEntries {
+		if treeEntries[k].Mode != "40000" {
+			continue
+		}
+		s := subtrees[n]
+		n++
+		if s.err != nil {
+			return "", nil, s.err
+		}
+		treeEntries[k].Hash = s.hash
+		lines = append(lines, s.lines...)
+	}
+	hash, err := writeTreeObject(treeEntries)
+	return hash, lines, err
+}
 
-			treeEntries = append(treeEntries, TreeEntry{
-				Mode: mode,
-				Name: entry.Name(),
-				Hash: hash,
-			})
+// writeTreeObject sorts entries and stores them as a tree object.
+func writeTreeObject(treeEntries []TreeEntry) (string, error) {
+	content, err := object.EncodeTree(treeEntries)
+	if err != nil {
+		return "", err
+	}
+	return writeObject("tree", content)
+}
+
+// convertToGit applies the core.autocrlf input conversion: when it is set
+// to true or input, CRLF line endings in text files are normalised to LF
+// before hashing so trees hash the same on every platform. Binary content
+// is left untouched.
+func convertToGit(content []byte) ([]byte, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return nil, err
+	}
+	if !autocrlfEnabled(repo.Config) {
+		return content, nil
+	}
+	if isBinary(content) || !bytes.Contains(content, []byte("\r\n")) {
+		return content, nil
+	}
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), nil
+}
+
+// autocrlfEnabled reports whether core.autocrlf asks for CRLF conversion
+// when content is stored.
+func autocrlfEnabled(config *Config) bool {
+	autocrlf, _ := config.Get("core", "autocrlf")
+	return autocrlf == "input" || config.Bool("core", "autocrlf", false)
+}
+
+func isSubmodule(dirPath string) bool {
+	_, err := os.Lstat(filepath.Join(dirPath, ".git"))
+	return err == nil
+}
+
+// submoduleHead returns the commit checked out in the repository at
+// dirPath. Its .git may be a directory or a "gitdir: <path>" file.
+func submoduleHead(dirPath string) (string, error) {
+	sub, err := repo.Open(dirPath)
+	if err != nil {
+		return "", err
+	}
+	defer sub.Close()
+	hash, err := sub.Refs.Resolve("HEAD")
+	if errors.Is(err, errRefNotFound) {
+		return "", fmt.Errorf("'%s/' does not have a commit checked out", filepath.ToSlash(dirPath))
+	}
+	return hash, err
+}
+
+// readObject returns the type and content of the object with the given
+// hash, looking at loose objects first and then at packs, in the local
+// object directory and its alternates. A partial clone fetches objects it
+// lacks from its promisor remote. Objects are
+// cached on the repository, so the returned content must not be modified.
+func readObject(hash string) (string, []byte, error) {
+	r, err := currentRepo()
+	if err != nil {
+		return "", nil, err
+	}
+	if objectType, content, ok := r.objects.get(hash); ok {
+		return objectType, content, nil
+	}
+
+	objectType, content, err := r.ReadObject(hash)
+	if errors.Is(err, repo.ErrNotFound) {
+		if fetched, fetchErr := fetchPromised([]string{hash}); fetchErr != nil {
+			return "", nil, fetchErr
+		} else if fetched {
+			objectType, content, err = r.ReadObject(hash)
 		}
 	}
+	if err != nil {
+		return "", nil, err
+	}
+	r.objects.add(hash, objectType, content)
+	return objectType, content, nil
+}
 
-	sort.Slice(treeEntries, func(i, j int) bool {
-		return treeEntries[i].Name < treeEntries[j].Name
-	})
+// rawObject returns the inflated bytes of an object, header included,
+// without validating them. On a read error it also returns whatever was
+// inflated before the failure. Packed objects have no stored header, so
+// one is rebuilt from the type and size.
+func rawObject(hash string) ([]byte, error) {
+	r, err := currentRepo()
+	if err != nil {
+		return nil, err
+	}
+	f, path, err := r.OpenLoose(hash)
+	if errors.Is(err, os.ErrNotExist) {
+		objectType, content, err := r.ReadObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		return object.Encode(objectType, content), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	var treeContent bytes.Buffer
-	for _, entry := range treeEntries {
-		//<mode> <name>\0<20-byte-hash>
-		treeContent.WriteString(entry.Mode)
-		treeContent.WriteByte(' ')
-		treeContent.WriteString(entry.Name)
-		treeContent.WriteByte(0)
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %s: corrupt loose object: %w", hash, path, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return data, fmt.Errorf("object %s: %s: corrupt loose object: %w", hash, path, err)
+	}
+	return data, nil
+}
 
-		hashBytes, err := hex.DecodeString(entry.Hash)
+// openObject returns the type and size of an object and a reader for its
+// content. Loose objects are inflated as they are read, so large blobs are
+// never held in memory; packed objects are read whole.
+func openObject(hash string) (string, int64, io.ReadCloser, error) {
+	r, err := currentRepo()
+	if err != nil {
+		return "", 0, nil, err
+	}
+	f, path, err := r.OpenLoose(hash)
+	if errors.Is(err, os.ErrNotExist) {
+		objectType, content, err := readObject(hash)
 		if err != nil {
-			return "", err
+			return "", 0, nil, err
 		}
-		treeContent.Write(hashBytes)
+		return objectType, int64(len(content)), io.NopCloser(bytes.NewReader(content)), nil
+	}
+	if err != nil {
+		return "", 0, nil, err
+	}
+	objectType, size, content, err := object.OpenLoose(f)
+	if err != nil {
+		f.Close()
+		return "", 0, nil, fmt.Errorf("object %s: %s: %w", hash, path, err)
 	}
+	return objectType, size, struct {
+		io.Reader
+		io.Closer
+	}{content, f}, nil
+}
 
-	return writeObject("tree", treeContent.Bytes())
+// objectHeader returns the type and size of an object. Loose objects are
+// only inflated far enough to read their header, so this is cheap even for
+// very large blobs. A partial clone fetches the object if it is missing.
+func objectHeader(hash string) (string, int64, error) {
+	r, err := currentRepo()
+	if err != nil {
+		return "", 0, err
+	}
+	objectType, size, err := r.ObjectHeader(hash)
+	if errors.Is(err, repo.ErrNotFound) {
+		if fetched, fetchErr := fetchPromised([]string{hash}); fetchErr != nil {
+			return "", 0, fetchErr
+		} else if fetched {
+			return r.ObjectHeader(hash)
+		}
+	}
+	return objectType, size, err
 }
 
-func writeObject(objectType string, content []byte) (string, error) {
-	header := fmt.Sprintf("%s %d\x00", objectType, len(content))
-	fullContent := append([]byte(header), content...)
+// displayMode pads a tree entry mode to the six digits git prints; trees
+// are stored as "40000" but shown as "040000".
+func displayMode(mode string) string {
+	if len(mode) < 6 {
+		return strings.Repeat("0", 6-len(mode)) + mode
+	}
+	return mode
+}
 
-	hash := computeHash(fullContent)
+// lsTreeEntry is the --json form of a tree entry. Size is only set for
+// blobs with -l.
+type lsTreeEntry struct {
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	Hash string `json:"hash"`
+	Name string `json:"name"`
+	Size *int64 `json:"size,omitempty"`
+}
 
-	var compressed bytes.Buffer
-	writer := zlib.NewWriter(&compressed)
-	if _, err := writer.Write(fullContent); err != nil {
-		return "", err
+func writeLsTreeJSON(entries []TreeEntry, long bool) {
+	list := make([]lsTreeEntry, 0, len(entries))
+	for _, entry := range entries {
+		mode := displayMode(entry.Mode)
+		item := lsTreeEntry{Mode: mode, Type: gitModes[mode], Hash: entry.Hash, Name: entry.Name}
+		if long && item.Type == "blob" {
+			_, size, err := objectHeader(entry.Hash)
+			if err != nil {
+				handleError(err)
+			}
+			item.Size = &size
+		}
+		list = append(list, item)
 	}
-	if err := writer.Close(); err != nil {
+	writeJSON(list)
+}
+
+// formatTreeEntry formats an entry as "<mode> <type> <hash>\t<name>".
+func formatTreeEntry(entry TreeEntry) string {
+	mode := displayMode(entry.Mode)
+	return fmt.Sprintf("%s %s %s\t%s", mode, gitModes[mode], entry.Hash, entry.Name)
+}
+
+// treeEntrySize returns the size column shown by ls-tree -l: the blob size
+// for blobs and "-" for trees and gitlinks.
+func treeEntrySize(entry TreeEntry) (string, error) {
+	if gitModes[displayMode(entry.Mode)] != "blob" {
+		return "-", nil
+	}
+	_, size, err := objectHeader(entry.Hash)
+	if err != nil {
 		return "", err
 	}
+	return strconv.FormatInt(size, 10), nil
+}
 
-	dir := fmt.Sprintf(".git/objects/%s", hash[:2])
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
+// dryRun makes writeObject compute hashes without storing anything, for
+// commands run with --dry-run.
+var dryRun bool
+
+// writeObject stores an object and returns its hash. Content already in
+// the object store is only hashed, so rewriting an unchanged tree costs no
+// compression or writes.
+func writeObject(objectType string, content []byte) (string, error) {
+	if !dryRun {
+		if hash := object.Hash(objectType, content); objectStored(hash) {
+			return hash, nil
+		}
 	}
+	return streamObject(objectType, int64(len(content)), bytes.NewReader(content), !dryRun)
+}
 
-	objectPath := fmt.Sprintf("%s/%s", dir, hash[2:])
-	if err := os.WriteFile(objectPath, compressed.Bytes(), 0644); err != nil {
+// objectStored reports whether hash is already stored, loose or packed, in
+// the object directory or an alternate.
+func objectStored(hash string) bool {
+	r, err := currentRepo()
+	return err == nil && r.HasObject(hash)
+}
+
+// streamObject hashes an object of the given type whose size bytes of
+// content are read from r, and stores it when write is set, without
+// holding the content in memory.
+func streamObject(objectType string, size int64, r io.Reader, write bool) (string, error) {
+	if !write {
+		return object.HashReader(objectType, size, r)
+	}
+	repo, err := currentRepo()
+	if err != nil {
 		return "", err
 	}
-
-	return hash, nil
+	return repo.WriteObject(objectType, size, r)
 }
 
 func formatGitTimestamp(t time.Time) string {
 	timestamp := t.Unix()
 	_, offset := t.Zone()
-	hours := offset / 3600
-	minutes := (offset % 3600) / 60
-	timezone := fmt.Sprintf("%+03d%02d", hours, minutes)
-	return fmt.Sprintf("%d %s", timestamp, timezone)
+	// The sign is written separately: an offset such as -02:30 has a zero
+	// hour that %+d would print as "+00".
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%d %c%02d%02d", timestamp, sign, offset/3600, (offset%3600)/60)
 }
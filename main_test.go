@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// gotBinary is got built for the tests, which mostly run it as a user would:
+// commands keep their state in package variables and exit on errors, so
+// they cannot run one after another in the test process. testHome stands
+// in for the user's home directory, keeping their config out. fakeSSH is
+// an ssh that runs the command on the local machine, so that remotes can be
+// tested over ssh:// URLs.
+var gotBinary, testHome, fakeSSH string
+
+const fakeSSHScript = `#!/bin/sh
+while [ "${1#-}" != "$1" ]; do shift 2; done
+shift
+exec sh -c "$1"
+`
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "got-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	gotBinary, testHome, fakeSSH = filepath.Join(dir, "got"), filepath.Join(dir, "home"), filepath.Join(dir, "ssh")
+	err = os.Mkdir(testHome, 0755)
+	if err == nil {
+		err = os.WriteFile(fakeSSH, []byte(fakeSSHScript), 0755)
+	}
+	if err == nil {
+		var out []byte
+		if out, err = exec.Command("go", "build", "-o", gotBinary, ".").CombinedOutput(); err != nil {
+			err = fmt.Errorf("building got: %v\n%s", err, out)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestRepo creates an empty repository with git, on branch main, and
+// returns its work tree.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	return dir
+}
+
+// newTestRemote creates an empty bare repository with git and returns an
+// ssh:// URL for it.
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, filepath.Dir(dir), "init", "-q", "--bare", "-b", "main", dir)
+	return "ssh://localhost" + filepath.ToSlash(dir)
+}
+
+// runCommand runs name in dir with a fixed identity and clock, returning
+// its standard output and, on failure, an error holding its standard error.
+func runCommand(dir, name string, args ...string) (string, error) {
+	out, stderr, err := runCommandStderr(dir, name, args...)
+	if err != nil {
+		return out, fmt.Errorf("%s %s: %v\n%s", filepath.Base(name), strings.Join(args, " "), err, stderr)
+	}
+	return out, nil
+}
+
+// runCommandStderr is runCommand for callers that want the standard error
+// of a command that succeeds too.
+func runCommandStderr(dir, name string, args ...string) (string, string, error) {
+	return runCommandInput(dir, "", name, args...)
+}
+
+// runCommandInput is runCommandStderr with input on standard input.
+func runCommandInput(dir, input, name string, args ...string) (string, string, error) {
+	return runCommandEnv(dir, input, nil, name, args...)
+}
+
+// runCommandEnv is runCommandInput with env added to, and overriding, the
+// fixed environment.
+func runCommandEnv(dir, input string, env []string, name string, args ...string) (string, string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"HOME="+testHome,
+		"XDG_CONFIG_HOME="+testHome,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_SSH_COMMAND="+fakeSSH,
+		"GIT_SSH_VARIANT=simple",
+		"GIT_AUTHOR_NAME=A U Thor",
+		"GIT_AUTHOR_EMAIL=author@example.com",
+		"GIT_AUTHOR_DATE=1700000000 +0000",
+		"GIT_COMMITTER_NAME=C O Mitter",
+		"GIT_COMMITTER_EMAIL=committer@example.com",
+		"GIT_COMMITTER_DATE=1700000000 +0000",
+	)
+	cmd.Env = append(cmd.Env, env...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	return string(out), stderr.String(), err
+}
+
+// runGit runs git in dir and returns its output, failing the test if it
+// fails.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runCommand(dir, "git", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// runGot runs got in dir and returns its output, failing the test if it
+// fails.
+func runGot(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runCommand(dir, gotBinary, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// commitFile writes content to name in the work tree at dir and commits it
+// with git.
+func commitFile(t *testing.T, dir, name, content, message string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "-q", "-m", message)
+}
+
+func TestWriteTreeOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{"dotted file and directory", []string{"foo.c", "foo/x"}},
+		{"file, dotted file and directory", []string{"foo", "foo.c", "foo0/x", "foo-bar/x"}},
+		{"nested", []string{"a/foo.c", "a/foo/x", "a/foo0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+			for _, name := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			runGit(t, dir, "add", ".")
+			want := runGit(t, dir, "write-tree")
+			if got := runGot(t, dir, "write-tree"); got != want {
+				t.Errorf("write-tree = %s, git has %s", strings.TrimSpace(got), strings.TrimSpace(want))
+			}
+		})
+	}
+}
+
+// BenchmarkWriteBlobs stores a directory of 5000 small files, with one
+// worker and with GOMAXPROCS of them.
+func BenchmarkWriteBlobs(b *testing.B) {
+	b.Chdir(b.TempDir())
+	if err := createGitDir(".git", false); err != nil {
+		b.Fatal(err)
+	}
+	var names []string
+	for i := 0; i < 5000; i++ {
+		name := fmt.Sprintf("file%04d.txt", i)
+		if err := os.WriteFile(name, []byte(strings.Repeat(name+"\n", 50)), 0644); err != nil {
+			b.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	saved := blobWorkers
+	defer func() { blobWorkers = saved }()
+	for _, bm := range []struct {
+		name    string
+		workers int
+	}{
+		{"serial", 1},
+		{"parallel", runtime.GOMAXPROCS(0)},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			blobWorkers = bm.workers
+			for b.Loop() {
+				// Start from an empty object directory, so every blob is
+				// written rather than found.
+				b.StopTimer()
+				if err := os.RemoveAll(filepath.Join(".git", "objects")); err != nil {
+					b.Fatal(err)
+				}
+				if err := os.Mkdir(filepath.Join(".git", "objects"), 0755); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				if _, err := writeBlobs(".", names); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWriteIndexTree writes the trees for an index of 20000 files in
+// 400 nested directories, with one writer and with GOMAXPROCS of them. The
+// blobs are left out, so what is measured is building, hashing and
+// compressing the trees.
+func BenchmarkWriteIndexTree(b *testing.B) {
+	b.Chdir(b.TempDir())
+	if err := createGitDir(".git", false); err != nil {
+		b.Fatal(err)
+	}
+	idx := &Index{}
+	for i := 0; i < 20000; i++ {
+		sum := sha1.Sum([]byte(fmt.Sprint(i)))
+		path := fmt.Sprintf("d%02d/e%d/f%d/file%05d.txt", i%20, i/20%10, i/200%2, i)
+		entry, err := newIndexEntry(path, "100644", hex.EncodeToString(sum[:]), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		idx.Entries = append(idx.Entries, entry)
+	}
+	sort.Slice(idx.Entries, func(i, j int) bool { return idx.Entries[i].Path < idx.Entries[j].Path })
+
+	saved := treeWorkers
+	defer func() { treeWorkers = saved }()
+	for _, bm := range []struct {
+		name    string
+		workers int
+	}{
+		{"serial", 1},
+		{"parallel", runtime.GOMAXPROCS(0)},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			treeWorkers = bm.workers
+			for b.Loop() {
+				// Start from an empty object directory, so every tree is
+				// written rather than found.
+				b.StopTimer()
+				if err := os.RemoveAll(filepath.Join(".git", "objects")); err != nil {
+					b.Fatal(err)
+				}
+				if err := os.Mkdir(filepath.Join(".git", "objects"), 0755); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				if _, err := writeIndexTree(idx, "", true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,758 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mergeHeadPath and mergeMsgPath hold the commit being merged and the
+// message for the merge commit while a merge is in progress.
+func mergeHeadPath() string { return gitPath("MERGE_HEAD") }
+func mergeMsgPath() string  { return gitPath("MERGE_MSG") }
+
+// mergeConflict is a path the merge could not resolve. Base, Ours and
+// Theirs have an empty Mode when the path is absent on that side. Content
+// is the working tree file with conflict markers, when the sides could be
+// merged line by line.
+type mergeConflict struct {
+	Path               string
+	Base, Ours, Theirs TreeEntry
+	Content            []byte
+	Message            string
+}
+
+func runMerge(args []string) {
+	usage := errors.New("usage: got merge [--no-ff | --ff-only] [-m <message>] <commit> | --abort")
+	var noFF, ffOnly, abort bool
+	var message, target string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--no-ff":
+			noFF = true
+		case arg == "--ff-only":
+			ffOnly = true
+		case arg == "--abort":
+			abort = true
+		case arg == "-m" || arg == "--message":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			message = args[i]
+		case target == "" && !strings.HasPrefix(arg, "-"):
+			target = arg
+		default:
+			handleError(usage)
+		}
+	}
+	if abort {
+		if target != "" {
+			handleError(usage)
+		}
+		if err := abortMerge(); err != nil {
+			handleError(err)
+		}
+		return
+	}
+	if target == "" || (noFF && ffOnly) {
+		handleError(usage)
+	}
+	if _, err := os.Stat(mergeHeadPath()); err == nil {
+		handleError(errors.New("you have not concluded your merge (MERGE_HEAD exists)\nPlease commit your changes before you merge"))
+	}
+
+	head, err := resolveRef("HEAD")
+	if err != nil {
+		handleError(err)
+	}
+	other, err := resolveRevision(target)
+	if err != nil {
+		handleError(err)
+	}
+	if other, err = peelToCommit(other); err != nil {
+		handleError(err)
+	}
+	if message == "" {
+		if message, err = defaultMergeMessage(target, head, other); err != nil {
+			handleError(err)
+		}
+	}
+
+	bases, err := mergeBases(head, other)
+	if err != nil {
+		handleError(err)
+	}
+	if len(bases) == 0 {
+		handleError(errors.New("refusing to merge unrelated histories"))
+	}
+	for _, base := range bases {
+		if base == other {
+			fmt.Println("Already up to date.")
+			return
+		}
+	}
+
+	branch, onBranch, err := currentBranch()
+	if err != nil {
+		handleError(err)
+	}
+	ref := "HEAD"
+	if onBranch {
+		ref = "refs/heads/" + branch
+	}
+	if err := writeRef("ORIG_HEAD", head); err != nil {
+		handleError(err)
+	}
+
+	if len(bases) == 1 && bases[0] == head && !noFF {
+		fmt.Printf("Updating %s..%s\n", head[:7], other[:7])
+		if err := switchTree(other, false); err != nil {
+			handleError(err)
+		}
+		if err := updateMergedRef(ref, onBranch, head, other, fmt.Sprintf("merge %s: Fast-forward", target)); err != nil {
+			handleError(err)
+		}
+		fmt.Println("Fast-forward")
+		return
+	}
+	if ffOnly {
+		handleError(errors.New("not possible to fast-forward, aborting"))
+	}
+
+	conflicts, err := mergeIntoWorktree(bases, head, other, target)
+	if err != nil {
+		handleError(err)
+	}
+	// MERGE_MSG is written for every merge commit, as git does; it stays
+	// behind only for commit to conclude a conflicted merge with.
+	msg := message + "\n"
+	if len(conflicts) > 0 {
+		msg += "\n# Conflicts:\n"
+		for _, c := range conflicts {
+			msg += "#\t" + c.Path + "\n"
+		}
+	}
+	if err := os.WriteFile(mergeMsgPath(), []byte(msg), 0644); err != nil {
+		handleError(err)
+	}
+	if len(conflicts) > 0 {
+		if err := os.WriteFile(mergeHeadPath(), []byte(other+"\n"), 0644); err != nil {
+			handleError(err)
+		}
+		fmt.Println("Automatic merge failed; fix conflicts and then commit the result.")
+		exit(1)
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+	tree, err := writeIndexTree(idx, "", false)
+	if err != nil {
+		handleError(err)
+	}
+	hash, err := createCommit(tree, []string{head, other}, cleanupMessage(message))
+	if err != nil {
+		handleError(err)
+	}
+	if err := updateMergedRef(ref, onBranch, head, hash, fmt.Sprintf("merge %s: Merge made by the 'recursive' strategy.", target)); err != nil {
+		handleError(err)
+	}
+	if err := clearMergeState(); err != nil {
+		handleError(err)
+	}
+	fmt.Println("Merge made by the 'recursive' strategy.")
+}
+
+// mergeLogLimit is how many merged commits defaultMergeMessage lists, as
+// in git's merge.log.
+const mergeLogLimit = 20
+
+// defaultMergeMessage names what is merged the way git does, followed by
+// the subjects of the commits other brings in that head does not have,
+// newest first and leaving out merges.
+func defaultMergeMessage(target, head, other string) (string, error) {
+	var message string
+	if exists, _ := refExists("refs/heads/" + target); exists {
+		message = fmt.Sprintf("Merge branch '%s'", target)
+	} else if exists, _ := refExists("refs/remotes/" + target); exists {
+		message = fmt.Sprintf("Merge remote-tracking branch '%s'", target)
+	} else if exists, _ := refExists("refs/tags/" + target); exists {
+		message = fmt.Sprintf("Merge tag '%s'", target)
+	} else {
+		message = fmt.Sprintf("Merge commit '%s'", target)
+	}
+
+	ours, err := ancestors(head)
+	if err != nil {
+		return "", err
+	}
+	var subjects []string
+	more := false
+	err = walkCommits(other, func(c *logCommit) bool {
+		if ours[c.Hash] || len(c.Commit.Parents) > 1 {
+			return true
+		}
+		if len(subjects) == mergeLogLimit {
+			more = true
+			return false
+		}
+		subject, _ := splitMessage(c.Commit.Message)
+		subjects = append(subjects, subject)
+		return true
+	})
+	if err != nil || len(subjects) == 0 {
+		return message, err
+	}
+	message += "\n\n* " + target + ":\n"
+	for _, subject := range subjects {
+		message += "  " + subject + "\n"
+	}
+	if more {
+		message += "  ...\n"
+	}
+	return strings.TrimSuffix(message, "\n"), nil
+}
+
+// updateMergedRef moves the current branch, or a detached HEAD, from old
+// to new and logs it.
+func updateMergedRef(ref string, onBranch bool, old, new, reason string) error {
+	if err := writeRef(ref, new); err != nil {
+		return err
+	}
+	if err := appendReflog("HEAD", old, new, reason); err != nil {
+		return err
+	}
+	if onBranch {
+		return appendReflog(ref, old, new, reason)
+	}
+	return nil
+}
+
+// abortMerge throws away a conflicted merge: the index and working tree go
+// back to HEAD and the merge state is removed.
+func abortMerge() error {
+	if _, err := os.Stat(mergeHeadPath()); err != nil {
+		return errors.New("there is no merge to abort (MERGE_HEAD missing)")
+	}
+	head, err := resolveRef("HEAD")
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	if err := switchTree(head, true); err != nil {
+		return err
+	}
+	// switchTree keeps files HEAD does not track, but a conflicted file
+	// that only the merge brought in goes too.
+	headTree, err := peelToTree(head)
+	if err != nil {
+		return err
+	}
+	for _, entry := range idx.Entries {
+		if entry.Stage() == 0 {
+			continue
+		}
+		if _, err := lookupTreePath(headTree, entry.Path); errors.Is(err, os.ErrNotExist) {
+			if err := removeWorktreeFile(entry.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return clearMergeState()
+}
+
+// clearMergeState removes the files a conflicted merge leaves for commit.
+func clearMergeState() error {
+	for _, path := range []string{mergeHeadPath(), mergeMsgPath()} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeBaseTree returns the tree to use as the base of a three-way merge.
+// Several merge bases, as criss-cross merges leave, are merged into one
+// virtual base first, recursively.
+func mergeBaseTree(bases []string) (string, error) {
+	tree, err := peelToTree(bases[0])
+	if err != nil {
+		return "", err
+	}
+	for _, next := range bases[1:] {
+		nextTree, err := peelToTree(next)
+		if err != nil {
+			return "", err
+		}
+		inner, err := mergeBases(bases[0], next)
+		if err != nil {
+			return "", err
+		}
+		innerTree := ""
+		if len(inner) > 0 {
+			if innerTree, err = mergeBaseTree(inner); err != nil {
+				return "", err
+			}
+		}
+		// Conflicts in the virtual base keep the first side; the real
+		// merge then reports them against that.
+		if tree, _, err = mergeTrees(innerTree, tree, nextTree, ""); err != nil {
+			return "", err
+		}
+	}
+	return tree, nil
+}
+
+// mergeIntoWorktree merges other into HEAD, updating the index and the
+// working tree. Conflicted paths are left with their stages in the index
+// and conflict markers in the file, and are returned.
+func mergeIntoWorktree(bases []string, head, other, label string) ([]mergeConflict, error) {
+	baseTree, err := mergeBaseTree(bases)
+	if err != nil {
+		return nil, err
+	}
+	oursTree, err := peelToTree(head)
+	if err != nil {
+		return nil, err
+	}
+	theirsTree, err := peelToTree(other)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTreesIntoWorktree(baseTree, oursTree, theirsTree, "HEAD", label)
+}
+
+// mergeTreesIntoWorktree merges theirsTree into oursTree, which the index
+// must match, the way mergeIntoWorktree does. The labels name the sides in
+// conflict messages and markers.
+func mergeTreesIntoWorktree(baseTree, oursTree, theirsTree, oursLabel, theirsLabel string) ([]mergeConflict, error) {
+	baseFiles, err := flattenTree(baseTree)
+	if err != nil {
+		return nil, err
+	}
+	ours, err := flattenTree(oursTree)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := flattenTree(theirsTree)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, conflicts, err := mergeFiles(baseFiles, ours, theirs, oursLabel, theirsLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paths whose result differs from HEAD are about to be rewritten.
+	var changed []string
+	conflicted := make(map[string]*mergeConflict)
+	for i := range conflicts {
+		conflicted[conflicts[i].Path] = &conflicts[i]
+		changed = append(changed, conflicts[i].Path)
+	}
+	for _, path := range unionPaths(ours, merged) {
+		if _, ok := conflicted[path]; ok {
+			continue
+		}
+		if o, m := ours[path], merged[path]; o.Hash != m.Hash || o.Mode != m.Mode {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	if err := checkFileDirectoryConflicts(merged, conflicts); err != nil {
+		return nil, err
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMergeClean(idx, ours, changed); err != nil {
+		return nil, err
+	}
+
+	// Deletions go first, as in switchTree.
+	for _, path := range changed {
+		if _, ok := merged[path]; !ok && conflicted[path] == nil {
+			idx.remove(path)
+			if err := removeWorktreeFile(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, path := range changed {
+		if c := conflicted[path]; c != nil {
+			if err := writeConflict(idx, c); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		entry, ok := merged[path]
+		if !ok {
+			continue
+		}
+		if err := checkoutEntry(path, entry); err != nil {
+			return nil, err
+		}
+		info, err := os.Lstat(filepath.FromSlash(path))
+		if err != nil {
+			return nil, err
+		}
+		indexEntry, err := newIndexEntry(path, entry.Mode, entry.Hash, info)
+		if err != nil {
+			return nil, err
+		}
+		idx.add(indexEntry)
+	}
+	if err := idx.write(); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// checkMergeClean refuses a merge when the index has staged changes, or
+// when a path the merge rewrites has local changes or an untracked file in
+// the way.
+func checkMergeClean(idx *Index, ours map[string]TreeEntry, changed []string) error {
+	var staged []string
+	seen := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		seen[entry.Path] = true
+		if o, ok := ours[entry.Path]; !ok || entry.Stage() != 0 || o.Hash != entry.Hash || o.Mode != entry.ModeString() {
+			staged = append(staged, entry.Path)
+		}
+	}
+	for path := range ours {
+		if !seen[path] {
+			staged = append(staged, path)
+		}
+	}
+	if len(staged) > 0 {
+		sort.Strings(staged)
+		return fmt.Errorf("your local changes to the following files would be overwritten by merge:\n\t%s\nPlease commit your changes or stash them before you merge.\nAborting", strings.Join(staged, "\n\t"))
+	}
+
+	var dirty, untracked []string
+	for _, path := range changed {
+		entry := idx.entry(path)
+		if entry == nil {
+			if _, err := os.Lstat(filepath.FromSlash(path)); err == nil {
+				untracked = append(untracked, path)
+			}
+			continue
+		}
+		if _, err := os.Lstat(filepath.FromSlash(path)); errors.Is(err, os.ErrNotExist) {
+			dirty = append(dirty, path)
+			continue
+		}
+		modified, err := worktreeModified(entry)
+		if err != nil {
+			return err
+		}
+		if modified {
+			dirty = append(dirty, path)
+		}
+	}
+	if len(dirty) > 0 {
+		return fmt.Errorf("your local changes to the following files would be overwritten by merge:\n\t%s\nPlease commit your changes or stash them before you merge.\nAborting", strings.Join(dirty, "\n\t"))
+	}
+	if len(untracked) > 0 {
+		return fmt.Errorf("the following untracked working tree files would be overwritten by merge:\n\t%s\nPlease move or remove them before you merge.\nAborting", strings.Join(untracked, "\n\t"))
+	}
+	return nil
+}
+
+// checkFileDirectoryConflicts rejects results where a file would sit at a
+// path another file needs as a directory, which the merge cannot express.
+func checkFileDirectoryConflicts(merged map[string]TreeEntry, conflicts []mergeConflict) error {
+	paths := make(map[string]bool, len(merged))
+	for path := range merged {
+		paths[path] = true
+	}
+	for _, c := range conflicts {
+		paths[c.Path] = true
+	}
+	for path := range paths {
+		for dir := path; strings.Contains(dir, "/"); {
+			dir = dir[:strings.LastIndex(dir, "/")]
+			if paths[dir] {
+				return fmt.Errorf("CONFLICT (file/directory): %s is a file on one side and a directory on the other; merging it is not supported", dir)
+			}
+		}
+	}
+	return nil
+}
+
+// writeConflict records a conflicted path: its stages in the index and
+// the best working tree file, with markers when there are any.
+func writeConflict(idx *Index, c *mergeConflict) error {
+	fmt.Println(c.Message)
+	idx.remove(c.Path)
+	for stage, entry := range []TreeEntry{c.Base, c.Ours, c.Theirs} {
+		if entry.Mode == "" {
+			continue
+		}
+		indexEntry, err := newIndexEntry(c.Path, entry.Mode, entry.Hash, nil)
+		if err != nil {
+			return err
+		}
+		indexEntry.Flags |= uint16(stage+1) << 12
+		idx.Entries = append(idx.Entries, indexEntry)
+	}
+	idx.sort()
+
+	switch {
+	case c.Content != nil:
+		mode := c.Ours.Mode
+		if mode == "" {
+			mode = c.Theirs.Mode
+		}
+		perm := os.FileMode(0644)
+		if mode == "100755" {
+			perm = 0755
+		}
+		content, err := convertToWorktree(c.Content)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.FromSlash(c.Path), content, perm)
+	case c.Ours.Mode == "" && c.Theirs.Mode != "":
+		// Deleted here but changed there: leave their version to look at.
+		return checkoutEntry(c.Path, c.Theirs)
+	}
+	return nil
+}
+
+// mergeFiles merges three flattened trees path by path. Files changed on
+// both sides are merged line by line; what cannot be merged is returned as
+// a conflict instead of in the merged map.
+func mergeFiles(base, ours, theirs map[string]TreeEntry, oursLabel, label string) (map[string]TreeEntry, []mergeConflict, error) {
+	merged := make(map[string]TreeEntry)
+	var conflicts []mergeConflict
+	names := unionPaths(base, unionMap(ours, theirs))
+	for _, path := range names {
+		b, inBase := base[path]
+		o, inOurs := ours[path]
+		t, inTheirs := theirs[path]
+
+		switch {
+		case sameEntry(o, inOurs, t, inTheirs):
+			if inOurs {
+				merged[path] = o
+			}
+			continue
+		case sameEntry(b, inBase, o, inOurs):
+			if inTheirs {
+				merged[path] = t
+			}
+			continue
+		case sameEntry(b, inBase, t, inTheirs):
+			if inOurs {
+				merged[path] = o
+			}
+			continue
+		}
+
+		c := mergeConflict{Path: path, Base: b, Ours: o, Theirs: t}
+		switch {
+		case !inOurs || !inTheirs:
+			deletedIn, modifiedIn := label, oursLabel
+			if !inOurs {
+				deletedIn, modifiedIn = oursLabel, label
+			}
+			c.Message = fmt.Sprintf("CONFLICT (modify/delete): %s deleted in %s and modified in %s. Version %s of %s left in tree.", path, deletedIn, modifiedIn, modifiedIn, path)
+			conflicts = append(conflicts, c)
+			continue
+		case !isRegularFileMode(o.Mode) || !isRegularFileMode(t.Mode) || (inBase && !isRegularFileMode(b.Mode)):
+			c.Message = fmt.Sprintf("CONFLICT (content): Merge conflict in %s", path)
+			conflicts = append(conflicts, c)
+			continue
+		}
+
+		// Both sides changed a regular file: merge the contents, and the
+		// executable bit separately.
+		fmt.Printf("Auto-merging %s\n", path)
+		mode := o.Mode
+		if o.Mode != t.Mode && inBase && b.Mode == o.Mode {
+			mode = t.Mode
+		}
+		modeConflict := o.Mode != t.Mode && (!inBase || (b.Mode != o.Mode && b.Mode != t.Mode))
+
+		var baseContent []byte
+		if inBase {
+			_, content, err := readObject(b.Hash)
+			if err != nil {
+				return nil, nil, err
+			}
+			baseContent = content
+		}
+		_, oursContent, err := readObject(o.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, theirsContent, err := readObject(t.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isBinary(baseContent) || isBinary(oursContent) || isBinary(theirsContent) {
+			if o.Hash != t.Hash {
+				c.Message = fmt.Sprintf("warning: Cannot merge binary files: %s (%s vs. %s)\nCONFLICT (content): Merge conflict in %s", path, oursLabel, label, path)
+				conflicts = append(conflicts, c)
+				continue
+			}
+		}
+
+		content, clean := mergeLines(baseContent, oursContent, theirsContent, oursLabel, label)
+		if !clean || modeConflict {
+			kind := "content"
+			if !inBase {
+				kind = "add/add"
+			}
+			c.Message = fmt.Sprintf("CONFLICT (%s): Merge conflict in %s", kind, path)
+			c.Content = content
+			conflicts = append(conflicts, c)
+			continue
+		}
+		hash, err := writeObject("blob", content)
+		if err != nil {
+			return nil, nil, err
+		}
+		merged[path] = TreeEntry{Mode: mode, Name: o.Name, Hash: hash}
+	}
+	return merged, conflicts, nil
+}
+
+func isRegularFileMode(mode string) bool {
+	return mode == "100644" || mode == "100755"
+}
+
+// unionMap returns a map holding the keys of both maps; values come from a
+// where both have the key.
+func unionMap(a, b map[string]TreeEntry) map[string]TreeEntry {
+	union := make(map[string]TreeEntry, len(a)+len(b))
+	for k, v := range b {
+		union[k] = v
+	}
+	for k, v := range a {
+		union[k] = v
+	}
+	return union
+}
+
+// mergeLines performs a three-way merge of file contents. Regions changed
+// on only one side take that side; regions changed differently on both are
+// written between conflict markers labelled with oursLabel and
+// theirsLabel. It reports whether the merge was clean.
+func mergeLines(base, ours, theirs []byte, oursLabel, theirsLabel string) ([]byte, bool) {
+	baseLines := splitLines(base)
+	oursLines := splitLines(ours)
+	theirsLines := splitLines(theirs)
+	oursMatch := matchBaseLines(diffLines(baseLines, oursLines), len(baseLines))
+	theirsMatch := matchBaseLines(diffLines(baseLines, theirsLines), len(baseLines))
+
+	var out []string
+	clean := true
+	i, j, k := 0, 0, 0
+	for i < len(baseLines) || j < len(oursLines) || k < len(theirsLines) {
+		// A base line both sides kept where we are is copied through.
+		if i < len(baseLines) && oursMatch[i] == j && theirsMatch[i] == k {
+			out = append(out, baseLines[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+
+		// Otherwise the changed region runs to the next base line that
+		// both sides kept.
+		end := i
+		for end < len(baseLines) && (oursMatch[end] < 0 || theirsMatch[end] < 0) {
+			end++
+		}
+		oursEnd, theirsEnd := len(oursLines), len(theirsLines)
+		if end < len(baseLines) {
+			oursEnd, theirsEnd = oursMatch[end], theirsMatch[end]
+		}
+		b, o, t := baseLines[i:end], oursLines[j:oursEnd], theirsLines[k:theirsEnd]
+		i, j, k = end, oursEnd, theirsEnd
+
+		switch {
+		case equalLines(o, b):
+			out = append(out, t...)
+		case equalLines(t, b) || equalLines(o, t):
+			out = append(out, o...)
+		default:
+			clean = false
+			// Lines both sides share at the edges of the region are not
+			// part of the conflict.
+			head := 0
+			for head < len(o) && head < len(t) && o[head] == t[head] {
+				head++
+			}
+			tail := 0
+			for tail < len(o)-head && tail < len(t)-head && o[len(o)-1-tail] == t[len(t)-1-tail] {
+				tail++
+			}
+			out = append(out, o[:head]...)
+			out = append(out, "<<<<<<< "+oursLabel+"\n")
+			out = appendTerminated(out, o[head:len(o)-tail])
+			out = append(out, "=======\n")
+			out = appendTerminated(out, t[head:len(t)-tail])
+			out = append(out, ">>>>>>> "+theirsLabel+"\n")
+			out = append(out, o[len(o)-tail:]...)
+		}
+	}
+	return []byte(strings.Join(out, "")), clean
+}
+
+// matchBaseLines maps each base line to the index of the same line on the
+// other side of a diff, or -1 when the diff removed it.
+func matchBaseLines(ops []diffOp, n int) []int {
+	match := make([]int, n)
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op.Kind {
+		case ' ':
+			match[i] = j
+			i++
+			j++
+		case '-':
+			match[i] = -1
+			i++
+		case '+':
+			j++
+		}
+	}
+	return match
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTerminated appends lines, adding a newline to the last one if it
+// lacks one so that the conflict marker after it starts a line.
+func appendTerminated(out, lines []string) []string {
+	for i, line := range lines {
+		if i == len(lines)-1 && !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		topic  []string
+		merges bool
+		want   string
+	}{
+		{
+			name:  "one commit",
+			topic: []string{"add t1"},
+			want:  "Merge branch 'topic'\n\n* topic:\n  add t1\n",
+		},
+		{
+			name:  "newest first",
+			topic: []string{"add t1", "add t2", "add t3"},
+			want:  "Merge branch 'topic'\n\n* topic:\n  add t3\n  add t2\n  add t1\n",
+		},
+		{
+			name:   "merges left out",
+			topic:  []string{"add t1"},
+			merges: true,
+			want:   "Merge branch 'topic'\n\n* topic:\n  add t1\n  add side\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+			commitFile(t, dir, "base", "base\n", "base")
+			runGit(t, dir, "checkout", "-q", "-b", "topic")
+			for i, subject := range tt.topic {
+				commitFile(t, dir, "t"+string(rune('1'+i)), subject+"\n", subject)
+			}
+			if tt.merges {
+				runGit(t, dir, "checkout", "-q", "-b", "side", "main")
+				commitFile(t, dir, "side", "side\n", "add side")
+				runGit(t, dir, "checkout", "-q", "topic")
+				runGit(t, dir, "merge", "-q", "--no-ff", "-m", "Merge branch 'side'", "side")
+			}
+			runGit(t, dir, "checkout", "-q", "main")
+			commitFile(t, dir, "main", "main\n", "main")
+
+			runGot(t, dir, "merge", "topic")
+			if got := runGit(t, dir, "log", "-1", "--format=%B"); strings.TrimSpace(got) != strings.TrimSpace(tt.want) {
+				t.Errorf("merge message = %q, want %q", got, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, ".git", "MERGE_MSG")); !os.IsNotExist(err) {
+				t.Errorf("MERGE_MSG left behind after a clean merge: %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeMessageConflict(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "base")
+	runGit(t, dir, "checkout", "-q", "-b", "topic")
+	commitFile(t, dir, "a", "topic\n", "change a on topic")
+	runGit(t, dir, "checkout", "-q", "main")
+	commitFile(t, dir, "a", "main\n", "change a on main")
+
+	if _, err := runCommand(dir, gotBinary, "merge", "topic"); err == nil {
+		t.Fatal("conflicted merge succeeded")
+	}
+	msg, err := os.ReadFile(filepath.Join(dir, ".git", "MERGE_MSG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Merge branch 'topic'\n\n* topic:\n  change a on topic\n\n# Conflicts:\n#\ta\n"
+	if string(msg) != want {
+		t.Errorf("MERGE_MSG = %q, want %q", msg, want)
+	}
+}
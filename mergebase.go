@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ancestors returns hash and every commit reachable from it.
+func ancestors(hash string) (map[string]bool, error) {
+	reachable := make(map[string]bool)
+	err := walkCommits(hash, func(c *logCommit) bool {
+		reachable[c.Hash] = true
+		return true
+	})
+	return reachable, err
+}
+
+// mergeBases returns the best common ancestors of a and b: the commits
+// reachable from both that are not ancestors of another such commit.
+func mergeBases(a, b string) ([]string, error) {
+	fromA, err := ancestors(a)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back from b, stopping at the first commits that a can reach.
+	var candidates []string
+	seen := map[string]bool{b: true}
+	pending := []string{b}
+	for len(pending) > 0 {
+		hash := pending[0]
+		pending = pending[1:]
+		if fromA[hash] {
+			candidates = append(candidates, hash)
+			continue
+		}
+		c, err := readLogCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range c.Commit.Parents {
+			if !seen[parent] {
+				seen[parent] = true
+				pending = append(pending, parent)
+			}
+		}
+	}
+
+	// With criss-cross merges one candidate can be an ancestor of another;
+	// only the newest ones are useful bases.
+	var bases []string
+	for _, candidate := range candidates {
+		redundant := false
+		for _, other := range candidates {
+			if other == candidate {
+				continue
+			}
+			reachable, err := ancestors(other)
+			if err != nil {
+				return nil, err
+			}
+			if reachable[candidate] {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			bases = append(bases, candidate)
+		}
+	}
+	return bases, nil
+}
+
+func runMergeBase(args []string) {
+	all := false
+	var revisions []string
+	for _, arg := range args {
+		if arg == "--all" || arg == "-a" {
+			all = true
+		} else {
+			revisions = append(revisions, arg)
+		}
+	}
+	if len(revisions) != 2 {
+		handleError(errors.New("usage: got merge-base [--all] <commit> <commit>"))
+	}
+
+	var hashes [2]string
+	for i, revision := range revisions {
+		hash, err := resolveRevision(revision)
+		if err != nil {
+			handleError(err)
+		}
+		hashes[i] = hash
+	}
+
+	bases, err := mergeBases(hashes[0], hashes[1])
+	if err != nil {
+		handleError(err)
+	}
+	if len(bases) == 0 {
+		exit(1)
+	}
+	if !all {
+		bases = bases[:1]
+	}
+	for _, base := range bases {
+		fmt.Println(base)
+	}
+}
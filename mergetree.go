@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// sameEntry reports whether two sides of a merge agree, including both
+// being absent.
+func sameEntry(a TreeEntry, inA bool, b TreeEntry, inB bool) bool {
+	if inA != inB {
+		return false
+	}
+	return !inA || (a.Hash == b.Hash && a.Mode == b.Mode)
+}
+
+// mergeTrees performs a three-way merge of trees a and b against their
+// common base and writes the result. Paths changed differently on both
+// sides are returned as conflicts and keep the entry from a. Any hash may
+// be empty to stand for an empty tree.
+func mergeTrees(base, a, b, prefix string) (string, []string, error) {
+	baseEntries, err := readTreeEntries(base)
+	if err != nil {
+		return "", nil, err
+	}
+	aEntries, err := readTreeEntries(a)
+	if err != nil {
+		return "", nil, err
+	}
+	bEntries, err := readTreeEntries(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, entries := range []map[string]TreeEntry{baseEntries, aEntries, bEntries} {
+		for name := range entries {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var merged []TreeEntry
+	var conflicts []string
+	for _, name := range sorted {
+		baseEntry, inBase := baseEntries[name]
+		aEntry, inA := aEntries[name]
+		bEntry, inB := bEntries[name]
+
+		switch {
+		case sameEntry(aEntry, inA, bEntry, inB):
+			if inA {
+				merged = append(merged, aEntry)
+			}
+		case sameEntry(baseEntry, inBase, aEntry, inA):
+			if inB {
+				merged = append(merged, bEntry)
+			}
+		case sameEntry(baseEntry, inBase, bEntry, inB):
+			if inA {
+				merged = append(merged, aEntry)
+			}
+		case inA && inB && object.IsTreeMode(aEntry.Mode) && object.IsTreeMode(bEntry.Mode):
+			// Both sides changed a directory; merge its contents.
+			var baseSub string
+			if inBase && object.IsTreeMode(baseEntry.Mode) {
+				baseSub = baseEntry.Hash
+			}
+			hash, sub, err := mergeTrees(baseSub, aEntry.Hash, bEntry.Hash, prefix+name+"/")
+			if err != nil {
+				return "", nil, err
+			}
+			conflicts = append(conflicts, sub...)
+			if hash != object.EmptyTreeHash {
+				merged = append(merged, TreeEntry{Mode: "40000", Name: name, Hash: hash})
+			}
+		default:
+			conflicts = append(conflicts, prefix+name)
+			if inA {
+				merged = append(merged, aEntry)
+			}
+		}
+	}
+
+	hash, err := writeTreeObject(merged)
+	if err != nil {
+		return "", nil, err
+	}
+	return hash, conflicts, nil
+}
+
+func runMergeTree(args []string) {
+	if len(args) != 3 {
+		handleError(errors.New("usage: got merge-tree <base-tree> <tree-a> <tree-b>"))
+	}
+
+	var trees [3]string
+	for i, arg := range args {
+		hash, err := resolveRevision(arg)
+		if err != nil {
+			handleError(err)
+		}
+		if trees[i], err = peelToTree(hash); err != nil {
+			handleError(err)
+		}
+	}
+
+	hash, conflicts, err := mergeTrees(trees[0], trees[1], trees[2], "")
+	if err != nil {
+		handleError(err)
+	}
+	fmt.Println(hash)
+	for _, path := range conflicts {
+		fmt.Fprintf(os.Stderr, "CONFLICT (content): %s\n", path)
+	}
+	if len(conflicts) > 0 {
+		exit(1)
+	}
+}
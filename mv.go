@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// move is one rename mv will make: src to dst, with the index entries
+// under src.
+type move struct {
+	src, dst string
+	entries  []*IndexEntry
+}
+
+func runMv(args []string) {
+	var force, dryRun, verbose, skipErrors bool
+	var paths []string
+	for _, arg := range args {
+		switch arg {
+		case "-f", "--force":
+			force = true
+		case "-n", "--dry-run":
+			dryRun = true
+		case "-v", "--verbose":
+			verbose = true
+		case "-k":
+			skipErrors = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				handleError(errors.New("usage: got mv [-f] [-n] [-v] [-k] <source>... <destination>"))
+			}
+			paths = append(paths, prefixPath(arg))
+		}
+	}
+	if len(paths) < 2 {
+		handleError(errors.New("usage: got mv [-f] [-n] [-v] [-k] <source>... <destination>"))
+	}
+	sources, dest := paths[:len(paths)-1], paths[len(paths)-1]
+
+	// Several sources, or a destination that is a directory, move into it.
+	info, err := os.Stat(filepath.FromSlash(dest))
+	intoDir := err == nil && info.IsDir()
+	if len(sources) > 1 && !intoDir {
+		handleError(fmt.Errorf("destination '%s' is not a directory", dest))
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+
+	// Every rename is checked before anything moves.
+	var moves []move
+	for _, src := range sources {
+		dst := dest
+		if intoDir {
+			dst = path.Join(dest, path.Base(src))
+		}
+		m, err := planMove(idx, src, dst, force)
+		if dryRun {
+			fmt.Printf("Checking rename of '%s' to '%s'\n", src, dst)
+		}
+		if err != nil {
+			if skipErrors {
+				continue
+			}
+			handleError(fmt.Errorf("%s, source=%s, destination=%s", err, src, dst))
+		}
+		moves = append(moves, m)
+	}
+
+	for _, m := range moves {
+		if dryRun || verbose {
+			fmt.Printf("Renaming %s to %s\n", m.src, m.dst)
+		}
+		if dryRun {
+			continue
+		}
+		if _, err := os.Lstat(filepath.FromSlash(m.dst)); err == nil {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "warning: overwriting '%s'\n", m.dst)
+			}
+			if err := os.RemoveAll(filepath.FromSlash(m.dst)); err != nil {
+				handleError(err)
+			}
+		}
+		if err := os.Rename(filepath.FromSlash(m.src), filepath.FromSlash(m.dst)); err != nil {
+			handleError(err)
+		}
+		for _, entry := range m.entries {
+			idx.remove(entry.Path)
+			entry.Path = m.dst + strings.TrimPrefix(entry.Path, m.src)
+			if info, err := os.Lstat(filepath.FromSlash(entry.Path)); err == nil {
+				setStat(entry, info)
+			}
+			idx.add(entry)
+		}
+	}
+	if dryRun {
+		return
+	}
+	if err := idx.write(); err != nil {
+		handleError(err)
+	}
+}
+
+// planMove checks that src can be renamed to dst and collects the index
+// entries that move with it. The error wording follows git's.
+func planMove(idx *Index, src, dst string, force bool) (move, error) {
+	m := move{src: src, dst: dst}
+	srcInfo, err := os.Lstat(filepath.FromSlash(src))
+	if err != nil {
+		return m, errors.New("bad source")
+	}
+	if src == dst || strings.HasPrefix(dst, src+"/") {
+		return m, errors.New("can not move directory into itself")
+	}
+
+	for _, entry := range idx.Entries {
+		if entry.Path == src || (srcInfo.IsDir() && strings.HasPrefix(entry.Path, src+"/")) {
+			if entry.Stage() != 0 {
+				return m, errors.New("conflicted")
+			}
+			m.entries = append(m.entries, entry)
+		}
+	}
+	if len(m.entries) == 0 {
+		return m, errors.New("not under version control")
+	}
+
+	if dstInfo, err := os.Lstat(filepath.FromSlash(dst)); err == nil {
+		// A file may replace a file with -f; nothing replaces a directory.
+		if !force || srcInfo.IsDir() || dstInfo.IsDir() {
+			return m, errors.New("destination exists")
+		}
+	}
+	if dir := path.Dir(dst); dir != "." {
+		if info, err := os.Stat(filepath.FromSlash(dir)); err != nil || !info.IsDir() {
+			return m, errors.New("destination directory does not exist")
+		}
+	}
+	return m, nil
+}
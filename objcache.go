@@ -0,0 +1,99 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const defaultObjectCacheSize = 256 << 20
+
+// objectCache is a least-recently-used cache of inflated objects, bounded
+// by the total size of their content. Its size comes from got.objectCacheSize
+// or, overriding that, GOT_OBJECT_CACHE_SIZE.
+type objectCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	used     int64
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+
+	hits      int
+	misses    int
+	evictions int
+}
+
+type cachedObject struct {
+	hash       string
+	objectType string
+	content    []byte
+}
+
+func newObjectCache(maxBytes int64) *objectCache {
+	return &objectCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached object. The content is shared and must not be
+// modified.
+func (c *objectCache) get(hash string) (string, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[hash]
+	if !ok {
+		c.misses++
+		return "", nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	obj := elem.Value.(*cachedObject)
+	return obj.objectType, obj.content, true
+}
+
+func (c *objectCache) add(hash, objectType string, content []byte) {
+	size := int64(len(content))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[hash]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[hash] = c.order.PushFront(&cachedObject{hash: hash, objectType: objectType, content: content})
+	c.used += size
+
+	for c.used > c.maxBytes {
+		oldest := c.order.Back()
+		obj := oldest.Value.(*cachedObject)
+		c.order.Remove(oldest)
+		delete(c.items, obj.hash)
+		c.used -= int64(len(obj.content))
+		c.evictions++
+	}
+}
+
+// debugStats is set by the global --debug-stats option.
+var debugStats bool
+
+// writeDebugStats reports how the current repository's object cache did,
+// for the global --debug-stats option.
+func writeDebugStats() {
+	repo, err := currentRepo()
+	if err != nil {
+		return
+	}
+	c := repo.objects
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "object cache: %d hits, %d misses, %d evictions\n", c.hits, c.misses, c.evictions)
+	fmt.Fprintf(os.Stderr, "object cache: %d objects, %d of %d bytes\n", len(c.items), c.used, c.maxBytes)
+}
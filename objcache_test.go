@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDebugStats(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "first")
+	commitFile(t, dir, "a", "b\n", "second")
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+	}{
+		{"success", []string{"log", "--oneline"}, 0},
+		{"error", []string{"cat-file", "-p", "HEAD:missing"}, 1},
+		{"exit status", []string{"merge-base", "--is-ancestor", "HEAD", "HEAD~1"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"--debug-stats"}, tt.args...)
+			_, stderr, err := runCommandStderr(dir, gotBinary, args...)
+			status := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status = exitErr.ExitCode()
+			}
+			if status != tt.status {
+				t.Fatalf("got %s exited with %d (%v), want %d", strings.Join(args, " "), status, err, tt.status)
+			}
+			if !strings.Contains(stderr, "object cache: ") || !strings.Contains(stderr, " hits, ") {
+				t.Errorf("got %s printed no cache stats:\n%s", strings.Join(args, " "), stderr)
+			}
+		})
+	}
+}
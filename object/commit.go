@@ -0,0 +1,43 @@
+package object
+
+import (
+	"errors"
+	"strings"
+)
+
+// Commit holds the parsed fields of a commit object.
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    string
+	Committer string
+	Message   string
+}
+
+// ParseCommit decodes a commit object. Headers other than tree, parent,
+// author and committer are skipped.
+func ParseCommit(content []byte) (*Commit, error) {
+	header, message, found := strings.Cut(string(content), "\n\n")
+	if !found {
+		return nil, errors.New("malformed commit: missing message")
+	}
+
+	commit := &Commit{Message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author = value
+		case "committer":
+			commit.Committer = value
+		}
+	}
+	if commit.Tree == "" {
+		return nil, errors.New("malformed commit: missing tree")
+	}
+	return commit, nil
+}
@@ -0,0 +1,119 @@
+// Package object implements git's object formats: hashing, the loose
+// object encoding, and the tree and commit layouts. It does no I/O on a
+// repository, so other programs can use it to read and build objects.
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EmptyTreeHash is the hash of a tree with no entries.
+const EmptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// ErrMalformedHeader is returned for a loose object whose "<type> <size>"
+// header cannot be parsed.
+var ErrMalformedHeader = errors.New("malformed object header")
+
+// Encode returns an object as it is hashed and stored: the "<type> <size>\0"
+// header followed by the content.
+func Encode(objectType string, content []byte) []byte {
+	header := fmt.Sprintf("%s %d\x00", objectType, len(content))
+	return append([]byte(header), content...)
+}
+
+// Hash returns the hex SHA-1 name of an object with the given type and
+// content.
+func Hash(objectType string, content []byte) string {
+	h := sha1.Sum(Encode(objectType, content))
+	return hex.EncodeToString(h[:])
+}
+
+// HashReader returns the hash of an object of the given type whose size
+// bytes of content are read from r, without holding the content in memory.
+func HashReader(objectType string, size int64, r io.Reader) (string, error) {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", objectType, size)
+	n, err := io.CopyN(h, r, size)
+	if err == io.EOF {
+		return "", fmt.Errorf("short read: expected %d bytes, got %d", size, n)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Inflate decompresses a loose object and splits it into its type and
+// content, checking the size recorded in the header.
+func Inflate(compressed []byte) (string, []byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+
+	nullIndex := bytes.IndexByte(data, 0)
+	if nullIndex == -1 {
+		return "", nil, ErrMalformedHeader
+	}
+	objectType, size, found := strings.Cut(string(data[:nullIndex]), " ")
+	if !found {
+		return "", nil, ErrMalformedHeader
+	}
+	content := data[nullIndex+1:]
+	if size != strconv.Itoa(len(content)) {
+		return "", nil, fmt.Errorf("object size mismatch: header says %s, got %d", size, len(content))
+	}
+	return objectType, content, nil
+}
+
+// ReadLooseHeader returns the type and size of the compressed loose object
+// read from f, inflating only as much as the header needs.
+func ReadLooseHeader(f io.Reader) (string, int64, error) {
+	objectType, size, _, err := OpenLoose(f)
+	return objectType, size, err
+}
+
+// OpenLoose reads the header of the compressed loose object read from f
+// and returns its type and size, and a reader that inflates the content as
+// it is read. The reader stops after size bytes.
+func OpenLoose(f io.Reader) (string, int64, io.Reader, error) {
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+	r := bufio.NewReader(zr)
+
+	// The header is "<type> <size>\0"; 64 bytes is plenty for any type
+	// name and a decimal size.
+	header, err := r.ReadSlice(0)
+	if err == io.EOF || err == bufio.ErrBufferFull || len(header) > 64 {
+		return "", 0, nil, ErrMalformedHeader
+	}
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+	objectType, size, found := strings.Cut(string(header[:len(header)-1]), " ")
+	if !found {
+		return "", 0, nil, ErrMalformedHeader
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return "", 0, nil, ErrMalformedHeader
+	}
+	return objectType, n, io.LimitReader(r, n), nil
+}
@@ -0,0 +1,186 @@
+package object
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The entry types of a pack.
+const (
+	PackCommit   = 1
+	PackTree     = 2
+	PackBlob     = 3
+	PackTag      = 4
+	PackOfsDelta = 6
+	PackRefDelta = 7
+)
+
+// PackTypeNames names the pack entry types that hold whole objects.
+var PackTypeNames = map[int]string{
+	PackCommit: "commit",
+	PackTree:   "tree",
+	PackBlob:   "blob",
+	PackTag:    "tag",
+}
+
+// PackEntryHeader is the decoded header of a pack entry. Size is that of
+// the inflated entry data, which for a delta is the delta itself. For
+// deltas, BaseOffset or BaseHash names the base object.
+type PackEntryHeader struct {
+	Type       int
+	Size       uint64
+	BaseOffset uint64
+	BaseHash   string
+}
+
+// ReadPackEntryHeader decodes the header of the entry at offset from r,
+// leaving r at the start of the entry's zlib stream.
+func ReadPackEntryHeader(r io.ByteReader, offset uint64) (PackEntryHeader, error) {
+	var h PackEntryHeader
+	c, err := r.ReadByte()
+	if err != nil {
+		return h, err
+	}
+	h.Type = int(c>>4) & 7
+	h.Size = uint64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return h, err
+		}
+		h.Size |= uint64(c&0x7f) << shift
+	}
+
+	switch h.Type {
+	case PackOfsDelta:
+		c, err := r.ReadByte()
+		if err != nil {
+			return h, err
+		}
+		distance := uint64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return h, err
+			}
+			distance = (distance+1)<<7 | uint64(c&0x7f)
+		}
+		if distance == 0 || distance > offset {
+			return h, fmt.Errorf("bad delta base offset at %d", offset)
+		}
+		h.BaseOffset = offset - distance
+	case PackRefDelta:
+		raw := make([]byte, 20)
+		for i := range raw {
+			if raw[i], err = r.ReadByte(); err != nil {
+				return h, err
+			}
+		}
+		h.BaseHash = hex.EncodeToString(raw)
+	default:
+		if _, ok := PackTypeNames[h.Type]; !ok {
+			return h, fmt.Errorf("unknown object type %d at offset %d", h.Type, offset)
+		}
+	}
+	return h, nil
+}
+
+// DeltaSizes reads the base and result sizes at the start of a delta.
+func DeltaSizes(r io.ByteReader) (base, result uint64, err error) {
+	if base, err = readDeltaSize(r); err != nil {
+		return 0, 0, err
+	}
+	result, err = readDeltaSize(r)
+	return base, result, err
+}
+
+func readDeltaSize(r io.ByteReader) (uint64, error) {
+	var size uint64
+	for shift := 0; ; shift += 7 {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, errors.New("truncated delta header")
+		}
+		if err != nil {
+			return 0, err
+		}
+		size |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return size, nil
+		}
+	}
+}
+
+// ApplyDelta reconstructs an object from its base and a git delta.
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	r := &sliceReader{delta}
+	srcSize, dstSize, err := DeltaSizes(r)
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != uint64(len(base)) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	delta = r.b
+
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			var offset, size uint64
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta copy")
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta copy")
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errors.New("delta copy out of range")
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta insert")
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("invalid delta opcode 0")
+		}
+	}
+	if uint64(len(out)) != dstSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return out, nil
+}
+
+// sliceReader reads bytes off the front of a slice.
+type sliceReader struct {
+	b []byte
+}
+
+func (r *sliceReader) ReadByte() (byte, error) {
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c, nil
+}
@@ -0,0 +1,134 @@
+package object
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TreeEntry is one entry of a tree object. Mode is as stored, so trees
+// are "40000" rather than "040000".
+type TreeEntry struct {
+	Mode string
+	Name string
+	Hash string
+}
+
+// IsTreeMode reports whether mode is that of a subtree.
+func IsTreeMode(mode string) bool {
+	return mode == "40000" || mode == "040000"
+}
+
+// ParseTree decodes the entries of a tree object.
+func ParseTree(content []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	data := content
+	for len(data) > 0 {
+		// Parse mode
+		spaceIdx := bytes.IndexByte(data, ' ')
+		if spaceIdx == -1 {
+			return nil, errors.New("malformed entry: missing mode")
+		}
+		mode := string(data[:spaceIdx])
+		data = data[spaceIdx+1:]
+
+		nullIdx := bytes.IndexByte(data, 0)
+		if nullIdx == -1 {
+			return nil, errors.New("malformed entry: missing name terminator")
+		}
+		name := string(data[:nullIdx])
+		data = data[nullIdx+1:]
+
+		if len(data) < 20 {
+			return nil, errors.New("malformed entry: incomplete hash")
+		}
+		hashBytes := data[:20]
+		data = data[20:]
+
+		entries = append(entries, TreeEntry{
+			Mode: mode,
+			Name: name,
+			Hash: hex.EncodeToString(hashBytes),
+		})
+	}
+	return entries, nil
+}
+
+// EncodeTree sorts entries into tree order and returns the content of the
+// tree object holding them.
+func EncodeTree(entries []TreeEntry) ([]byte, error) {
+	SortTree(entries)
+
+	var content bytes.Buffer
+	for _, entry := range entries {
+		//<mode> <name>\0<20-byte-hash>
+		content.WriteString(entry.Mode)
+		content.WriteByte(' ')
+		content.WriteString(entry.Name)
+		content.WriteByte(0)
+
+		hashBytes, err := hex.DecodeString(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		content.Write(hashBytes)
+	}
+	return content.Bytes(), nil
+}
+
+// SortTree sorts entries in git's tree order: by name, except that a tree
+// compares as if its name ended in "/". So "foo.c" comes before the
+// directory "foo", which comes before "foo0".
+func SortTree(entries []TreeEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return SortKey(entries[i]) < SortKey(entries[j])
+	})
+}
+
+// SortKey returns the string an entry sorts by in a tree.
+func SortKey(entry TreeEntry) string {
+	if IsTreeMode(entry.Mode) {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
+
+// VerifyTree checks that a tree object is in the canonical form git
+// writes, so that the same entries always hash the same: known modes
+// without zero padding, names that are valid path components, and entries
+// in tree order with no duplicates.
+func VerifyTree(content []byte) error {
+	entries, err := ParseTree(content)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		switch entry.Mode {
+		case "40000", "100644", "100755", "120000", "160000":
+		case "040000":
+			return fmt.Errorf("zero-padded mode %s for '%s'", entry.Mode, entry.Name)
+		default:
+			return fmt.Errorf("bad mode %s for '%s'", entry.Mode, entry.Name)
+		}
+		switch {
+		case entry.Name == "":
+			return errors.New("empty entry name")
+		case entry.Name == "." || entry.Name == ".." || entry.Name == ".git" || strings.Contains(entry.Name, "/"):
+			return fmt.Errorf("bad entry name '%s'", entry.Name)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := entries[i-1]
+		switch {
+		case prev.Name == entry.Name:
+			return fmt.Errorf("duplicate entry '%s'", entry.Name)
+		case SortKey(prev) > SortKey(entry):
+			return fmt.Errorf("not properly sorted: '%s' before '%s'", prev.Name, entry.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package object
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	blobMode = "100644"
+	treeMode = "40000"
+	someHash = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+)
+
+// entries makes tree entries from "name" for blobs and "name/" for trees.
+func entries(names ...string) []TreeEntry {
+	var out []TreeEntry
+	for _, name := range names {
+		mode := blobMode
+		if dir, ok := strings.CutSuffix(name, "/"); ok {
+			name, mode = dir, treeMode
+		}
+		out = append(out, TreeEntry{Mode: mode, Name: name, Hash: someHash})
+	}
+	return out
+}
+
+// names is the inverse of entries.
+func names(entries []TreeEntry) []string {
+	var out []string
+	for _, entry := range entries {
+		if IsTreeMode(entry.Mode) {
+			out = append(out, entry.Name+"/")
+		} else {
+			out = append(out, entry.Name)
+		}
+	}
+	return out
+}
+
+func TestSortTree(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"files by name", []string{"foo.c", "foo"}, []string{"foo", "foo.c"}},
+		{"directory after dotted file", []string{"foo/", "foo.c"}, []string{"foo.c", "foo/"}},
+		{"file, dotted file, directory", []string{"foo/", "foo.c", "bar"}, []string{"bar", "foo.c", "foo/"}},
+		{"directory before digit", []string{"foo0", "foo/"}, []string{"foo/", "foo0"}},
+		{"directory after dash", []string{"foo/", "foo-bar"}, []string{"foo-bar", "foo/"}},
+		{"directory sorts as with slash", []string{"foo.c/", "foo/", "foo"}, []string{"foo", "foo.c/", "foo/"}},
+		{"bytes, not runes", []string{"é", "z", "Z"}, []string{"Z", "z", "é"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := entries(tt.in...)
+			SortTree(in)
+			if got := names(in); !slices.Equal(got, tt.want) {
+				t.Errorf("SortTree(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTreeOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []string
+		wantErr string
+	}{
+		{"sorted", []string{"foo", "foo.c", "foo0/"}, ""},
+		{"directory before dotted file", []string{"foo/", "foo.c"}, "not properly sorted"},
+		{"duplicate", []string{"foo", "foo"}, "duplicate entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// EncodeTree sorts, so the content is written out by hand.
+			var content []byte
+			for _, entry := range entries(tt.in...) {
+				one, err := EncodeTree([]TreeEntry{entry})
+				if err != nil {
+					t.Fatal(err)
+				}
+				content = append(content, one...)
+			}
+			err := VerifyTree(content)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("VerifyTree(%q) = %v, want no error", tt.in, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("VerifyTree(%q) = %v, want %q", tt.in, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// jsonOutput is set by the global --json option. Commands that support it
+// print their results with writeJSON instead of their usual format.
+var jsonOutput bool
+
+func writeJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		handleError(err)
+	}
+}
+
+// quotePath returns p as git shows it: in double quotes with C-style
+// escapes when it holds a control character, a double quote or a
+// backslash, when quoteHigh (core.quotePath) is set and it holds a byte
+// outside ASCII, which is written in octal, or when quoteSpace is set and
+// it holds a space. Other paths are returned as they are.
+func quotePath(p string, quoteSpace, quoteHigh bool) string {
+	needsQuote := func(c byte) bool {
+		return c < 0x20 || c == '"' || c == '\\' || c == 0x7f ||
+			(quoteHigh && c >= 0x80) || (quoteSpace && c == ' ')
+	}
+	i := 0
+	for i < len(p) && !needsQuote(p[i]) {
+		i++
+	}
+	if i == len(p) {
+		return p
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\a':
+			b.WriteString(`\a`)
+		case c == '\b':
+			b.WriteString(`\b`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\v':
+			b.WriteString(`\v`)
+		case c == '\f':
+			b.WriteString(`\f`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < 0x20 || c == 0x7f || (quoteHigh && c >= 0x80):
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+	"github.com/piyushyadav1617/got/repo"
+)
+
+func init() {
+	registerCapability("pack-read", "v2 index, ofs-delta, ref-delta")
+}
+
+// objectStore reads the bases of ref-deltas through the object cache, and
+// from the promisor remote in a partial clone.
+type objectStore struct{}
+
+func (objectStore) ReadObject(hash string) (string, []byte, error) {
+	return readObject(hash)
+}
+
+func (objectStore) ObjectHeader(hash string) (string, int64, error) {
+	return objectHeader(hash)
+}
+
+// openPack opens the pack at packPath and parses its .idx file.
+func openPack(packPath string) (*repo.Pack, error) {
+	return repo.OpenPack(packPath, objectStore{})
+}
+
+// verifyPack checks the CRC and hash of every object in a pack and calls
+// fn with each object's index position, type and content.
+func verifyPack(p *repo.Pack, fn func(i int, objectType string, content []byte)) []error {
+	var errs []error
+	for i := 0; i < p.Count(); i++ {
+		if err := p.CheckCRC(i); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		objectType, content, err := p.Read(i)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("object %s: %w", p.HashAt(i), err))
+			continue
+		}
+		if actual := object.Hash(objectType, content); actual != p.HashAt(i) {
+			errs = append(errs, fmt.Errorf("%s: object %s hashes to %s", p.Path(), p.HashAt(i), actual))
+			continue
+		}
+		if fn != nil {
+			fn(i, objectType, content)
+		}
+	}
+	return errs
+}
+
+func runVerifyPack(args []string) {
+	verbose := false
+	var paths []string
+	for _, arg := range args {
+		if arg == "-v" || arg == "--verbose" {
+			verbose = true
+		} else {
+			paths = append(paths, prefixFilename(arg))
+		}
+	}
+	if len(paths) == 0 {
+		handleError(errors.New("usage: got verify-pack [-v] <pack>.idx..."))
+	}
+
+	failed := false
+	for _, path := range paths {
+		p, err := openPack(strings.TrimSuffix(strings.TrimSuffix(path, ".idx"), ".pack") + ".pack")
+		if err != nil {
+			handleError(err)
+		}
+		errs := verifyPack(p, func(i int, objectType string, content []byte) {
+			if verbose {
+				offset := p.Offset(i)
+				fmt.Printf("%s %-6s %d %d %d\n", p.HashAt(i), objectType, len(content), p.EntryEnd(offset)-offset, offset)
+			}
+		})
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		}
+		if len(errs) > 0 {
+			failed = true
+			fmt.Printf("%s: bad\n", p.Path())
+		} else if verbose {
+			fmt.Printf("%s: ok\n", p.Path())
+		}
+		p.Close()
+	}
+	if failed {
+		exit(1)
+	}
+}
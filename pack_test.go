@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestPack makes a repository whose objects are all in one pack and
+// returns its work tree, the pack's path and the hash of the blob "a".
+func newTestPack(t *testing.T) (dir, packPath, blob string) {
+	t.Helper()
+	dir = newTestRepo(t)
+	commitFile(t, dir, "a", strings.Repeat("the blob to corrupt\n", 50), "add a")
+	commitFile(t, dir, "b", "b\n", "add b")
+	runGit(t, dir, "repack", "-q", "-a", "-d")
+	packs, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "pack-*.pack"))
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("want one pack, have %v (%v)", packs, err)
+	}
+	return dir, packs[0], strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD:a"))
+}
+
+// flipPackByte inverts one byte of the entry for hash in the pack at
+// packPath, at where(start, end) for the entry's extent.
+func flipPackByte(t *testing.T, packPath, hash string, where func(start, end uint64) uint64) {
+	t.Helper()
+	p, err := openPack(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, ok := p.Find(hash)
+	if !ok {
+		t.Fatalf("%s is not in the pack", hash)
+	}
+	offset := where(p.Offset(i), p.EntryEnd(p.Offset(i)))
+	p.Close()
+
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[offset] ^= 0xff
+	// Packs are written read-only.
+	if err := os.Chmod(packPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(packPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var packCorruptions = []struct {
+	name  string
+	where func(start, end uint64) uint64
+}{
+	{"entry header", func(start, end uint64) uint64 { return start }},
+	{"compressed data", func(start, end uint64) uint64 { return start + (end-start)/2 }},
+	{"last byte", func(start, end uint64) uint64 { return end - 1 }},
+}
+
+func TestVerifyPackCRC(t *testing.T) {
+	_, packPath, _ := newTestPack(t)
+	p, err := openPack(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if errs := verifyPack(p, nil); len(errs) > 0 {
+		t.Fatalf("intact pack: %v", errs)
+	}
+
+	for _, tt := range packCorruptions {
+		t.Run(tt.name, func(t *testing.T) {
+			_, packPath, blob := newTestPack(t)
+			flipPackByte(t, packPath, blob, tt.where)
+			p, err := openPack(packPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			errs := verifyPack(p, nil)
+			if len(errs) == 0 {
+				t.Fatal("corrupt pack verified")
+			}
+			want := "CRC mismatch for object " + blob
+			if !strings.Contains(errs[0].Error(), want) {
+				t.Errorf("verifyPack: %v, want %q", errs, want)
+			}
+		})
+	}
+}
+
+func TestReadCorruptPack(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantCRC bool
+	}{
+		{name: "default", wantCRC: false},
+		{name: "verifyPackCrc", config: "true", wantCRC: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, packPath, blob := newTestPack(t)
+			flipPackByte(t, packPath, blob, packCorruptions[2].where)
+			if tt.config != "" {
+				runGit(t, dir, "config", "got.verifyPackCrc", tt.config)
+			}
+			_, err := runCommand(dir, gotBinary, "cat-file", "-p", blob)
+			if err == nil {
+				t.Fatal("corrupt object read")
+			}
+			if got := strings.Contains(err.Error(), "CRC mismatch"); got != tt.wantCRC {
+				t.Errorf("CRC mismatch reported = %v, want %v:\n%v", got, tt.wantCRC, err)
+			}
+			// fsck checks CRCs whatever the config says.
+			out, err := runCommand(dir, gotBinary, "fsck")
+			if err == nil || !strings.Contains(out+err.Error(), "CRC mismatch") {
+				t.Errorf("fsck did not report the CRC mismatch: %s%v", out, err)
+			}
+		})
+	}
+}
+
+func TestGCWritesReadOnlyPack(t *testing.T) {
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a", "a\n", "add a")
+	runGot(t, dir, "gc")
+	files, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "pack-*"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("want a pack and its index, have %v (%v)", files, err)
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0444 {
+			t.Errorf("%s has mode %o, want 444", filepath.Base(file), mode)
+		}
+	}
+	runGit(t, dir, "fsck", "--strict")
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+func init() {
+	registerCapability("pack-write", "v2 index, ofs-delta")
+}
+
+var packTypeCodes = map[string]int{
+	"commit": object.PackCommit,
+	"tree":   object.PackTree,
+	"blob":   object.PackBlob,
+	"tag":    object.PackTag,
+}
+
+// packEntry is the position of one object written to a pack.
+type packEntry struct {
+	hash   []byte
+	offset uint64
+	crc    uint32
+}
+
+// countingWriter tracks how many bytes have gone through it.
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// writePackEntryHeader writes the type and size of a pack entry: the type
+// in bits 4-6 of the first byte and the size in little-endian groups of 7
+// bits, 4 in the first byte.
+func writePackEntryHeader(w io.Writer, objType int, size uint64) error {
+	var header []byte
+	b := byte(objType<<4) | byte(size&0x0f)
+	size >>= 4
+	for size != 0 {
+		header = append(header, b|0x80)
+		b = byte(size & 0x7f)
+		size >>= 7
+	}
+	header = append(header, b)
+	_, err := w.Write(header)
+	return err
+}
+
+// packOptions control delta compression when writing a pack: each object
+// is compared with up to window objects of its type before it, and delta
+// chains are at most depth long. A window of 0 stores every object whole.
+type packOptions struct {
+	window, depth int
+}
+
+// configPackOptions reads pack.window and pack.depth, which default to 10
+// and 50 as in git.
+func configPackOptions() (packOptions, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return packOptions{}, err
+	}
+	window, err := repo.Config.Size("pack", "window", 10)
+	if err != nil {
+		return packOptions{}, err
+	}
+	depth, err := repo.Config.Size("pack", "depth", 50)
+	if err != nil {
+		return packOptions{}, err
+	}
+	return packOptions{window: int(window), depth: int(depth)}, nil
+}
+
+// writePack stores the objects with the given hashes in a new pack under
+// .git/objects/pack, with a version 2 index, and returns the pack's path.
+func writePack(hashes []string) (string, error) {
+	opts, err := configPackOptions()
+	if err != nil {
+		return "", err
+	}
+	return writePackFiles(commonPath("objects", "pack", "pack"), hashes, opts)
+}
+
+// writePackFiles writes the objects to <prefix>-<checksum>.pack with a
+// matching .idx and returns the pack's path.
+func writePackFiles(prefix string, hashes []string, opts packOptions) (string, error) {
+	dir := filepath.Dir(prefix)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, "tmp_pack_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	entries, checksum, err := writePackData(tmp, hashes, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	base := prefix + "-" + hex.EncodeToString(checksum)
+	if err := os.Rename(tmp.Name(), base+".pack"); err != nil {
+		return "", err
+	}
+	if err := writePackIndex(base+".idx", entries, checksum); err != nil {
+		return "", err
+	}
+	return base + ".pack", nil
+}
+
+// packObject is an object on its way into a pack. base is set when it is
+// stored as delta against an earlier entry.
+type packObject struct {
+	hash    string
+	objType int
+	content []byte
+
+	base   *packObject
+	delta  []byte
+	depth  int
+	offset uint64
+}
+
+// writePackData writes a pack holding the given objects to w and returns
+// where each object went and the pack's checksum. Objects are written
+// grouped by type, largest first, which is also the order deltas are
+// looked for in; a delta always refers back to its base by offset.
+func writePackData(w io.Writer, hashes []string, opts packOptions) ([]packEntry, []byte, error) {
+	objects := make([]*packObject, 0, len(hashes))
+	for _, hash := range hashes {
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		objType, ok := packTypeCodes[objectType]
+		if !ok {
+			return nil, nil, errors.New("cannot pack object " + hash + " of type " + objectType)
+		}
+		objects = append(objects, &packObject{hash: hash, objType: objType, content: content})
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].objType != objects[j].objType {
+			return objects[i].objType < objects[j].objType
+		}
+		return len(objects[i].content) > len(objects[j].content)
+	})
+	if opts.window > 0 {
+		findDeltas(objects, opts)
+	}
+
+	sum := sha1.New()
+	out := &countingWriter{w: io.MultiWriter(w, sum)}
+
+	header := make([]byte, 12)
+	copy(header, "PACK")
+	binary.BigEndian.PutUint32(header[4:], 2)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(objects)))
+	if _, err := out.Write(header); err != nil {
+		return nil, nil, err
+	}
+
+	entries := make([]packEntry, 0, len(objects))
+	for _, o := range objects {
+		raw, err := hex.DecodeString(o.hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		o.offset = out.n
+		entry := packEntry{hash: raw, offset: o.offset}
+		if o.base != nil {
+			entry.crc, err = writePackDelta(out, o.offset-o.base.offset, o.delta)
+		} else {
+			entry.crc, err = writePackObject(out, o.objType, o.content)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	checksum := sum.Sum(nil)
+	if _, err := w.Write(checksum); err != nil {
+		return nil, nil, err
+	}
+	return entries, checksum, nil
+}
+
+// findDeltas picks a delta base for each object from the window of objects
+// of the same type just before it, keeping the smallest delta. As in git,
+// a delta must save at least half the object to be worth it.
+func findDeltas(objects []*packObject, opts packOptions) {
+	var window []*packObject
+	indexes := make(map[*packObject]*deltaIndex)
+	for _, o := range objects {
+		if len(window) > 0 && window[0].objType != o.objType {
+			window = window[:0]
+			clear(indexes)
+		}
+		maxSize := len(o.content)/2 - 20
+		for i := len(window) - 1; i >= 0 && maxSize > 0; i-- {
+			base := window[i]
+			if base.depth >= opts.depth {
+				continue
+			}
+			idx, ok := indexes[base]
+			if !ok {
+				idx = newDeltaIndex(base.content)
+				indexes[base] = idx
+			}
+			if delta := idx.computeDelta(o.content, maxSize); delta != nil {
+				o.base, o.delta, o.depth = base, delta, base.depth+1
+				maxSize = len(delta) - 1
+			}
+		}
+
+		window = append(window, o)
+		if len(window) > opts.window {
+			delete(indexes, window[0])
+			window = window[1:]
+		}
+	}
+}
+
+// writePackObject writes one whole object as a pack entry and returns the
+// entry's CRC-32, which the index records.
+func writePackObject(w io.Writer, objType int, content []byte) (uint32, error) {
+	crc := crc32.NewIEEE()
+	w = io.MultiWriter(w, crc)
+	if err := writePackEntryHeader(w, objType, uint64(len(content))); err != nil {
+		return 0, err
+	}
+	if err := writeDeflated(w, content); err != nil {
+		return 0, err
+	}
+	return crc.Sum32(), nil
+}
+
+// writePackDelta writes an offset delta entry whose base starts distance
+// bytes before it, and returns the entry's CRC-32.
+func writePackDelta(w io.Writer, distance uint64, delta []byte) (uint32, error) {
+	crc := crc32.NewIEEE()
+	w = io.MultiWriter(w, crc)
+	if err := writePackEntryHeader(w, object.PackOfsDelta, uint64(len(delta))); err != nil {
+		return 0, err
+	}
+	// The distance is big-endian in groups of 7 bits, each group but the
+	// last one less, so that no two encodings mean the same distance.
+	buf := []byte{byte(distance & 0x7f)}
+	for distance >>= 7; distance != 0; distance >>= 7 {
+		distance--
+		buf = append([]byte{byte(distance&0x7f) | 0x80}, buf...)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return 0, err
+	}
+	if err := writeDeflated(w, delta); err != nil {
+		return 0, err
+	}
+	return crc.Sum32(), nil
+}
+
+// writeDeflated writes data zlib-compressed, as pack entries store it.
+func writeDeflated(w io.Writer, data []byte) error {
+	zw := zlib.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writePackIndex writes a version 2 .idx for the given entries.
+func writePackIndex(path string, entries []packEntry, packChecksum []byte) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].hash, entries[j].hash) < 0
+	})
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 't', 'O', 'c'})
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+
+	var fanout [256]uint32
+	for _, entry := range entries {
+		fanout[entry.hash[0]]++
+	}
+	for i := 1; i < 256; i++ {
+		fanout[i] += fanout[i-1]
+	}
+	binary.Write(&buf, binary.BigEndian, fanout)
+
+	for _, entry := range entries {
+		buf.Write(entry.hash)
+	}
+	for _, entry := range entries {
+		binary.Write(&buf, binary.BigEndian, entry.crc)
+	}
+	// Offsets that do not fit in 31 bits go in a table of 64-bit offsets,
+	// referenced by index with the high bit set.
+	var large []uint64
+	for _, entry := range entries {
+		if entry.offset < 0x80000000 {
+			binary.Write(&buf, binary.BigEndian, uint32(entry.offset))
+		} else {
+			binary.Write(&buf, binary.BigEndian, uint32(len(large))|0x80000000)
+			large = append(large, entry.offset)
+		}
+	}
+	for _, offset := range large {
+		binary.Write(&buf, binary.BigEndian, offset)
+	}
+
+	buf.Write(packChecksum)
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0444); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runPackObjects packs the objects named on stdin, one per line. Anything
+// after the hash on a line, such as a path, is ignored.
+func runPackObjects(args []string) {
+	usage := errors.New("usage: got pack-objects [--window=<n>] [--depth=<n>] (--stdout | <base-name>) < <object-list>")
+	opts, err := configPackOptions()
+	if err != nil {
+		handleError(err)
+	}
+	stdout := false
+	var prefix string
+	for _, arg := range args {
+		switch {
+		case arg == "--stdout":
+			stdout = true
+		case strings.HasPrefix(arg, "--window="):
+			if opts.window, err = strconv.Atoi(strings.TrimPrefix(arg, "--window=")); err != nil || opts.window < 0 {
+				handleError(fmt.Errorf("invalid window '%s'", strings.TrimPrefix(arg, "--window=")))
+			}
+		case strings.HasPrefix(arg, "--depth="):
+			if opts.depth, err = strconv.Atoi(strings.TrimPrefix(arg, "--depth=")); err != nil || opts.depth < 0 {
+				handleError(fmt.Errorf("invalid depth '%s'", strings.TrimPrefix(arg, "--depth=")))
+			}
+		case prefix == "" && !strings.HasPrefix(arg, "-"):
+			prefix = prefixFilename(arg)
+		default:
+			handleError(usage)
+		}
+	}
+	if stdout == (prefix != "") {
+		handleError(usage)
+	}
+
+	var hashes []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !isFullHash(fields[0]) {
+			handleError(fmt.Errorf("expected object ID, got garbage:\n %s", scanner.Text()))
+		}
+		if !seen[fields[0]] {
+			seen[fields[0]] = true
+			hashes = append(hashes, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		handleError(err)
+	}
+
+	if stdout {
+		w := bufio.NewWriter(os.Stdout)
+		if _, _, err := writePackData(w, hashes, opts); err != nil {
+			handleError(err)
+		}
+		if err := w.Flush(); err != nil {
+			handleError(err)
+		}
+		return
+	}
+	path, err := writePackFiles(prefix, hashes, opts)
+	if err != nil {
+		handleError(err)
+	}
+	fmt.Println(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), filepath.Base(prefix)+"-"), ".pack"))
+}
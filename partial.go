@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/piyushyadav1617/got/repo"
+)
+
+// parseFilter checks a --filter spec and returns it in the form sent to
+// servers, with any size suffix on a blob limit spelled out in bytes.
+// Only blob:none and blob:limit=<n> are supported.
+func parseFilter(spec string) (string, error) {
+	if spec == "blob:none" {
+		return spec, nil
+	}
+	if limit, ok := strings.CutPrefix(spec, "blob:limit="); ok {
+		if n, err := parseSize(limit); err == nil && n >= 0 {
+			return "blob:limit=" + strconv.FormatInt(n, 10), nil
+		}
+	}
+	return "", fmt.Errorf("invalid filter-spec '%s'", spec)
+}
+
+// registerPromisor makes the named remote the one a partial clone fetches
+// the objects it left out from, remembering filter for later fetches.
+func registerPromisor(name, filter string) error {
+	for _, kv := range [][2]string{
+		{"core.repositoryformatversion", "1"},
+		{"extensions.partialClone", name},
+		{"remote." + name + ".promisor", "true"},
+		{"remote." + name + ".partialclonefilter", filter},
+	} {
+		if err := setConfigValue(commonPath("config"), kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promisorRemote returns the remote a partial clone fetches missing objects
+// from, or "" for a complete repository. The config is read afresh, as
+// clone sets it up after the repository was opened.
+func promisorRemote() (string, *Config, error) {
+	config, err := readRepoConfig(commonPath("config"))
+	if err != nil {
+		return "", nil, err
+	}
+	name, _ := config.Get("extensions", "partialClone")
+	return name, config, nil
+}
+
+// markPromisorPack records that the pack at packPath came from the promisor
+// remote, so objects it references may be missing without that being an
+// error.
+func markPromisorPack(packPath string) error {
+	return os.WriteFile(strings.TrimSuffix(packPath, ".pack")+".promisor", nil, 0644)
+}
+
+// isPromisorPack reports whether p came from the promisor remote.
+func isPromisorPack(p *repo.Pack) bool {
+	_, err := os.Stat(strings.TrimSuffix(p.Path(), ".pack") + ".promisor")
+	return err == nil
+}
+
+// promisedTried holds the objects already asked of the promisor remote, so
+// one it does not send is not asked for again.
+var promisedTried struct {
+	sync.Mutex
+	hashes map[string]bool
+}
+
+// fetchPromised fetches objects missing from a partial clone from its
+// promisor remote, reporting whether it asked for any. Objects that are
+// present or were asked for before are left out.
+func fetchPromised(hashes []string) (bool, error) {
+	name, config, err := promisorRemote()
+	if err != nil || name == "" {
+		return false, err
+	}
+	promisedTried.Lock()
+	if promisedTried.hashes == nil {
+		promisedTried.hashes = make(map[string]bool)
+	}
+	var wants []string
+	for _, hash := range hashes {
+		if !promisedTried.hashes[hash] && !objectStored(hash) {
+			promisedTried.hashes[hash] = true
+			wants = append(wants, hash)
+		}
+	}
+	promisedTried.Unlock()
+	if len(wants) == 0 {
+		return false, nil
+	}
+
+	url, ok := config.Get("remote."+name, "url")
+	if !ok {
+		return false, fmt.Errorf("promisor remote '%s' has no url", name)
+	}
+	remote, err := openRemote(url)
+	if err != nil {
+		return false, err
+	}
+	defer remote.close()
+	adv, err := remote.discoverRefs("git-upload-pack", protocolVersion())
+	if err != nil {
+		return false, err
+	}
+	// As in git, what is fetched on demand leaves out blobs that the wanted
+	// objects reference; blobs asked for by name are still sent.
+	pack, _, err := fetchPack(remote, adv, wants, nil, deepen{}, "blob:none", nil)
+	if err != nil {
+		return false, fmt.Errorf("could not fetch %s from promisor remote: %w", wants[0], err)
+	}
+	packPath, err := storePack(pack)
+	if err != nil {
+		return false, err
+	}
+	return true, markPromisorPack(packPath)
+}
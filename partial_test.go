@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newPartialClone pushes a two-commit history to a remote that serves
+// filtered fetches and clones it with --filter=blob:none and no checkout,
+// so that the clone has the commits and trees but none of the blobs.
+func newPartialClone(t *testing.T) string {
+	t.Helper()
+	url := newTestRemote(t)
+	remoteDir := strings.TrimPrefix(url, "ssh://localhost")
+	runGit(t, remoteDir, "config", "uploadpack.allowFilter", "true")
+	runGit(t, remoteDir, "config", "uploadpack.allowAnySHA1InWant", "true")
+
+	src := newTestRepo(t)
+	commitFile(t, src, "a", "first a\n", "first")
+	commitFile(t, src, "a", "second a\n", "second")
+	commitFile(t, src, "dir/b", "b\n", "third")
+	runGit(t, src, "push", "-q", url, "main")
+
+	parent := t.TempDir()
+	runGot(t, parent, "clone", "-q", "-n", "--filter=blob:none", url, "clone")
+	return filepath.Join(parent, "clone")
+}
+
+// promisorPacks counts the packs in dir's repository marked as coming from
+// the promisor remote.
+func promisorPacks(t *testing.T, dir string) int {
+	t.Helper()
+	marks, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "pack-*.promisor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(marks)
+}
+
+func TestLazyFetch(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"cat-file", []string{"cat-file", "-p", "HEAD:a"}, "second a\n"},
+		{"cat-file size", []string{"cat-file", "-s", "HEAD~1:a"}, "9\n"},
+		{"show", []string{"show", "HEAD~2:a"}, "first a\n"},
+		{"nested path", []string{"cat-file", "-p", "HEAD:dir/b"}, "b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newPartialClone(t)
+			if n := promisorPacks(t, dir); n != 1 {
+				t.Fatalf("clone left %d promisor packs, want 1", n)
+			}
+			if got := runGot(t, dir, tt.args...); got != tt.want {
+				t.Errorf("%s = %q, want %q", strings.Join(tt.args, " "), got, tt.want)
+			}
+			if n := promisorPacks(t, dir); n != 2 {
+				t.Errorf("after the lazy fetch there are %d promisor packs, want 2", n)
+			}
+			// Once fetched, the blob is read locally.
+			if got := runGot(t, dir, tt.args...); got != tt.want {
+				t.Errorf("%s again = %q, want %q", strings.Join(tt.args, " "), got, tt.want)
+			}
+			if n := promisorPacks(t, dir); n != 2 {
+				t.Errorf("a second read fetched again: %d promisor packs", n)
+			}
+		})
+	}
+}
+
+func TestLazyFetchCheckout(t *testing.T) {
+	dir := newPartialClone(t)
+	runGot(t, dir, "checkout", "-f", "main")
+	if got := runGit(t, dir, "status", "--porcelain"); got != "" {
+		t.Errorf("status after checkout:\n%s", got)
+	}
+	// Checkout fetches every blob it needs in one go.
+	if n := promisorPacks(t, dir); n != 2 {
+		t.Errorf("checkout left %d promisor packs, want 2", n)
+	}
+	runGit(t, dir, "fsck", "--no-progress")
+}
+
+func TestCloneBlobLimit(t *testing.T) {
+	url := newTestRemote(t)
+	remoteDir := strings.TrimPrefix(url, "ssh://localhost")
+	runGit(t, remoteDir, "config", "uploadpack.allowFilter", "true")
+	runGit(t, remoteDir, "config", "uploadpack.allowAnySHA1InWant", "true")
+
+	src := newTestRepo(t)
+	commitFile(t, src, "small", "small\n", "add small")
+	commitFile(t, src, "large", strings.Repeat("large\n", 400), "add large")
+	runGit(t, src, "push", "-q", url, "main")
+
+	parent := t.TempDir()
+	runGot(t, parent, "clone", "-q", "-n", "--filter=blob:limit=1k", url, "clone")
+	dir := filepath.Join(parent, "clone")
+
+	for key, want := range map[string]string{
+		"extensions.partialClone":          "origin",
+		"remote.origin.promisor":           "true",
+		"remote.origin.partialclonefilter": "blob:limit=1024",
+	} {
+		if got := strings.TrimSpace(runGit(t, dir, "config", key)); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	// git lists the objects the clone left out with a leading "?".
+	missing := runGit(t, dir, "rev-list", "--objects", "--missing=print", "HEAD")
+	large := strings.TrimSpace(runGit(t, src, "rev-parse", "HEAD:large"))
+	small := strings.TrimSpace(runGit(t, src, "rev-parse", "HEAD:small"))
+	if !strings.Contains(missing, "?"+large) || strings.Contains(missing, "?"+small) {
+		t.Errorf("want only the large blob %s missing, have:\n%s", large, missing)
+	}
+
+	if got := runGot(t, dir, "cat-file", "-s", "HEAD:large"); got != "2400\n" {
+		t.Errorf("size of the lazily fetched blob = %q, want 2400", got)
+	}
+	if n := promisorPacks(t, dir); n != 2 {
+		t.Errorf("after the lazy fetch there are %d promisor packs, want 2", n)
+	}
+
+	// Later fetches from the promisor remote use the same filter.
+	commitFile(t, src, "large2", strings.Repeat("large2\n", 400), "add large2")
+	runGit(t, src, "push", "-q", url, "main")
+	runGot(t, dir, "fetch", "-q", "origin")
+	large2 := strings.TrimSpace(runGit(t, src, "rev-parse", "HEAD:large2"))
+	if missing := runGit(t, dir, "rev-list", "--objects", "--missing=print", "origin/main"); !strings.Contains(missing, "?"+large2) {
+		t.Errorf("fetch brought in the large blob %s:\n%s", large2, missing)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxPktLine is the largest pkt-line, including its four byte length.
+const maxPktLine = 65520
+
+// errFlush is returned by readPktLine for a flush packet ("0000").
+var errFlush = errors.New("flush packet")
+
+// readPktLine reads one pkt-line and returns its payload. A flush packet
+// returns errFlush; delimiter and response-end packets return an empty
+// payload.
+func readPktLine(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	n, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("bad pkt-line length %q", header)
+	}
+	switch {
+	case n == 0:
+		return nil, errFlush
+	case n < 4:
+		return []byte{}, nil
+	case n > maxPktLine:
+		return nil, fmt.Errorf("pkt-line too long: %d bytes", n)
+	}
+	payload := make([]byte, n-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// writePktLine writes payload as one pkt-line.
+func writePktLine(w io.Writer, payload string) error {
+	if len(payload)+4 > maxPktLine {
+		return fmt.Errorf("pkt-line too long: %d bytes", len(payload)+4)
+	}
+	_, err := fmt.Fprintf(w, "%04x%s", len(payload)+4, payload)
+	return err
+}
+
+// writeDelim writes a delimiter packet, which separates the sections of a
+// version 2 request.
+func writeDelim(w io.Writer) error {
+	_, err := io.WriteString(w, "0001")
+	return err
+}
+
+// writeFlush writes a flush packet.
+func writeFlush(w io.Writer) error {
+	_, err := io.WriteString(w, "0000")
+	return err
+}
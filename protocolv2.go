@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// writeCommand starts a version 2 request for command: the command, the
+// capabilities the client takes up, and the delimiter before its
+// arguments.
+func writeCommand(req *bytes.Buffer, adv *refAdvertisement, command string) {
+	writePktLine(req, "command="+command+"\n")
+	if adv.has("agent") {
+		writePktLine(req, "agent=got/"+gotVersion+"\n")
+	}
+	if format, ok := adv.capValue("object-format"); ok {
+		writePktLine(req, "object-format="+format+"\n")
+	}
+	writeDelim(req)
+}
+
+// lsRefs lists the server's refs with the version 2 ls-refs command, with
+// the targets of symbolic refs and the objects tags peel to. Given
+// prefixes, the server sends only refs starting with one of them. A HEAD
+// on an unborn branch is listed with no hash, when the server can say so.
+func lsRefs(t Transport, adv *refAdvertisement, prefixes []string) ([]advertisedRef, error) {
+	features, ok := adv.capValue("ls-refs")
+	if !ok {
+		return nil, errors.New("the server does not support ls-refs")
+	}
+	var req bytes.Buffer
+	writeCommand(&req, adv, "ls-refs")
+	writePktLine(&req, "symrefs\n")
+	writePktLine(&req, "peel\n")
+	if slices.Contains(strings.Fields(features), "unborn") {
+		writePktLine(&req, "unborn\n")
+	}
+	for _, prefix := range prefixes {
+		writePktLine(&req, "ref-prefix "+prefix+"\n")
+	}
+	writeFlush(&req)
+
+	body, err := t.call("git-upload-pack", req.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var refs []advertisedRef
+	for {
+		line, err := readPktLine(body)
+		if errors.Is(err, errFlush) {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading ls-refs response: %w", err)
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		if msg, ok := strings.CutPrefix(text, "ERR "); ok {
+			return nil, fmt.Errorf("remote error: %s", msg)
+		}
+		// Each line is "<hash> <ref>", or "unborn HEAD", and any
+		// attributes.
+		fields := strings.Split(text, " ")
+		if len(fields) < 2 || (fields[0] != "unborn" && !isFullHash(fields[0])) {
+			return nil, fmt.Errorf("bad ls-refs line %q", text)
+		}
+		ref := advertisedRef{Hash: fields[0], Name: fields[1]}
+		if ref.Hash == "unborn" {
+			ref.Hash = ""
+		}
+		for _, attr := range fields[2:] {
+			if target, ok := strings.CutPrefix(attr, "symref-target:"); ok {
+				ref.Symref = target
+			} else if peeled, ok := strings.CutPrefix(attr, "peeled:"); ok {
+				ref.Peeled = peeled
+			}
+		}
+		refs = append(refs, ref)
+	}
+}
+
+// fetchPackV2 is fetchPack for a server speaking version 2. The request
+// ends with "done", so the server skips negotiation and answers with the
+// packfile section, whose lines carry side-band channels as in version 0.
+// Before it comes the shallow-info section when we sent shallow or deepen
+// lines; any other section is passed over.
+func fetchPackV2(t Transport, adv *refAdvertisement, wants, haves []string, d deepen, filter string, progress io.Writer) ([]byte, shallowUpdate, error) {
+	features, ok := adv.capValue("fetch")
+	if !ok && !adv.has("fetch") {
+		return nil, shallowUpdate{}, errors.New("the server does not support fetch")
+	}
+	var req bytes.Buffer
+	writeCommand(&req, adv, "fetch")
+	writePktLine(&req, "ofs-delta\n")
+	writePktLine(&req, "include-tag\n")
+	if progress == nil {
+		writePktLine(&req, "no-progress\n")
+	}
+	for _, want := range wants {
+		writePktLine(&req, "want "+want+"\n")
+	}
+	shallow, err := writeShallowRequest(&req, d)
+	if err != nil {
+		return nil, shallowUpdate{}, err
+	}
+	if shallow && !slices.Contains(strings.Fields(features), "shallow") {
+		return nil, shallowUpdate{}, errors.New("the server does not support shallow clients")
+	}
+	if d.relative {
+		writePktLine(&req, "deepen-relative\n")
+	}
+	if filter != "" {
+		if slices.Contains(strings.Fields(features), "filter") {
+			writePktLine(&req, "filter "+filter+"\n")
+		} else {
+			fmt.Fprintln(os.Stderr, "warning: filtering not recognized by server, ignoring")
+		}
+	}
+	for _, have := range haves {
+		writePktLine(&req, "have "+have+"\n")
+	}
+	writePktLine(&req, "done\n")
+	writeFlush(&req)
+
+	body, err := t.call("git-upload-pack", req.Bytes())
+	if err != nil {
+		return nil, shallowUpdate{}, err
+	}
+	defer body.Close()
+	var update shallowUpdate
+	section := ""
+	for {
+		line, err := readPktLine(body)
+		if err != nil {
+			return nil, shallowUpdate{}, fmt.Errorf("reading fetch response: %w", err)
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		if msg, ok := strings.CutPrefix(text, "ERR "); ok {
+			return nil, shallowUpdate{}, fmt.Errorf("remote error: %s", msg)
+		}
+		switch {
+		case text == "packfile":
+			pack, err := readSideband(body, nil, progress)
+			return pack, update, err
+		case len(line) == 0:
+			// A delimiter ends the section.
+			section = ""
+		case section == "":
+			section = text
+		case section == "shallow-info":
+			if _, err := update.parseLine(text); err != nil {
+				return nil, shallowUpdate{}, err
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reachableObjects returns every object reachable from the refs, HEAD,
+// the reflogs and the index. Objects that are referenced but missing are
+// skipped; fsck is the place to report those.
+func reachableObjects() (map[string]bool, error) {
+	roots, blobs, err := reachabilityRoots(true)
+	if err != nil {
+		return nil, err
+	}
+	reachable := make(map[string]bool)
+	for _, hash := range blobs {
+		reachable[hash] = true
+	}
+
+	pending := roots
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if hash == zeroHash || reachable[hash] {
+			continue
+		}
+		reachable[hash] = true
+
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			continue
+		}
+		references, err := objectReferences(hash, objectType, content)
+		if err != nil {
+			return nil, fmt.Errorf("object %s: %w", hash, err)
+		}
+		for refType, hashes := range references {
+			for _, h := range hashes {
+				// Blobs reference nothing, so there is no need to read them.
+				if refType == "blob" {
+					reachable[h] = true
+				} else {
+					pending = append(pending, h)
+				}
+			}
+		}
+	}
+	return reachable, nil
+}
+
+// reachabilityRoots returns the objects that keep others alive: what the
+// refs and HEAD point at, every reflog entry unless reflogs is false, and
+// the index's cached trees. The blobs staged in the index are returned
+// separately since they reference nothing.
+func reachabilityRoots(reflogs bool) (roots, blobs []string, err error) {
+	refs, err := listRefs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ref := range append(refs, "HEAD") {
+		hash, err := resolveRef(ref)
+		if errors.Is(err, errRefNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		roots = append(roots, hash)
+	}
+
+	if reflogs {
+		err = filepath.WalkDir(commonPath("logs"), func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(commonPath("logs"), path)
+			if err != nil {
+				return err
+			}
+			entries, err := readReflog(filepath.ToSlash(rel))
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				roots = append(roots, entry.Old, entry.New)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range idx.Entries {
+		if entry.Mode != 0160000 {
+			blobs = append(blobs, entry.Hash)
+		}
+	}
+	return append(roots, idx.cacheTrees...), blobs, nil
+}
+
+// parseExpiry parses an --expire value: "now", "never", a Unix timestamp,
+// an RFC 3339 date or a relative "<n>.<unit>.ago" such as "2.weeks.ago".
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "now":
+		return now, nil
+	case "never":
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == '.' || r == ' ' })
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil {
+			switch strings.TrimSuffix(fields[1], "s") {
+			case "second":
+				return now.Add(-time.Duration(n) * time.Second), nil
+			case "minute":
+				return now.Add(-time.Duration(n) * time.Minute), nil
+			case "hour":
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case "day":
+				return now.AddDate(0, 0, -n), nil
+			case "week":
+				return now.AddDate(0, 0, -7*n), nil
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry date '%s'", value)
+}
+
+func runPrune(args []string) {
+	dryRun, verbose := false, false
+	var expire time.Time
+	hasExpire := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-n" || arg == "--dry-run":
+			dryRun = true
+		case arg == "-v" || arg == "--verbose":
+			verbose = true
+		case arg == "--expire" || strings.HasPrefix(arg, "--expire="):
+			value, found := strings.CutPrefix(arg, "--expire=")
+			if !found {
+				if i+1 >= len(args) {
+					handleError(errors.New("option --expire requires a value"))
+				}
+				i++
+				value = args[i]
+			}
+			t, err := parseExpiry(value, time.Now())
+			if err != nil {
+				handleError(err)
+			}
+			expire, hasExpire = t, true
+		default:
+			handleError(errors.New("usage: got prune [-n] [-v] [--expire <time>]"))
+		}
+	}
+
+	reachable, err := reachableObjects()
+	if err != nil {
+		handleError(err)
+	}
+	var before *time.Time
+	if hasExpire {
+		before = &expire
+	}
+	if err := pruneLooseObjects(reachable, before, dryRun, verbose); err != nil {
+		handleError(err)
+	}
+}
+
+// pruneLooseObjects deletes the loose objects that are not reachable. If
+// expire is set, only those last modified before it are deleted. dryRun
+// and verbose print what is (or would be) deleted.
+func pruneLooseObjects(reachable map[string]bool, expire *time.Time, dryRun, verbose bool) error {
+	objects, err := looseObjects()
+	if err != nil {
+		return err
+	}
+	for hash, path := range objects {
+		if reachable[hash] {
+			continue
+		}
+		if expire != nil {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().Before(*expire) {
+				continue
+			}
+		}
+
+		if dryRun || verbose {
+			objectType, _, err := objectHeader(hash)
+			if err != nil {
+				objectType = "unknown"
+			}
+			fmt.Printf("%s %s\n", hash, objectType)
+		}
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		os.Remove(filepath.Dir(path))
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// pushUpdate is one ref the push asks the server to change. A zero hash
+// for new deletes the ref.
+type pushUpdate struct {
+	src, dst string
+	old, new string
+	force    bool
+
+	// status is empty until the update is rejected locally or the server
+	// reports on it.
+	status string
+}
+
+func runPush(args []string) {
+	var force, quiet bool
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "-f" || arg == "--force":
+			force = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case strings.HasPrefix(arg, "-"):
+			handleError(errors.New("usage: got push [-f] [-q] [<remote> [<refspec>...]]"))
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	repo, err := currentRepo()
+	if err != nil {
+		handleError(err)
+	}
+	branch, onBranch, err := currentBranch()
+	if err != nil {
+		handleError(err)
+	}
+	name := "origin"
+	if len(positional) > 0 {
+		name, positional = positional[0], positional[1:]
+	} else if onBranch {
+		if remote, ok := repo.Config.Get("branch."+branch, "pushRemote"); ok {
+			name = remote
+		} else if remote, ok := repo.Config.Get("branch."+branch, "remote"); ok && remote != "." {
+			name = remote
+		}
+	}
+
+	// Without refspecs the remote's push refspecs are used, or else the
+	// current branch is pushed to the branch of the same name.
+	specs := positional
+	if len(specs) == 0 {
+		if specs, err = expandPushRefspecs(repo.Config.values[configKey("remote."+name, "push")]); err != nil {
+			handleError(err)
+		}
+	}
+	if len(specs) == 0 {
+		if !onBranch {
+			handleError(errors.New("you are not currently on a branch; name the refspec to push"))
+		}
+		specs = []string{"refs/heads/" + branch}
+	}
+
+	url := remotePushURL(repo.Config, name)
+	remote, err := openRemote(url)
+	if err != nil {
+		handleError(err)
+	}
+	adv, err := remote.discoverRefs("git-receive-pack", 0)
+	if err != nil {
+		handleError(err)
+	}
+	defer remote.close()
+
+	var updates []*pushUpdate
+	for _, spec := range specs {
+		u, err := parsePushRefspec(spec, adv)
+		if err != nil {
+			handleError(err)
+		}
+		u.force = u.force || force
+		updates = append(updates, u)
+	}
+
+	failed, err := sendPack(remote, adv, updates, quiet)
+	if err != nil {
+		handleError(err)
+	}
+
+	// Remote-tracking refs follow what the server accepted.
+	fetchSpecs := repo.Config.values[configKey("remote."+name, "fetch")]
+	for _, u := range updates {
+		if u.status != "ok" {
+			continue
+		}
+		for _, spec := range fetchSpecs {
+			local, ok := mapRefspec(spec, u.dst)
+			if !ok {
+				continue
+			}
+			if u.new == zeroHash {
+				err = deleteRef(local)
+				if errors.Is(err, errRefNotFound) {
+					err = nil
+				}
+			} else {
+				old, _ := resolveRef(local)
+				if err = writeRef(local, u.new); err == nil {
+					err = appendReflog(local, old, u.new, "update by push")
+				}
+			}
+			if err != nil {
+				handleError(err)
+			}
+			break
+		}
+	}
+
+	if !quiet || failed {
+		fmt.Fprintf(os.Stderr, "To %s\n", url)
+		for _, u := range updates {
+			fmt.Fprintln(os.Stderr, describePush(u))
+		}
+	}
+	for _, u := range updates {
+		if u.status == "rejected: non-fast-forward" {
+			advise("pushNonFastForward", "Updates were rejected because a pushed branch tip is behind its remote\n"+
+				"counterpart. If you want to integrate the remote changes, use 'got fetch'\n"+
+				"and 'got merge' before pushing again.")
+			break
+		}
+	}
+	if failed {
+		exit(1)
+	}
+}
+
+// expandPushRefspecs turns each configured refspec with a "*" into one for
+// every local ref it matches.
+func expandPushRefspecs(specs []string) ([]string, error) {
+	var expanded []string
+	for _, spec := range specs {
+		if !strings.Contains(spec, "*") {
+			expanded = append(expanded, spec)
+			continue
+		}
+		refs, err := listRefs()
+		if err != nil {
+			return nil, err
+		}
+		force := ""
+		if strings.HasPrefix(spec, "+") {
+			force = "+"
+		}
+		for _, ref := range refs {
+			if dst, ok := mapRefspec(spec, ref); ok {
+				expanded = append(expanded, force+ref+":"+dst)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// parsePushRefspec turns "[+]<src>[:<dst>]" into an update. The source is
+// any revision; a short branch or tag name also names the destination
+// when no dst is given. An empty source deletes dst.
+func parsePushRefspec(spec string, adv *refAdvertisement) (*pushUpdate, error) {
+	u := &pushUpdate{}
+	spec, u.force = strings.CutPrefix(spec, "+")
+	src, dst, hasDst := strings.Cut(spec, ":")
+
+	if src == "" {
+		if !hasDst || dst == "" {
+			return nil, fmt.Errorf("invalid refspec '%s'", spec)
+		}
+		u.new = zeroHash
+	} else {
+		hash, err := resolveRevision(src)
+		if err != nil {
+			return nil, fmt.Errorf("src refspec %s does not match any", src)
+		}
+		u.new = hash
+		u.src = src
+		if !strings.HasPrefix(src, "refs/") {
+			for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+				if exists, _ := refExists(prefix + src); exists {
+					u.src = prefix + src
+					break
+				}
+			}
+		}
+	}
+
+	if !hasDst {
+		dst = u.src
+	}
+	if !strings.HasPrefix(dst, "refs/") {
+		// A short destination is a branch unless the source is a tag, or
+		// the server already has a tag by that name.
+		if _, isTag := adv.lookup("refs/tags/" + dst); isTag || strings.HasPrefix(u.src, "refs/tags/") {
+			dst = "refs/tags/" + dst
+		} else {
+			dst = "refs/heads/" + dst
+		}
+	}
+	if !strings.HasPrefix(dst, "refs/") || checkRefName(strings.TrimPrefix(dst, "refs/")) != nil {
+		return nil, fmt.Errorf("invalid destination ref '%s'", dst)
+	}
+	u.dst = dst
+
+	u.old = zeroHash
+	if ref, ok := adv.lookup(dst); ok {
+		u.old = ref.Hash
+	}
+	if u.src == "" {
+		u.src = "(delete)"
+	}
+	return u, nil
+}
+
+// sendPack checks each update, sends the accepted ones to git-receive-pack
+// with the objects the server lacks, and records the server's verdict in
+// each update's status. It reports whether any update failed.
+func sendPack(remote Transport, adv *refAdvertisement, updates []*pushUpdate, quiet bool) (bool, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return false, err
+	}
+
+	var send []*pushUpdate
+	for _, u := range updates {
+		switch {
+		case u.old == zeroHash && u.new == zeroHash:
+			u.status = "rejected: remote ref does not exist"
+		case u.old == u.new:
+			u.status = "up to date"
+		case u.new == zeroHash && !adv.has("delete-refs"):
+			u.status = "rejected: remote does not support deleting refs"
+		case u.old == zeroHash || u.new == zeroHash || u.force:
+			send = append(send, u)
+		case !repo.ObjectExists(u.old):
+			u.status = "rejected: fetch first"
+		default:
+			reachable, err := ancestors(u.new)
+			if err != nil {
+				return false, err
+			}
+			if !reachable[u.old] {
+				u.status = "rejected: non-fast-forward"
+				continue
+			}
+			send = append(send, u)
+		}
+	}
+	if len(send) == 0 {
+		return failedPush(updates), nil
+	}
+
+	caps := []string{"report-status", "agent=got/" + gotVersion}
+	if adv.has("side-band-64k") {
+		caps = append(caps, "side-band-64k")
+	}
+	if quiet && adv.has("quiet") {
+		caps = append(caps, "quiet")
+	}
+	var req bytes.Buffer
+	for i, u := range send {
+		line := fmt.Sprintf("%s %s %s", u.old, u.new, u.dst)
+		if i == 0 {
+			line += "\x00" + strings.Join(caps, " ")
+		}
+		writePktLine(&req, line)
+	}
+	writeFlush(&req)
+
+	// Everything reachable from what the server already has is left out.
+	var roots, haves []string
+	for _, u := range send {
+		if u.new != zeroHash {
+			roots = append(roots, u.new)
+		}
+	}
+	for _, ref := range adv.Refs {
+		if repo.ObjectExists(ref.Hash) {
+			haves = append(haves, ref.Hash)
+		}
+	}
+	if len(roots) > 0 {
+		known, err := objectClosure(haves, nil)
+		if err != nil {
+			return false, err
+		}
+		stop := make(map[string]bool, len(known))
+		for _, hash := range known {
+			stop[hash] = true
+		}
+		objects, err := objectClosure(roots, stop)
+		if err != nil {
+			return false, err
+		}
+		opts, err := configPackOptions()
+		if err != nil {
+			return false, err
+		}
+		if _, _, err := writePackData(&req, objects, opts); err != nil {
+			return false, err
+		}
+	}
+
+	body, err := remote.call("git-receive-pack", req.Bytes())
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	var report io.Reader = body
+	if adv.has("side-band-64k") {
+		first, err := readPktLine(body)
+		if err != nil {
+			return false, fmt.Errorf("reading receive-pack response: %w", err)
+		}
+		data, err := readSideband(body, first, progressWriter(quiet))
+		if err != nil {
+			return false, err
+		}
+		report = bytes.NewReader(data)
+	}
+	if err := readReportStatus(report, send); err != nil {
+		return false, err
+	}
+	return failedPush(updates), nil
+}
+
+// readReportStatus reads the server's "unpack ok" line and then one
+// "ok <ref>" or "ng <ref> <reason>" line per update.
+func readReportStatus(r io.Reader, updates []*pushUpdate) error {
+	line, err := readPktLine(r)
+	if err != nil {
+		return fmt.Errorf("reading report-status: %w", err)
+	}
+	unpack := strings.TrimSuffix(string(line), "\n")
+	if unpack != "unpack ok" {
+		return fmt.Errorf("remote unpack failed: %s", strings.TrimPrefix(unpack, "unpack "))
+	}
+	for {
+		line, err := readPktLine(r)
+		if errors.Is(err, errFlush) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading report-status: %w", err)
+		}
+		verdict, rest, _ := strings.Cut(strings.TrimSuffix(string(line), "\n"), " ")
+		ref, reason, _ := strings.Cut(rest, " ")
+		for _, u := range updates {
+			if u.dst != ref {
+				continue
+			}
+			if verdict == "ok" {
+				u.status = "ok"
+			} else {
+				u.status = "remote rejected: " + reason
+			}
+		}
+	}
+	for _, u := range updates {
+		if u.status == "" {
+			u.status = "remote rejected: no status reported"
+		}
+	}
+	return nil
+}
+
+// failedPush reports whether any update was rejected.
+func failedPush(updates []*pushUpdate) bool {
+	for _, u := range updates {
+		if u.status != "ok" && u.status != "up to date" {
+			return true
+		}
+	}
+	return false
+}
+
+// describePush formats an update's outcome the way git push does.
+func describePush(u *pushUpdate) string {
+	refs := shortRefName(u.src) + " -> " + shortRefName(u.dst)
+	switch {
+	case u.status == "up to date":
+		return fmt.Sprintf(" = %-17s %s", "[up to date]", refs)
+	case strings.HasPrefix(u.status, "remote rejected: "):
+		return fmt.Sprintf(" ! %-17s %s (%s)", "[remote rejected]", refs, strings.TrimPrefix(u.status, "remote rejected: "))
+	case strings.HasPrefix(u.status, "rejected: "):
+		return fmt.Sprintf(" ! %-17s %s (%s)", "[rejected]", refs, strings.TrimPrefix(u.status, "rejected: "))
+	case u.new == zeroHash:
+		return fmt.Sprintf(" - %-17s %s", "[deleted]", shortRefName(u.dst))
+	case u.old == zeroHash && strings.HasPrefix(u.dst, "refs/tags/"):
+		return fmt.Sprintf(" * %-17s %s", "[new tag]", refs)
+	case u.old == zeroHash:
+		return fmt.Sprintf(" * %-17s %s", "[new branch]", refs)
+	case u.force:
+		return fmt.Sprintf(" + %-17s %s (forced update)", u.old[:7]+"..."+u.new[:7], refs)
+	}
+	return fmt.Sprintf("   %-17s %s", u.old[:7]+".."+u.new[:7], refs)
+}
+
+// objectClosure returns every object reachable from roots, without
+// descending into objects in stop. Blobs are listed without being read.
+func objectClosure(roots []string, stop map[string]bool) ([]string, error) {
+	var found []string
+	seen := make(map[string]bool)
+	pending := append([]string(nil), roots...)
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if hash == zeroHash || seen[hash] || stop[hash] {
+			continue
+		}
+		seen[hash] = true
+		found = append(found, hash)
+
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		references, err := objectReferences(hash, objectType, content)
+		if err != nil {
+			return nil, fmt.Errorf("object %s: %w", hash, err)
+		}
+		for refType, hashes := range references {
+			for _, h := range hashes {
+				if refType == "blob" && !seen[h] && !stop[h] {
+					seen[h] = true
+					found = append(found, h)
+				} else if refType != "blob" {
+					pending = append(pending, h)
+				}
+			}
+		}
+	}
+	return found, nil
+}
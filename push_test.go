@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPushNonFastForwardAdvice(t *testing.T) {
+	tests := []struct {
+		name   string
+		config []string
+		want   bool
+	}{
+		{name: "default", want: true},
+		{name: "enabled", config: []string{"advice.pushNonFastForward", "true"}, want: true},
+		{name: "disabled", config: []string{"advice.pushNonFastForward", "false"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := newTestRemote(t)
+			dir := newTestRepo(t)
+			runGit(t, dir, "remote", "add", "origin", url)
+			commitFile(t, dir, "a", "a\n", "first")
+			commitFile(t, dir, "a", "b\n", "second")
+			runGot(t, dir, "push", "origin", "main")
+			runGit(t, dir, "reset", "-q", "--hard", "HEAD~1")
+			commitFile(t, dir, "a", "c\n", "diverged")
+			if tt.config != nil {
+				runGit(t, dir, append([]string{"config"}, tt.config...)...)
+			}
+
+			_, err := runCommand(dir, gotBinary, "push", "origin", "main")
+			if err == nil {
+				t.Fatal("non-fast-forward push succeeded")
+			}
+			if !strings.Contains(err.Error(), "(non-fast-forward)") {
+				t.Fatalf("push was not rejected as non-fast-forward:\n%v", err)
+			}
+			if got := strings.Contains(err.Error(), "hint: Updates were rejected"); got != tt.want {
+				t.Errorf("advice shown = %v, want %v:\n%v", got, tt.want, err)
+			}
+		})
+	}
+}
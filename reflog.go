@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const zeroHash = "0000000000000000000000000000000000000000"
+
+// reflogEntry is one line of a .git/logs file.
+type reflogEntry struct {
+	Old       string
+	New       string
+	Committer string
+	Message   string
+}
+
+func reflogPath(ref string) string {
+	if strings.HasPrefix(ref, "refs/") {
+		return commonPath("logs", filepath.FromSlash(ref))
+	}
+	return gitPath("logs", filepath.FromSlash(ref))
+}
+
+// appendReflog records that ref moved from oldHash to newHash, if updates
+// to ref are logged. An empty oldHash is written as all zeros, for a ref
+// that did not exist before.
+func appendReflog(ref, oldHash, newHash, message string) error {
+	logged, err := logsRef(ref)
+	if err != nil || !logged {
+		return err
+	}
+	if oldHash == "" {
+		oldHash = zeroHash
+	}
+	committer, err := reflogIdentity(time.Now())
+	if err != nil {
+		return err
+	}
+
+	path := reflogPath(ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	message = strings.ReplaceAll(message, "\n", " ")
+	if _, err := fmt.Fprintf(f, "%s %s %s\t%s\n", oldHash, newHash, committer, message); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// logsRef reports whether updates to ref are recorded in a reflog. As in
+// git, HEAD, branches and remote-tracking refs are, unless
+// core.logAllRefUpdates is false; "always" logs every ref. refs/stash is
+// always logged, since its log is the stash list.
+func logsRef(ref string) (bool, error) {
+	if ref == stashRef {
+		return true, nil
+	}
+	repo, err := currentRepo()
+	if err != nil {
+		return false, err
+	}
+	if value, _ := repo.Config.Get("core", "logAllRefUpdates"); value == "always" {
+		return true, nil
+	}
+	if !repo.Config.Bool("core", "logAllRefUpdates", true) {
+		return false, nil
+	}
+	return ref == "HEAD" || strings.HasPrefix(ref, "refs/heads/") || strings.HasPrefix(ref, "refs/remotes/") || strings.HasPrefix(ref, "refs/notes/"), nil
+}
+
+// reflogRef expands a name given to reflog commands to the ref whose log
+// exists, trying the same prefixes as revisions do. An empty name is the
+// current branch and "@" is HEAD.
+func reflogRef(name string) (string, error) {
+	switch name {
+	case "":
+		branch, onBranch, err := currentBranch()
+		if err != nil || !onBranch {
+			return "HEAD", err
+		}
+		return "refs/heads/" + branch, nil
+	case "@", "HEAD":
+		return "HEAD", nil
+	}
+	for _, candidate := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if _, err := os.Stat(reflogPath(candidate)); err == nil {
+			return candidate, nil
+		}
+	}
+	return name, nil
+}
+
+// reflogIdentity is the committer identity, falling back to the login
+// name and host so that moving HEAD never fails for lack of user.name.
+func reflogIdentity(now time.Time) (string, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return "", err
+	}
+	if committer, err := repo.identity("committer", now); err == nil {
+		return committer, nil
+	}
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s <%s@%s> %s", name, name, host, formatGitTimestamp(now)), nil
+}
+
+// writeReflog replaces ref's log with entries, through a lock file.
+func writeReflog(ref string, entries []reflogEntry) error {
+	var buf strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "%s %s %s\t%s\n", entry.Old, entry.New, entry.Committer, entry.Message)
+	}
+	path := reflogPath(ref)
+	if err := os.WriteFile(path+".lock", []byte(buf.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".lock", path)
+}
+
+// readReflog returns the entries recorded for ref, oldest first.
+func readReflog(ref string) ([]reflogEntry, error) {
+	f, err := os.Open(reflogPath(ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []reflogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		header, message, _ := strings.Cut(line, "\t")
+		fields := strings.SplitN(header, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s: malformed reflog line %q", reflogPath(ref), line)
+		}
+		entries = append(entries, reflogEntry{Old: fields[0], New: fields[1], Committer: fields[2], Message: message})
+	}
+	return entries, scanner.Err()
+}
+
+func runReflog(args []string) {
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		handleError(errors.New("usage: got reflog [show] [<ref>]"))
+	}
+
+	name := "HEAD"
+	if len(args) == 1 {
+		name = args[0]
+	}
+	ref, err := reflogRef(name)
+	if err != nil {
+		handleError(err)
+	}
+
+	entries, err := readReflog(ref)
+	if err != nil {
+		handleError(err)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Printf("%s %s@{%d}: %s\n", entry.New[:7], name, len(entries)-1-i, entry.Message)
+	}
+}
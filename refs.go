@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/piyushyadav1617/got/refs"
+)
+
+var errRefNotFound = refs.ErrNotFound
+
+// refStore returns the refs of the repository setupRepository found. It is
+// built from gitDir and commonDir rather than currentRepo, so init and clone
+// can write refs into a repository they are still creating.
+func refStore() *refs.Store {
+	return &refs.Store{GitDir: gitDir, CommonDir: commonDir}
+}
+
+// refPath returns the file for the ref name. Refs under refs/ are shared;
+// HEAD and the other pseudo-refs belong to the work tree.
+func refPath(name string) string {
+	return refStore().Path(name)
+}
+
+// listRefs returns the full names of all refs, e.g. "refs/heads/main", in
+// sorted order. Both loose refs under .git/refs and packed refs are
+// included.
+func listRefs() ([]string, error) {
+	return refStore().List()
+}
+
+// readPackedRefs parses .git/packed-refs, returning its entries keyed by
+// ref name. A missing file yields no refs.
+func readPackedRefs() (map[string]refs.Packed, error) {
+	return refStore().ReadPacked()
+}
+
+// readRefFile returns the raw contents of a ref file, e.g. HEAD or
+// refs/heads/main, with surrounding whitespace removed.
+func readRefFile(name string) (string, error) {
+	return refStore().ReadFile(name)
+}
+
+// resolveRef follows symbolic refs starting at name until it reaches an
+// object hash. Loose refs take precedence over entries in packed-refs.
+func resolveRef(name string) (string, error) {
+	return refStore().Resolve(name)
+}
+
+func resolvePackedRef(name string) (string, error) {
+	return refStore().ResolvePacked(name)
+}
+
+// refExists reports whether name exists as a loose or packed ref.
+func refExists(name string) (bool, error) {
+	return refStore().Exists(name)
+}
+
+// currentBranch returns the short name of the branch HEAD points at, or
+// false if HEAD is detached.
+func currentBranch() (string, bool, error) {
+	value, err := readRefFile("HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	target, symbolic := strings.CutPrefix(value, "ref: ")
+	if !symbolic {
+		return "", false, nil
+	}
+	return strings.TrimPrefix(target, "refs/heads/"), true, nil
+}
+
+// writeRef points the ref name at hash, creating parent directories as
+// needed.
+func writeRef(name, hash string) error {
+	return refStore().Write(name, hash)
+}
+
+// writeSymbolicRef makes name, usually HEAD, point at the ref target.
+func writeSymbolicRef(name, target string) error {
+	return refStore().WriteSymbolic(name, target)
+}
+
+// deleteRef removes name, both as a loose ref and from packed-refs, and
+// its reflog.
+func deleteRef(name string) error {
+	if err := refStore().Delete(name); err != nil {
+		return err
+	}
+	// The reflog goes with the ref, as in git.
+	if err := os.Remove(reflogPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// checkRefName rejects names git would not accept as a ref component.
+func checkRefName(name string) error {
+	return refs.CheckName(name)
+}
+
+// errNoCommits is wrapped by headCommit's error for an unborn branch.
+var errNoCommits = errors.New("does not have any commits yet")
+
+// headCommit resolves HEAD, reporting an unborn branch with the message
+// git uses rather than a missing ref.
+func headCommit() (string, error) {
+	hash, err := resolveRef("HEAD")
+	if errors.Is(err, errRefNotFound) {
+		branch, _, _ := currentBranch()
+		return "", fmt.Errorf("your current branch '%s' %w", branch, errNoCommits)
+	}
+	return hash, err
+}
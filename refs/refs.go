@@ -0,0 +1,295 @@
+// Package refs reads and writes the refs of a git repository: loose ref
+// files, packed-refs and symbolic refs such as HEAD.
+package refs
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is wrapped by the errors for refs that do not exist.
+var ErrNotFound = errors.New("ref not found")
+
+// ErrLocked is wrapped by the errors for updates that found the ref's lock
+// file already there, because another update is under way or one crashed.
+var ErrLocked = errors.New("ref is locked")
+
+// maxSymrefDepth bounds chains of symbolic refs.
+const maxSymrefDepth = 5
+
+// Store is the refs of one repository. Refs under refs/ and packed-refs
+// are kept in CommonDir, which linked work trees share; HEAD and the other
+// pseudo-refs belong to a work tree and are kept in GitDir.
+type Store struct {
+	GitDir    string
+	CommonDir string
+}
+
+// Path returns the file for the ref name.
+func (s *Store) Path(name string) string {
+	if strings.HasPrefix(name, "refs/") {
+		return filepath.Join(s.CommonDir, filepath.FromSlash(name))
+	}
+	return filepath.Join(s.GitDir, filepath.FromSlash(name))
+}
+
+// List returns the full names of all refs, e.g. "refs/heads/main", in
+// sorted order. Both loose refs and packed refs are included.
+func (s *Store) List() ([]string, error) {
+	names := make(map[string]bool)
+	err := filepath.WalkDir(filepath.Join(s.CommonDir, "refs"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.CommonDir, path)
+		if err != nil {
+			return err
+		}
+		names[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	packed, err := s.ReadPacked()
+	if err != nil {
+		return nil, err
+	}
+	for name := range packed {
+		names[name] = true
+	}
+
+	refs := make([]string, 0, len(names))
+	for name := range names {
+		refs = append(refs, name)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// Packed is one entry of packed-refs. Peeled holds the commit an annotated
+// tag points at when git recorded it with a "^" line.
+type Packed struct {
+	Name   string
+	Hash   string
+	Peeled string
+}
+
+// ReadPacked parses packed-refs, returning its entries keyed by ref name.
+// A missing file yields no refs.
+func (s *Store) ReadPacked() (map[string]Packed, error) {
+	refs := make(map[string]Packed)
+	b, err := os.ReadFile(filepath.Join(s.CommonDir, "packed-refs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var last string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '^':
+			if last == "" {
+				return nil, errors.New("packed-refs: peeled line without a ref")
+			}
+			ref := refs[last]
+			ref.Peeled = line[1:]
+			refs[last] = ref
+		default:
+			hash, name, found := strings.Cut(line, " ")
+			if !found || !isFullHash(hash) {
+				return nil, fmt.Errorf("packed-refs: malformed line %q", line)
+			}
+			refs[name] = Packed{Name: name, Hash: hash}
+			last = name
+		}
+	}
+	return refs, nil
+}
+
+// ReadFile returns the raw contents of a loose ref file, e.g. HEAD or
+// refs/heads/main, with surrounding whitespace removed.
+func (s *Store) ReadFile(name string) (string, error) {
+	b, err := os.ReadFile(s.Path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Resolve follows symbolic refs starting at name until it reaches an
+// object hash. Loose refs take precedence over entries in packed-refs.
+func (s *Store) Resolve(name string) (string, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		value, err := s.ReadFile(name)
+		if errors.Is(err, ErrNotFound) {
+			return s.ResolvePacked(name)
+		}
+		if err != nil {
+			return "", err
+		}
+		target, symbolic := strings.CutPrefix(value, "ref: ")
+		if !symbolic {
+			return value, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("%s: too many levels of symbolic refs", name)
+}
+
+// ResolvePacked returns the hash packed-refs records for name.
+func (s *Store) ResolvePacked(name string) (string, error) {
+	packed, err := s.ReadPacked()
+	if err != nil {
+		return "", err
+	}
+	ref, ok := packed[name]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return ref.Hash, nil
+}
+
+// Exists reports whether name exists as a loose or packed ref.
+func (s *Store) Exists(name string) (bool, error) {
+	_, err := s.Resolve(name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Write points the ref name at hash, creating parent directories as
+// needed.
+func (s *Store) Write(name, hash string) error {
+	path := s.Path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeLocked(path, hash+"\n")
+}
+
+// WriteSymbolic makes name, usually HEAD, point at the ref target.
+func (s *Store) WriteSymbolic(name, target string) error {
+	return writeLocked(s.Path(name), "ref: "+target+"\n")
+}
+
+// writeLocked replaces the file at path with content the way git does:
+// content goes to path.lock, which must not exist yet, and is renamed over
+// path. Readers see the old file or the new one, never a partial write,
+// and of two concurrent updates the second fails instead of being lost.
+func writeLocked(path, content string) error {
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("unable to create '%s': %w", lock, ErrLocked)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(lock, path)
+	}
+	if err != nil {
+		os.Remove(lock)
+	}
+	return err
+}
+
+// Delete removes name, both as a loose ref and from packed-refs.
+func (s *Store) Delete(name string) error {
+	err := os.Remove(s.Path(name))
+	removedLoose := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	removedPacked, err := s.deletePacked(name)
+	if err != nil {
+		return err
+	}
+	if !removedLoose && !removedPacked {
+		return fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+// deletePacked rewrites packed-refs without name, reporting whether it
+// was present.
+func (s *Store) deletePacked(name string) (bool, error) {
+	path := filepath.Join(s.CommonDir, "packed-refs")
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var out strings.Builder
+	found, skipping := false, false
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "^") {
+			if !skipping {
+				out.WriteString(line)
+			}
+			continue
+		}
+		_, ref, _ := strings.Cut(strings.TrimSpace(line), " ")
+		skipping = ref == name && line[0] != '#'
+		if skipping {
+			found = true
+			continue
+		}
+		out.WriteString(line)
+	}
+	if !found {
+		return false, nil
+	}
+
+	return true, writeLocked(path, out.String())
+}
+
+// CheckName rejects names git would not accept as a ref component.
+func CheckName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".lock") ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") ||
+		strings.Contains(name, "@{") || strings.ContainsAny(name, " ~^:?*[\\\t\n") {
+		return fmt.Errorf("'%s' is not a valid ref name", name)
+	}
+	return nil
+}
+
+func isFullHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
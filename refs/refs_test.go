@@ -0,0 +1,157 @@
+package refs
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// git runs git in dir with a fixed identity and returns its trimmed output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+t.TempDir(),
+		"GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=a@example.com",
+		"GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=a@example.com",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newStore makes a repository on main with a branch, a loose annotated tag
+// and a packed one, and returns its refs.
+func newStore(t *testing.T) (string, *Store) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "first")
+	git(t, dir, "tag", "-a", "-m", "packed", "packed")
+	git(t, dir, "branch", "topic")
+	git(t, dir, "pack-refs", "--all")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "second")
+	git(t, dir, "tag", "-a", "-m", "loose", "loose")
+	gitDir := filepath.Join(dir, ".git")
+	return dir, &Store{GitDir: gitDir, CommonDir: gitDir}
+}
+
+func TestListAndResolve(t *testing.T) {
+	dir, s := newStore(t)
+	names, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(names, "\n"), git(t, dir, "for-each-ref", "--format=%(refname)"); got != want {
+		t.Errorf("List:\n%s\ngit has:\n%s", got, want)
+	}
+	for _, name := range append(names, "HEAD") {
+		hash, err := s.Resolve(name)
+		if want := git(t, dir, "rev-parse", name); hash != want || err != nil {
+			t.Errorf("Resolve(%s) = %s, %v, want %s", name, hash, err, want)
+		}
+	}
+
+	packed, err := s.ReadPacked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := git(t, dir, "rev-parse", "packed^{commit}"); packed["refs/tags/packed"].Peeled != want {
+		t.Errorf("packed tag peels to %q, want %s", packed["refs/tags/packed"].Peeled, want)
+	}
+	if _, err := s.Resolve("refs/heads/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve of a missing ref: %v, want ErrNotFound", err)
+	}
+}
+
+func TestWriteAndDelete(t *testing.T) {
+	dir, s := newStore(t)
+	head := git(t, dir, "rev-parse", "HEAD")
+	if err := s.Write("refs/heads/new/branch", head); err != nil {
+		t.Fatal(err)
+	}
+	if got := git(t, dir, "rev-parse", "new/branch"); got != head {
+		t.Errorf("written ref resolves to %s in git, want %s", got, head)
+	}
+	if err := s.WriteSymbolic("HEAD", "refs/heads/topic"); err != nil {
+		t.Fatal(err)
+	}
+	if got := git(t, dir, "symbolic-ref", "HEAD"); got != "refs/heads/topic" {
+		t.Errorf("HEAD points at %s in git, want refs/heads/topic", got)
+	}
+
+	for _, name := range []string{"refs/heads/new/branch", "refs/tags/packed", "refs/heads/topic"} {
+		if err := s.Delete(name); err != nil {
+			t.Fatalf("Delete(%s): %v", name, err)
+		}
+		if ok, err := s.Exists(name); ok || err != nil {
+			t.Errorf("Exists(%s) after Delete = %v, %v", name, ok, err)
+		}
+	}
+	if got := git(t, dir, "for-each-ref", "--format=%(refname)"); got != "refs/heads/main\nrefs/tags/loose" {
+		t.Errorf("refs left in git:\n%s", got)
+	}
+	if err := s.Delete("refs/heads/topic"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of a missing ref: %v, want ErrNotFound", err)
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"main", "feature/x", "v1.0"} {
+		if err := CheckName(name); err != nil {
+			t.Errorf("CheckName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-x", "a..b", "a.lock", "a b", "a@{1}", "a/", "a:b"} {
+		if err := CheckName(name); err == nil {
+			t.Errorf("CheckName(%q) accepted a bad name", name)
+		}
+	}
+}
+
+func TestWriteLocked(t *testing.T) {
+	dir, s := newStore(t)
+	head := git(t, dir, "rev-parse", "HEAD")
+	first := git(t, dir, "rev-parse", "HEAD~1")
+	if err := s.Write("refs/heads/topic", head); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.Path("refs/heads/topic") + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Write left its lock file behind: %v", err)
+	}
+
+	// An update already holding the lock makes others fail rather than
+	// be overwritten by them.
+	for _, name := range []string{"refs/heads/topic", "HEAD"} {
+		lock := s.Path(name) + ".lock"
+		if err := os.WriteFile(lock, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		before := git(t, dir, "rev-parse", name)
+		if err := s.Write(name, first); !errors.Is(err, ErrLocked) {
+			t.Errorf("Write(%s) while locked: %v, want ErrLocked", name, err)
+		}
+		if err := s.WriteSymbolic(name, "refs/heads/main"); !errors.Is(err, ErrLocked) {
+			t.Errorf("WriteSymbolic(%s) while locked: %v, want ErrLocked", name, err)
+		}
+		if got := git(t, dir, "rev-parse", name); got != before {
+			t.Errorf("%s moved to %s while locked, want %s", name, got, before)
+		}
+		if _, err := os.Stat(lock); err != nil {
+			t.Errorf("the other update's lock was removed: %v", err)
+		}
+		os.Remove(lock)
+	}
+	if err := s.Write("refs/heads/topic", first); err != nil {
+		t.Errorf("Write after the lock went away: %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// advertisedRef is one ref a server offers. Peeled is the object an
+// annotated tag points at and Symref the ref a symbolic ref points at,
+// when the server sends them.
+type advertisedRef struct {
+	Name   string
+	Hash   string
+	Peeled string
+	Symref string
+}
+
+// refAdvertisement is a server's list of refs and its capabilities. A
+// server speaking protocol version 2 sends only capabilities, and its refs
+// are listed with ls-refs.
+type refAdvertisement struct {
+	Refs    []advertisedRef
+	Caps    []string
+	Version int
+}
+
+// has reports whether the server offered the capability name.
+func (a *refAdvertisement) has(name string) bool {
+	_, ok := a.capValue(name)
+	return ok
+}
+
+// capValue returns the value of a "name=value" capability.
+func (a *refAdvertisement) capValue(name string) (string, bool) {
+	for _, c := range a.Caps {
+		key, value, _ := strings.Cut(c, "=")
+		if key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// headTarget returns the branch the server's HEAD points at, from the
+// symref capability or ls-refs.
+func (a *refAdvertisement) headTarget() string {
+	if head, ok := a.lookup("HEAD"); ok && head.Symref != "" {
+		return head.Symref
+	}
+	for _, c := range a.Caps {
+		if target, ok := strings.CutPrefix(c, "symref=HEAD:"); ok {
+			return target
+		}
+	}
+	return ""
+}
+
+// lookup returns the advertised ref called name.
+func (a *refAdvertisement) lookup(name string) (advertisedRef, bool) {
+	for _, ref := range a.Refs {
+		if ref.Name == name {
+			return ref, true
+		}
+	}
+	return advertisedRef{}, false
+}
+
+// readRefAdvertisement reads pkt-lines of "<hash> <ref>" up to a flush. The
+// first line carries the capabilities after a NUL byte. An empty
+// repository advertises only "capabilities^{}" with the zero hash. A
+// server that takes up a request for version 2 sends "version 2" and its
+// capabilities instead; one that only knows version 1 sends "version 1"
+// before the same advertisement as version 0.
+func readRefAdvertisement(r io.Reader) (*refAdvertisement, error) {
+	adv := &refAdvertisement{}
+	line, err := readPktLine(r)
+	if err == nil {
+		switch string(bytes.TrimSuffix(line, []byte("\n"))) {
+		case "version 2":
+			adv.Version = 2
+			return adv, readCapabilities(r, adv)
+		case "version 1":
+			line, err = readPktLine(r)
+		}
+	}
+	for first := true; ; first = false {
+		if !first {
+			line, err = readPktLine(r)
+		}
+		if errors.Is(err, errFlush) {
+			return adv, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		if msg, ok := strings.CutPrefix(text, "ERR "); ok {
+			return nil, fmt.Errorf("remote error: %s", msg)
+		}
+		if first {
+			var caps string
+			text, caps, _ = strings.Cut(text, "\x00")
+			adv.Caps = strings.Fields(caps)
+		}
+		hash, name, ok := strings.Cut(text, " ")
+		if !ok || !isFullHash(hash) {
+			return nil, fmt.Errorf("bad ref advertisement line %q", text)
+		}
+		if name == "capabilities^{}" {
+			continue
+		}
+		if base, peeled := strings.CutSuffix(name, "^{}"); peeled {
+			if n := len(adv.Refs); n > 0 && adv.Refs[n-1].Name == base {
+				adv.Refs[n-1].Peeled = hash
+			}
+			continue
+		}
+		adv.Refs = append(adv.Refs, advertisedRef{Name: name, Hash: hash})
+	}
+}
+
+// readCapabilities reads a version 2 capability advertisement, one
+// capability per pkt-line up to a flush.
+func readCapabilities(r io.Reader, adv *refAdvertisement) error {
+	for {
+		line, err := readPktLine(r)
+		if errors.Is(err, errFlush) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		adv.Caps = append(adv.Caps, strings.TrimSuffix(string(line), "\n"))
+	}
+}
+
+// listRemoteRefs returns the server's refs that start with one of prefixes,
+// or all of them without prefixes. Under version 2 they are asked for with
+// ls-refs; under version 0 they are the advertised ones, with symbolic refs
+// filled in from the symref capabilities.
+func listRemoteRefs(t Transport, adv *refAdvertisement, prefixes []string) ([]advertisedRef, error) {
+	if adv.Version == 2 {
+		return lsRefs(t, adv, prefixes)
+	}
+	symrefs := make(map[string]string)
+	for _, c := range adv.Caps {
+		if value, ok := strings.CutPrefix(c, "symref="); ok {
+			name, target, _ := strings.Cut(value, ":")
+			symrefs[name] = target
+		}
+	}
+	var refs []advertisedRef
+	for _, ref := range adv.Refs {
+		matched := len(prefixes) == 0
+		for _, prefix := range prefixes {
+			matched = matched || strings.HasPrefix(ref.Name, prefix)
+		}
+		if matched {
+			ref.Symref = symrefs[ref.Name]
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// remoteURL returns the URL configured for the named remote. A name that
+// is not configured is taken to be a URL itself.
+func remoteURL(repo *Repo, name string) string {
+	if url, ok := repo.Config.Get("remote."+name, "url"); ok {
+		return url
+	}
+	return name
+}
+
+// fetchPack asks the server for the objects needed to have every commit in
+// wants, given that we already have haves, and returns the pack it sends
+// with any change to the shallow boundary that d or our shallow commits
+// led to. A filter, as parseFilter returns, asks the server to leave out
+// the blobs it matches. Server progress messages are copied to progress
+// unless it is nil.
+func fetchPack(t Transport, adv *refAdvertisement, wants, haves []string, d deepen, filter string, progress io.Writer) ([]byte, shallowUpdate, error) {
+	// A bundle has nobody to negotiate with: its pack is all there is.
+	if b, ok := t.(*bundleTransport); ok {
+		pack, err := b.b.completePack()
+		return pack, shallowUpdate{}, err
+	}
+	if adv.Version == 2 {
+		return fetchPackV2(t, adv, wants, haves, d, filter, progress)
+	}
+
+	var reqArgs bytes.Buffer
+	shallow, err := writeShallowRequest(&reqArgs, d)
+	if err != nil {
+		return nil, shallowUpdate{}, err
+	}
+
+	// The pack is always read through side-band, which every smart
+	// server offers.
+	caps := []string{"ofs-delta", "agent=got/" + gotVersion}
+	switch {
+	case adv.has("side-band-64k"):
+		caps = append(caps, "side-band-64k")
+	case adv.has("side-band"):
+		caps = append(caps, "side-band")
+	default:
+		return nil, shallowUpdate{}, errors.New("the server does not support side-band")
+	}
+	if adv.has("include-tag") {
+		caps = append(caps, "include-tag")
+	}
+	if progress == nil && adv.has("no-progress") {
+		caps = append(caps, "no-progress")
+	}
+	if shallow {
+		if !adv.has("shallow") {
+			return nil, shallowUpdate{}, errors.New("the server does not support shallow clients")
+		}
+		caps = append(caps, "shallow")
+	}
+	if d.relative {
+		if !adv.has("deepen-relative") {
+			return nil, shallowUpdate{}, errors.New("the server does not support --deepen")
+		}
+		caps = append(caps, "deepen-relative")
+	}
+	if filter != "" {
+		if adv.has("filter") {
+			caps = append(caps, "filter")
+			writePktLine(&reqArgs, "filter "+filter+"\n")
+		} else {
+			fmt.Fprintln(os.Stderr, "warning: filtering not recognized by server, ignoring")
+		}
+	}
+
+	var req bytes.Buffer
+	for i, want := range wants {
+		line := "want " + want
+		if i == 0 {
+			line += " " + strings.Join(caps, " ")
+		}
+		writePktLine(&req, line+"\n")
+	}
+	req.Write(reqArgs.Bytes())
+	writeFlush(&req)
+	for _, have := range haves {
+		writePktLine(&req, "have "+have+"\n")
+	}
+	writePktLine(&req, "done\n")
+
+	body, err := t.call("git-upload-pack", req.Bytes())
+	if err != nil {
+		return nil, shallowUpdate{}, err
+	}
+	defer body.Close()
+
+	// Told about shallow commits, the server first answers with the new
+	// boundary, up to a flush.
+	var update shallowUpdate
+	for shallow {
+		line, err := readPktLine(body)
+		if errors.Is(err, errFlush) {
+			break
+		}
+		if err != nil {
+			return nil, shallowUpdate{}, fmt.Errorf("reading upload-pack response: %w", err)
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		if msg, ok := strings.CutPrefix(text, "ERR "); ok {
+			return nil, shallowUpdate{}, fmt.Errorf("remote error: %s", msg)
+		}
+		if ok, err := update.parseLine(text); err != nil || !ok {
+			return nil, shallowUpdate{}, fmt.Errorf("expected shallow list, got %q", text)
+		}
+	}
+
+	// The server acknowledges what it found in common, then sends the pack.
+	var first []byte
+	for {
+		line, err := readPktLine(body)
+		if err != nil {
+			return nil, shallowUpdate{}, fmt.Errorf("reading upload-pack response: %w", err)
+		}
+		if bytes.HasPrefix(line, []byte("ERR ")) {
+			return nil, shallowUpdate{}, fmt.Errorf("remote error: %s", strings.TrimSpace(string(line[4:])))
+		}
+		if !bytes.HasPrefix(line, []byte("ACK ")) && !bytes.HasPrefix(line, []byte("NAK")) {
+			first = line
+			break
+		}
+	}
+
+	pack, err := readSideband(body, first, progress)
+	return pack, update, err
+}
+
+// readSideband demultiplexes side-band packets, starting with first, up to
+// a flush: band 1 is pack data, band 2 progress and band 3 a fatal error.
+func readSideband(r io.Reader, first []byte, progress io.Writer) ([]byte, error) {
+	var pack bytes.Buffer
+	line := first
+	for {
+		if len(line) > 0 {
+			switch line[0] {
+			case 1:
+				pack.Write(line[1:])
+			case 2:
+				if progress != nil {
+					progress.Write(line[1:])
+				}
+			case 3:
+				return nil, fmt.Errorf("remote error: %s", strings.TrimSpace(string(line[1:])))
+			default:
+				return nil, fmt.Errorf("bad side-band channel %d", line[0])
+			}
+		}
+		var err error
+		line, err = readPktLine(r)
+		if errors.Is(err, errFlush) {
+			return pack.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// progressWriter returns where server progress should go: stderr, or nowhere
+// when quiet.
+func progressWriter(quiet bool) io.Writer {
+	if quiet {
+		return nil
+	}
+	return os.Stderr
+}
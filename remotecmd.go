@@ -0,0 +1,510 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func runRemote(args []string) {
+	usage := errors.New("usage: got remote [-v]\n   or: got remote add [-f] <name> <url>\n   or: got remote remove <name>\n   or: got remote rename <old> <new>\n   or: got remote set-url [--push] <name> <newurl>\n   or: got remote show [-n] <name>")
+	if len(args) == 0 || args[0] == "-v" || args[0] == "--verbose" {
+		if len(args) > 1 {
+			handleError(usage)
+		}
+		listRemotes(len(args) == 1)
+		return
+	}
+
+	sub, args := args[0], args[1:]
+	var flags, positional []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	var err error
+	switch {
+	case sub == "add" && len(positional) == 2 && (len(flags) == 0 || slices.Equal(flags, []string{"-f"})):
+		err = addRemote(positional[0], positional[1], len(flags) == 1)
+	case (sub == "remove" || sub == "rm") && len(positional) == 1 && len(flags) == 0:
+		err = removeRemote(positional[0])
+	case sub == "rename" && len(positional) == 2 && len(flags) == 0:
+		err = renameRemote(positional[0], positional[1])
+	case sub == "set-url" && len(positional) == 2 && (len(flags) == 0 || slices.Equal(flags, []string{"--push"})):
+		err = setRemoteURL(positional[0], positional[1], len(flags) == 1)
+	case sub == "show" && len(positional) == 1 && (len(flags) == 0 || slices.Equal(flags, []string{"-n"})):
+		err = showRemote(positional[0], len(flags) == 0)
+	default:
+		err = usage
+	}
+	if err != nil {
+		handleError(err)
+	}
+}
+
+// remoteNames returns the remotes configured in config, in the order they
+// first appear.
+func remoteNames(config *Config) []string {
+	var names []string
+	for _, entry := range config.entries {
+		rest, ok := strings.CutPrefix(entry.Key, "remote.")
+		i := strings.LastIndexByte(rest, '.')
+		if !ok || i <= 0 {
+			continue
+		}
+		if name := rest[:i]; !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// remotePushURL returns the URL the named remote is pushed to: its pushurl
+// if it has one, otherwise the URL it is fetched from.
+func remotePushURL(config *Config, name string) string {
+	if url, ok := config.Get("remote."+name, "pushurl"); ok {
+		return url
+	}
+	if url, ok := config.Get("remote."+name, "url"); ok {
+		return url
+	}
+	return name
+}
+
+// remoteConfig reads the repository's config and checks that the named
+// remote is in it. The config is read again rather than taken from
+// currentRepo, as the remote commands change it.
+func remoteConfig(name string) (*Config, error) {
+	config, err := readRepoConfig(commonPath("config"))
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(remoteNames(config), name) {
+		return nil, fmt.Errorf("no such remote: '%s'", name)
+	}
+	return config, nil
+}
+
+// checkRemoteName rejects names that cannot be a remote, as their
+// remote-tracking refs would not be valid.
+func checkRemoteName(name string) error {
+	if checkRefName(name) != nil || strings.Contains(name, "/") {
+		return fmt.Errorf("'%s' is not a valid remote name", name)
+	}
+	return nil
+}
+
+func listRemotes(verbose bool) {
+	config, err := readRepoConfig(commonPath("config"))
+	if err != nil {
+		handleError(err)
+	}
+	for _, name := range remoteNames(config) {
+		if !verbose {
+			fmt.Println(name)
+			continue
+		}
+		url, _ := config.Get("remote."+name, "url")
+		fmt.Printf("%s\t%s (fetch)\n", name, url)
+		fmt.Printf("%s\t%s (push)\n", name, remotePushURL(config, name))
+	}
+}
+
+// addRemote configures a remote whose branches are fetched into
+// refs/remotes/<name>/, fetching them straight away if fetch is set.
+func addRemote(name, url string, fetch bool) error {
+	if err := checkRemoteName(name); err != nil {
+		return err
+	}
+	if _, err := remoteConfig(name); err == nil {
+		return fmt.Errorf("remote %s already exists", name)
+	}
+	spec := "+refs/heads/*:refs/remotes/" + name + "/*"
+	for _, kv := range [][2]string{{"remote." + name + ".url", url}, {"remote." + name + ".fetch", spec}} {
+		if err := setConfigValue(commonPath("config"), kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	if !fetch {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Updating %s\n", name)
+	remote, err := openRemote(url)
+	if err != nil {
+		return err
+	}
+	rejected, err := fetchRemote(remote, url, []string{spec}, deepen{}, "", false, false, "fetch "+name)
+	if err == nil && rejected {
+		err = fmt.Errorf("could not fetch %s", name)
+	}
+	return err
+}
+
+// removeRemote deletes the named remote's config, its remote-tracking
+// refs and the upstream settings of branches that track it.
+func removeRemote(name string) error {
+	config, err := remoteConfig(name)
+	if err != nil {
+		return err
+	}
+	refs, err := trackingRefs(config, name)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := deleteRef(ref); err != nil && !errors.Is(err, errRefNotFound) {
+			return err
+		}
+	}
+	removeEmptyDirs(name)
+
+	for _, branch := range trackingBranches(config, name) {
+		for _, key := range []string{"remote", "merge"} {
+			if _, ok := config.Get("branch."+branch, key); !ok {
+				continue
+			}
+			if err := setConfigValue(commonPath("config"), "branch."+branch+"."+key, "", true); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = renameConfigSection(commonPath("config"), "remote."+name, "")
+	return err
+}
+
+// renameRemote renames a remote's config, moves its remote-tracking refs
+// along with their reflogs, and points branches that track it at the new
+// name. Fetch refspecs are rewritten when they store into
+// refs/remotes/<old>/.
+func renameRemote(oldName, newName string) error {
+	config, err := remoteConfig(oldName)
+	if err != nil {
+		return err
+	}
+	if err := checkRemoteName(newName); err != nil {
+		return err
+	}
+	if slices.Contains(remoteNames(config), newName) {
+		return fmt.Errorf("remote %s already exists", newName)
+	}
+	refs, err := trackingRefs(config, oldName)
+	if err != nil {
+		return err
+	}
+
+	path := commonPath("config")
+	if _, err := renameConfigSection(path, "remote."+oldName, "remote."+newName); err != nil {
+		return err
+	}
+	oldPrefix, newPrefix := "refs/remotes/"+oldName+"/", "refs/remotes/"+newName+"/"
+	specs := config.values[configKey("remote."+oldName, "fetch")]
+	if len(specs) > 0 {
+		if err := setConfigValue(path, "remote."+newName+".fetch", "", true); err != nil {
+			return err
+		}
+		for _, spec := range specs {
+			if src, dst, ok := strings.Cut(spec, ":"); ok {
+				if rest, ok := strings.CutPrefix(dst, oldPrefix); ok {
+					spec = src + ":" + newPrefix + rest
+				}
+			}
+			if err := addConfigValue(path, "remote."+newName+".fetch", spec); err != nil {
+				return err
+			}
+		}
+	}
+	for _, branch := range trackingBranches(config, oldName) {
+		if err := setConfigValue(path, "branch."+branch+".remote", newName, false); err != nil {
+			return err
+		}
+	}
+	if promisor, _ := config.Get("extensions", "partialClone"); promisor == oldName {
+		if err := setConfigValue(path, "extensions.partialClone", newName, false); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range refs {
+		rest, ok := strings.CutPrefix(ref, oldPrefix)
+		if !ok {
+			continue
+		}
+		if err := moveRef(ref, newPrefix+rest, oldPrefix, newPrefix); err != nil {
+			return err
+		}
+	}
+	removeEmptyDirs(oldName)
+	return nil
+}
+
+// moveRef renames the ref from to to, keeping its reflog. A symbolic ref
+// pointing under oldPrefix is pointed under newPrefix instead.
+func moveRef(from, to, oldPrefix, newPrefix string) error {
+	value, err := readRefFile(from)
+	if errors.Is(err, errRefNotFound) {
+		value, err = resolvePackedRef(from)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(refPath(to)), 0755); err != nil {
+		return err
+	}
+	if target, ok := strings.CutPrefix(value, "ref: "); ok {
+		if rest, ok := strings.CutPrefix(target, oldPrefix); ok {
+			target = newPrefix + rest
+		}
+		err = writeSymbolicRef(to, target)
+	} else {
+		err = writeRef(to, value)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(reflogPath(from)); err == nil {
+		if err := os.MkdirAll(filepath.Dir(reflogPath(to)), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(reflogPath(from), reflogPath(to)); err != nil {
+			return err
+		}
+	}
+	return deleteRef(from)
+}
+
+// removeEmptyDirs removes the directories left empty under refs/remotes/
+// and its reflogs once a remote's refs are gone. Directories still in use
+// are left alone, as Remove fails on them.
+func removeEmptyDirs(name string) {
+	for _, dir := range []string{refPath("refs/remotes/" + name), filepath.Dir(reflogPath("refs/remotes/" + name + "/x"))} {
+		var dirs []string
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+		for i := len(dirs) - 1; i >= 0; i-- {
+			os.Remove(dirs[i])
+		}
+	}
+}
+
+// trackingRefs lists the local refs the named remote's fetch refspecs store
+// into.
+func trackingRefs(config *Config, name string) ([]string, error) {
+	refs, err := listRefs()
+	if err != nil {
+		return nil, err
+	}
+	specs := config.values[configKey("remote."+name, "fetch")]
+	var tracking []string
+	for _, ref := range refs {
+		for _, spec := range specs {
+			src, dst, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+			if _, ok := mapRefspec(dst+":"+src, ref); ok {
+				tracking = append(tracking, ref)
+				break
+			}
+		}
+	}
+	return tracking, nil
+}
+
+// trackingBranches returns the local branches whose upstream is on the
+// named remote.
+func trackingBranches(config *Config, name string) []string {
+	var branches []string
+	for _, entry := range config.entries {
+		rest, ok := strings.CutPrefix(entry.Key, "branch.")
+		branch, isRemote := strings.CutSuffix(rest, ".remote")
+		if ok && isRemote && entry.Value == name && !slices.Contains(branches, branch) {
+			branches = append(branches, branch)
+		}
+	}
+	return branches
+}
+
+// setRemoteURL changes the URL the named remote is fetched from, or with
+// push the one it is pushed to.
+func setRemoteURL(name, url string, push bool) error {
+	if _, err := remoteConfig(name); err != nil {
+		return err
+	}
+	key := "url"
+	if push {
+		key = "pushurl"
+	}
+	return setConfigValue(commonPath("config"), "remote."+name+"."+key, url, false)
+}
+
+// showRemote describes the named remote: its URLs, its branches and how
+// local branches relate to them. Unless query is false, the remote is
+// asked for its branches and HEAD; otherwise only the remote-tracking refs
+// are listed.
+func showRemote(name string, query bool) error {
+	config, err := remoteConfig(name)
+	if err != nil {
+		return err
+	}
+	url, _ := config.Get("remote."+name, "url")
+	fmt.Printf("* remote %s\n", name)
+	fmt.Printf("  Fetch URL: %s\n", url)
+	fmt.Printf("  Push  URL: %s\n", remotePushURL(config, name))
+	specs := config.values[configKey("remote."+name, "fetch")]
+
+	if !query {
+		fmt.Println("  HEAD branch: (not queried)")
+		refs, err := trackingRefs(config, name)
+		if err != nil {
+			return err
+		}
+		var branches []string
+		for _, ref := range refs {
+			if !strings.HasSuffix(ref, "/HEAD") {
+				branches = append(branches, shortRefName(ref))
+			}
+		}
+		if len(branches) > 0 {
+			fmt.Printf("  %s: (status not queried)\n", plural(len(branches), "Remote branch", "Remote branches"))
+			for _, branch := range branches {
+				fmt.Printf("    %s\n", branch)
+			}
+		}
+		showMergeBranches(config, name)
+		return nil
+	}
+
+	remote, err := openRemote(url)
+	if err != nil {
+		return err
+	}
+	defer remote.close()
+	adv, err := remote.discoverRefs("git-upload-pack", protocolVersion())
+	if err != nil {
+		return err
+	}
+	if adv.Refs, err = listRemoteRefs(remote, adv, []string{"HEAD", "refs/heads/"}); err != nil {
+		return err
+	}
+	head := defaultBranch(adv)
+	if head == "" {
+		head = "(unknown)"
+	}
+	fmt.Printf("  HEAD branch: %s\n", head)
+
+	// Each remote branch is tracked, about to be, or not fetched at all;
+	// tracking refs without a branch are stale.
+	type remoteBranch struct{ name, status string }
+	var branches []remoteBranch
+	stored := make(map[string]bool)
+	for _, ref := range adv.Refs {
+		if !strings.HasPrefix(ref.Name, "refs/heads/") {
+			continue
+		}
+		status := "skipped"
+		for _, spec := range specs {
+			if local, ok := mapRefspec(spec, ref.Name); ok {
+				stored[local] = true
+				status = "tracked"
+				if exists, _ := refExists(local); !exists {
+					status = fmt.Sprintf("new (next fetch will store in %s)", strings.TrimPrefix(local, "refs/"))
+				}
+				break
+			}
+		}
+		branches = append(branches, remoteBranch{strings.TrimPrefix(ref.Name, "refs/heads/"), status})
+	}
+	refs, err := trackingRefs(config, name)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if !stored[ref] && !strings.HasSuffix(ref, "/HEAD") {
+			branches = append(branches, remoteBranch{shortRefName(ref), "stale (use 'got fetch --prune' to remove)"})
+		}
+	}
+	if len(branches) > 0 {
+		width := 0
+		for _, b := range branches {
+			width = max(width, len(b.name))
+		}
+		fmt.Printf("  %s:\n", plural(len(branches), "Remote branch", "Remote branches"))
+		for _, b := range branches {
+			fmt.Printf("    %-*s %s\n", width, b.name, b.status)
+		}
+	}
+	showMergeBranches(config, name)
+
+	// Local branches are pushed to the remote branch of the same name.
+	type pushRef struct{ name, status string }
+	var pushes []pushRef
+	local, err := listRefs()
+	if err != nil {
+		return err
+	}
+	for _, ref := range local {
+		branch, ok := strings.CutPrefix(ref, "refs/heads/")
+		if !ok {
+			continue
+		}
+		theirs, ok := adv.lookup(ref)
+		if !ok {
+			continue
+		}
+		ours, err := resolveRef(ref)
+		if err != nil {
+			return err
+		}
+		status := "local out of date"
+		if ours == theirs.Hash {
+			status = "up to date"
+		} else if reachable, err := ancestors(ours); err == nil && reachable[theirs.Hash] {
+			status = "fast-forwardable"
+		}
+		pushes = append(pushes, pushRef{branch, status})
+	}
+	if len(pushes) > 0 {
+		width := 0
+		for _, p := range pushes {
+			width = max(width, len(p.name))
+		}
+		fmt.Printf("  %s:\n", plural(len(pushes), "Local ref configured for push", "Local refs configured for push"))
+		for _, p := range pushes {
+			fmt.Printf("    %-*s pushes to %-*s (%s)\n", width, p.name, width, p.name, p.status)
+		}
+	}
+	return nil
+}
+
+// showMergeBranches lists the local branches that merge from the named
+// remote.
+func showMergeBranches(config *Config, name string) {
+	branches := trackingBranches(config, name)
+	if len(branches) == 0 {
+		return
+	}
+	width := 0
+	for _, branch := range branches {
+		width = max(width, len(branch))
+	}
+	fmt.Printf("  %s:\n", plural(len(branches), "Local branch tracking it", "Local branches tracking it"))
+	for _, branch := range branches {
+		merge, _ := config.Get("branch."+branch, "merge")
+		fmt.Printf("    %-*s merges with remote %s\n", width, branch, strings.TrimPrefix(merge, "refs/heads/"))
+	}
+}
+
+// plural picks one or many by n.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
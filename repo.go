@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piyushyadav1617/got/repo"
+)
+
+// Repo is the opened repository with the configuration read from it. The
+// object store underneath is the repo package's; Repo puts an object cache
+// in front of it and, in a partial clone, fetches what it lacks.
+type Repo struct {
+	*repo.Repository
+	Config *Config
+
+	objects *objectCache
+}
+
+// openRepo opens the repository whose git directory is gitDir.
+func openRepo(gitDir string) (*Repo, error) {
+	config, err := readRepoConfig(commonPath("config"))
+	if err != nil {
+		return nil, err
+	}
+	cacheSize, err := config.Size("got", "objectCacheSize", defaultObjectCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("GOT_OBJECT_CACHE_SIZE"); value != "" {
+		if cacheSize, err = parseSize(value); err != nil {
+			return nil, fmt.Errorf("bad numeric value '%s' for GOT_OBJECT_CACHE_SIZE", value)
+		}
+	}
+	r, err := repo.OpenWith(gitDir, repo.Options{
+		MissingAlternate: func(dir, alternates string) {
+			fmt.Fprintf(os.Stderr, "warning: object directory %s does not exist; check %s\n", dir, alternates)
+		},
+		Bases: objectStore{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.VerifyCRC = config.Bool("got", "verifyPackCrc", false)
+	return &Repo{Repository: r, Config: config, objects: newObjectCache(cacheSize)}, nil
+}
+
+// currentRepo returns the repository in the current directory, opened once
+// per process.
+var currentRepo = sync.OnceValues(func() (*Repo, error) {
+	return openRepo(gitDir)
+})
+
+// identity returns the "Name <email> timestamp tz" line used for the given
+// role ("author" or "committer"). GIT_AUTHOR_NAME style environment
+// variables take precedence over user.name and user.email, and
+// GIT_AUTHOR_DATE style ones over now.
+func (r *Repo) identity(role string, now time.Time) (string, error) {
+	envPrefix := "GIT_AUTHOR_"
+	if role == "committer" {
+		envPrefix = "GIT_COMMITTER_"
+	}
+
+	name := os.Getenv(envPrefix + "NAME")
+	if name == "" {
+		name, _ = r.Config.Get("user", "name")
+	}
+	email := os.Getenv(envPrefix + "EMAIL")
+	if email == "" {
+		email, _ = r.Config.Get("user", "email")
+	}
+	if name == "" || email == "" {
+		return "", errors.New(`identity unknown: run "got config user.name <name>" and "got config user.email <email>"`)
+	}
+
+	if date := os.Getenv(envPrefix + "DATE"); date != "" {
+		t, err := parseGitDate(date)
+		if err != nil {
+			return "", err
+		}
+		now = t
+	}
+	return fmt.Sprintf("%s <%s> %s", name, email, formatGitTimestamp(now)), nil
+}
+
+// gitDateLayouts are the RFC 2822 and ISO 8601 forms parseGitDate accepts.
+// Layouts without a zone are read in local time.
+var gitDateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseGitDate parses a date given in GIT_AUTHOR_DATE or
+// GIT_COMMITTER_DATE: git's internal "<unix timestamp> <+hhmm>", optionally
+// with a leading "@", or one of gitDateLayouts. The zone it names is kept.
+func parseGitDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if seconds, tz, ok := strings.Cut(strings.TrimPrefix(value, "@"), " "); ok {
+		if n, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+			if zone, err := time.Parse("-0700", tz); err == nil {
+				_, offset := zone.Zone()
+				return time.Unix(n, 0).In(time.FixedZone("", offset)), nil
+			}
+		}
+	}
+	for _, layout := range gitDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date format: %s", value)
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxAlternateDepth bounds chains of alternates, as git does.
+const maxAlternateDepth = 5
+
+// ObjectDirs returns objectDir followed by every directory borrowed
+// through objects/info/alternates, recursively. An alternate that does
+// not exist is skipped, after calling missing, if set, with it and the
+// alternates file that lists it.
+func ObjectDirs(objectDir string, missing func(dir, alternates string)) ([]string, error) {
+	dirs := []string{objectDir}
+	seen := map[string]bool{}
+	if abs, err := filepath.Abs(objectDir); err == nil {
+		seen[abs] = true
+	}
+	if err := readAlternates(objectDir, 0, seen, &dirs, missing); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+func readAlternates(objectDir string, depth int, seen map[string]bool, dirs *[]string, missing func(dir, alternates string)) error {
+	alternates := filepath.Join(objectDir, "info", "alternates")
+	f, err := os.Open(alternates)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if depth >= maxAlternateDepth {
+		return fmt.Errorf("%s: alternates nested too deeply", objectDir)
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Relative entries are relative to the object directory that
+		// lists them, not to the working directory.
+		dir := filepath.FromSlash(line)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(objectDir, dir)
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			if missing != nil {
+				missing(dir, alternates)
+			}
+			continue
+		}
+		*dirs = append(*dirs, dir)
+		if err := readAlternates(dir, depth+1, seen, dirs, missing); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
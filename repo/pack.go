@@ -0,0 +1,300 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// Objects looks up objects outside a pack, for the bases of its
+// ref-deltas.
+type Objects interface {
+	ReadObject(hash string) (string, []byte, error)
+	ObjectHeader(hash string) (string, int64, error)
+}
+
+// Pack is a pack and its version 2 index.
+type Pack struct {
+	path    string
+	file    *os.File
+	size    int64
+	hashes  []byte // 20 bytes per object, sorted
+	crcs    []uint32
+	offsets []uint64
+	fanout  [256]uint32
+	bases   Objects
+
+	// ends maps each entry's offset to the offset of the following entry,
+	// built on first use for CRC checks.
+	endsOnce sync.Once
+	ends     map[uint64]uint64
+}
+
+// OpenPack opens the pack at packPath and parses its .idx file. Ref-delta
+// bases that are not in the pack are read from bases.
+func OpenPack(packPath string, bases Objects) (*Pack, error) {
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4+40 || !bytes.Equal(idx[:4], []byte("\377tOc")) {
+		return nil, fmt.Errorf("%s: unsupported pack index format", idxPath)
+	}
+	if version := binary.BigEndian.Uint32(idx[4:8]); version != 2 {
+		return nil, fmt.Errorf("%s: unsupported pack index version %d", idxPath, version)
+	}
+
+	p := &Pack{path: packPath, bases: bases}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	n := int(p.fanout[255])
+
+	pos := 8 + 256*4
+	if len(idx) < pos+n*(20+4+4)+40 {
+		return nil, fmt.Errorf("%s: truncated pack index", idxPath)
+	}
+	p.hashes = idx[pos : pos+n*20]
+	pos += n * 20
+
+	p.crcs = make([]uint32, n)
+	for i := range p.crcs {
+		p.crcs[i] = binary.BigEndian.Uint32(idx[pos+i*4:])
+	}
+	pos += n * 4
+
+	largeOffsets := pos + n*4
+	p.offsets = make([]uint64, n)
+	for i := range p.offsets {
+		offset := binary.BigEndian.Uint32(idx[pos+i*4:])
+		if offset&0x80000000 == 0 {
+			p.offsets[i] = uint64(offset)
+			continue
+		}
+		large := largeOffsets + int(offset&0x7fffffff)*8
+		if large+8 > len(idx)-40 {
+			return nil, fmt.Errorf("%s: bad large offset", idxPath)
+		}
+		p.offsets[i] = binary.BigEndian.Uint64(idx[large:])
+	}
+
+	p.file, err = os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := p.file.Stat()
+	if err != nil {
+		p.file.Close()
+		return nil, err
+	}
+	p.size = info.Size()
+	return p, nil
+}
+
+// Path returns the path of the .pack file.
+func (p *Pack) Path() string {
+	return p.path
+}
+
+// Close closes the pack file.
+func (p *Pack) Close() error {
+	return p.file.Close()
+}
+
+// Count returns the number of objects in the pack.
+func (p *Pack) Count() int {
+	return len(p.offsets)
+}
+
+// HashAt returns the hash of object i, in index order.
+func (p *Pack) HashAt(i int) string {
+	return hex.EncodeToString(p.hashes[i*20 : i*20+20])
+}
+
+// Offset returns where object i's entry starts in the pack.
+func (p *Pack) Offset(i int) uint64 {
+	return p.offsets[i]
+}
+
+// Find returns the index position of the object with the given hash.
+func (p *Pack) Find(hash string) (int, bool) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != 20 {
+		return 0, false
+	}
+	lo, hi := p.fanoutRange(raw[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*20:(lo+i)*20+20], raw) >= 0
+	})
+	if i < hi && bytes.Equal(p.hashes[i*20:i*20+20], raw) {
+		return i, true
+	}
+	return 0, false
+}
+
+// HashesWithPrefix returns the hashes in the pack that start with the hex
+// prefix, which must be at least two digits long.
+func (p *Pack) HashesWithPrefix(prefix string) []string {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return nil
+	}
+	var found []string
+	lo, hi := p.fanoutRange(byte(first))
+	for i := lo; i < hi; i++ {
+		if hash := p.HashAt(i); strings.HasPrefix(hash, prefix) {
+			found = append(found, hash)
+		}
+	}
+	return found
+}
+
+// fanoutRange returns the index positions of the hashes starting with b.
+func (p *Pack) fanoutRange(b byte) (int, int) {
+	lo := 0
+	if b > 0 {
+		lo = int(p.fanout[b-1])
+	}
+	return lo, int(p.fanout[b])
+}
+
+// EntryEnd returns the offset just past the entry starting at offset.
+func (p *Pack) EntryEnd(offset uint64) uint64 {
+	p.endsOnce.Do(func() {
+		sorted := make([]uint64, len(p.offsets))
+		copy(sorted, p.offsets)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		p.ends = make(map[uint64]uint64, len(sorted))
+		for i, off := range sorted {
+			end := uint64(p.size - 20)
+			if i+1 < len(sorted) {
+				end = sorted[i+1]
+			}
+			p.ends[off] = end
+		}
+	})
+	return p.ends[offset]
+}
+
+// CheckCRC compares the CRC32 of the raw entry bytes for object i with the
+// value recorded in the index.
+func (p *Pack) CheckCRC(i int) error {
+	offset := p.offsets[i]
+	raw := make([]byte, p.EntryEnd(offset)-offset)
+	if _, err := p.file.ReadAt(raw, int64(offset)); err != nil {
+		return err
+	}
+	if crc := crc32.ChecksumIEEE(raw); crc != p.crcs[i] {
+		return fmt.Errorf("%s: CRC mismatch for object %s at offset %d", p.path, p.HashAt(i), offset)
+	}
+	return nil
+}
+
+// Read reads object i from the pack, resolving deltas.
+func (p *Pack) Read(i int) (string, []byte, error) {
+	return p.ReadAt(p.offsets[i])
+}
+
+// Header returns the type and size of object i without inflating more
+// than the start of a delta.
+func (p *Pack) Header(i int) (string, int64, error) {
+	return p.headerAt(p.offsets[i])
+}
+
+// readEntryHeader decodes the entry header at offset and leaves the
+// returned reader positioned at the start of the zlib stream.
+func (p *Pack) readEntryHeader(offset uint64) (*bufio.Reader, object.PackEntryHeader, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.file, int64(offset), p.size-int64(offset)))
+	h, err := object.ReadPackEntryHeader(r, offset)
+	if err != nil {
+		return nil, h, fmt.Errorf("%s: %w", p.path, err)
+	}
+	return r, h, nil
+}
+
+func (p *Pack) headerAt(offset uint64) (string, int64, error) {
+	r, h, err := p.readEntryHeader(offset)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var baseType string
+	switch h.Type {
+	case object.PackOfsDelta:
+		baseType, _, err = p.headerAt(h.BaseOffset)
+	case object.PackRefDelta:
+		baseType, _, err = p.bases.ObjectHeader(h.BaseHash)
+	default:
+		return object.PackTypeNames[h.Type], int64(h.Size), nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", 0, err
+	}
+	defer zr.Close()
+	_, size, err := object.DeltaSizes(bufio.NewReader(zr))
+	if err != nil {
+		return "", 0, err
+	}
+	return baseType, int64(size), nil
+}
+
+// ReadAt reads the entry starting at offset, resolving deltas.
+func (p *Pack) ReadAt(offset uint64) (string, []byte, error) {
+	r, h, err := p.readEntryHeader(offset)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var baseType string
+	var base []byte
+	switch h.Type {
+	case object.PackOfsDelta:
+		baseType, base, err = p.ReadAt(h.BaseOffset)
+	case object.PackRefDelta:
+		baseType, base, err = p.bases.ReadObject(h.BaseHash)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, h.Size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return "", nil, fmt.Errorf("%s: corrupt entry at offset %d: %w", p.path, offset, err)
+	}
+	// Reading past the end makes zlib verify the stream checksum.
+	if n, err := zr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		return "", nil, fmt.Errorf("%s: corrupt entry at offset %d: size mismatch or bad checksum", p.path, offset)
+	}
+
+	if h.Type == object.PackOfsDelta || h.Type == object.PackRefDelta {
+		data, err = object.ApplyDelta(base, data)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: entry at offset %d: %w", p.path, offset, err)
+		}
+		return baseType, data, nil
+	}
+	return object.PackTypeNames[h.Type], data, nil
+}
@@ -0,0 +1,409 @@
+// Package repo reads and writes the objects of a git repository on disk:
+// loose objects and packs, in its object directory and any alternates. It
+// is got's object store, for other programs to embed.
+package repo
+
+import (
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/piyushyadav1617/got/object"
+	"github.com/piyushyadav1617/got/refs"
+)
+
+// ErrNotFound is wrapped by the errors for objects the repository does not
+// have.
+var ErrNotFound = errors.New("not found")
+
+// Repository is an opened repository. Its methods may be called from
+// several goroutines.
+type Repository struct {
+	// GitDir is the git directory and CommonDir the one holding objects,
+	// refs and config. They differ only in a linked work tree.
+	GitDir    string
+	CommonDir string
+	// ObjectDirs lists the object directory first, then any alternates.
+	ObjectDirs []string
+	// Refs are the repository's branches, tags and HEAD.
+	Refs *refs.Store
+	// VerifyCRC makes every read from a pack check the CRC32 its index
+	// records for the entry first.
+	VerifyCRC bool
+
+	bases Objects
+
+	packsMu     sync.Mutex
+	packs       []*Pack
+	packsLoaded bool
+}
+
+// Options change how OpenWith opens a repository. The zero value gives
+// what Open does.
+type Options struct {
+	// MissingAlternate, if set, is called for each alternate object
+	// directory that does not exist, with the alternates file listing it.
+	MissingAlternate func(dir, alternates string)
+	// Bases, if set, is where packs look up the ref-delta bases they do
+	// not hold, in place of the repository itself: a program can put a
+	// cache, or a way to fetch missing objects, in front of it.
+	Bases Objects
+}
+
+// Open opens the repository at path, which is either a work tree holding a
+// ".git" directory or file, or a git directory itself.
+func Open(path string) (*Repository, error) {
+	return OpenWith(path, Options{})
+}
+
+// OpenWith is Open with options.
+func OpenWith(path string, opts Options) (*Repository, error) {
+	gitDir, err := ResolveGitDir(filepath.Join(path, ".git"))
+	if err != nil {
+		if !IsGitDir(path) && !isLinkedGitDir(path) {
+			return nil, fmt.Errorf("not a git repository: %s", path)
+		}
+		if gitDir, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+	}
+	commonDir := gitDir
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	dirs, err := ObjectDirs(filepath.Join(commonDir, "objects"), opts.MissingAlternate)
+	if err != nil {
+		return nil, err
+	}
+	r := &Repository{
+		GitDir:     gitDir,
+		CommonDir:  commonDir,
+		ObjectDirs: dirs,
+		Refs:       &refs.Store{GitDir: gitDir, CommonDir: commonDir},
+		bases:      opts.Bases,
+	}
+	if r.bases == nil {
+		r.bases = r
+	}
+	return r, nil
+}
+
+// ResolveGitDir returns the git directory at p: p itself if it is one, or
+// the directory a "gitdir: <path>" file at p points to, as linked work
+// trees and submodules have.
+func ResolveGitDir(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir: ")
+		if !ok {
+			return "", fmt.Errorf("%s: invalid gitfile format", p)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(p), target)
+		}
+		p = filepath.Clean(target)
+	}
+	if _, err := os.Stat(filepath.Join(p, "HEAD")); err != nil {
+		return "", errors.New("not a git repository: " + p)
+	}
+	return p, nil
+}
+
+// IsGitDir reports whether dir is laid out as a git directory.
+func IsGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isLinkedGitDir reports whether dir is the git directory of a linked work
+// tree, which has its own HEAD but shares objects and refs with the main
+// one through its commondir file.
+func isLinkedGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "commondir"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes the repository's packs.
+func (r *Repository) Close() error {
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	var errs []error
+	for _, p := range r.packs {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Packs returns every pack in the object directories, opened on first use.
+func (r *Repository) Packs() ([]*Pack, error) {
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	if r.packsLoaded {
+		return r.packs, nil
+	}
+	open := make(map[string]*Pack, len(r.packs))
+	for _, p := range r.packs {
+		open[p.Path()] = p
+	}
+	var packs []*Pack
+	for _, dir := range r.ObjectDirs {
+		idxPaths, err := filepath.Glob(filepath.Join(dir, "pack", "pack-*.idx"))
+		if err != nil {
+			return nil, err
+		}
+		for _, idxPath := range idxPaths {
+			packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+			p, ok := open[packPath]
+			if !ok {
+				if p, err = OpenPack(packPath, r.bases); err != nil {
+					return nil, err
+				}
+			}
+			packs = append(packs, p)
+		}
+	}
+	r.packs, r.packsLoaded = packs, true
+	return packs, nil
+}
+
+// ReloadPacks makes the next lookup see packs added since the last one.
+// Packs that were removed stay open, as callers may still be reading them.
+func (r *Repository) ReloadPacks() {
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	r.packsLoaded = false
+}
+
+// OpenLoose opens the loose object for hash in the first object directory
+// that has it, for callers that inflate it themselves, and returns its
+// path. The error wraps os.ErrNotExist if none does.
+func (r *Repository) OpenLoose(hash string) (*os.File, string, error) {
+	if !isFullHash(hash) {
+		return nil, "", fmt.Errorf("invalid object name '%s'", hash)
+	}
+	for _, dir := range r.ObjectDirs {
+		path := filepath.Join(dir, hash[:2], hash[2:])
+		f, err := os.Open(path)
+		if err == nil {
+			return f, path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, "", err
+		}
+	}
+	return nil, "", fmt.Errorf("object %s: %w", hash, os.ErrNotExist)
+}
+
+// findPacked returns the pack holding hash and its index position there.
+func (r *Repository) findPacked(hash string) (*Pack, int, error) {
+	packs, err := r.Packs()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, p := range packs {
+		if i, ok := p.Find(hash); ok {
+			return p, i, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("object %s %w", hash, ErrNotFound)
+}
+
+// ReadObject returns the type ("blob", "tree", "commit" or "tag") and
+// content of the object with the given hash, loose or packed.
+func (r *Repository) ReadObject(hash string) (string, []byte, error) {
+	f, path, err := r.OpenLoose(hash)
+	if errors.Is(err, os.ErrNotExist) {
+		p, i, err := r.findPacked(hash)
+		if err != nil {
+			return "", nil, err
+		}
+		if r.VerifyCRC {
+			if err := p.CheckCRC(i); err != nil {
+				return "", nil, err
+			}
+		}
+		objectType, content, err := p.Read(i)
+		if err != nil {
+			return "", nil, fmt.Errorf("object %s: %w", hash, err)
+		}
+		return objectType, content, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return "", nil, err
+	}
+	objectType, content, err := object.Inflate(b)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %s: %w", hash, path, err)
+	}
+	return objectType, content, nil
+}
+
+// ObjectHeader returns the type and size of an object, inflating no more
+// of it than its header.
+func (r *Repository) ObjectHeader(hash string) (string, int64, error) {
+	f, path, err := r.OpenLoose(hash)
+	if errors.Is(err, os.ErrNotExist) {
+		p, i, err := r.findPacked(hash)
+		if err != nil {
+			return "", 0, err
+		}
+		objectType, size, err := p.Header(i)
+		if err != nil {
+			return "", 0, fmt.Errorf("object %s: %w", hash, err)
+		}
+		return objectType, size, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	objectType, size, err := object.ReadLooseHeader(f)
+	if err != nil {
+		return "", 0, fmt.Errorf("object %s: %s: %w", hash, path, err)
+	}
+	return objectType, size, nil
+}
+
+// ObjectType returns the type of an object without inflating its content.
+func (r *Repository) ObjectType(hash string) (string, error) {
+	objectType, _, err := r.ObjectHeader(hash)
+	return objectType, err
+}
+
+// ObjectExists reports whether the repository has the object, loose or
+// packed, with a readable header.
+func (r *Repository) ObjectExists(hash string) bool {
+	_, _, err := r.ObjectHeader(hash)
+	return err == nil
+}
+
+// HasObject reports whether the object is stored, loose or packed. Unlike
+// ObjectExists it only looks for the file and in the pack indexes, so it
+// is cheap enough to ask before every write.
+func (r *Repository) HasObject(hash string) bool {
+	if !isFullHash(hash) {
+		return false
+	}
+	for _, dir := range r.ObjectDirs {
+		if _, err := os.Stat(filepath.Join(dir, hash[:2], hash[2:])); err == nil {
+			return true
+		}
+	}
+	_, _, err := r.findPacked(hash)
+	return err == nil
+}
+
+// WriteObject stores an object of the given type whose size bytes of
+// content are read from src as a loose object, and returns its hash. The
+// content is compressed into a temporary file as it is read, so objects of
+// any size pass through in constant memory; the file is renamed into place
+// once the hash is known, or dropped if the object is already stored.
+func (r *Repository) WriteObject(objectType string, size int64, src io.Reader) (string, error) {
+	objectDir := r.ObjectDirs[0]
+	tmp, err := os.CreateTemp(objectDir, "tmp_obj_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha1.New()
+	zw := zlib.NewWriter(tmp)
+	w := io.MultiWriter(h, zw)
+	fmt.Fprintf(w, "%s %d\x00", objectType, size)
+	n, err := io.CopyN(w, src, size)
+	if err == io.EOF {
+		return "", fmt.Errorf("short read: expected %d bytes, got %d", size, n)
+	}
+	if err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if r.HasObject(hash) {
+		return hash, nil
+	}
+	// Renaming means readers never see a partial object and concurrent
+	// writers of the same object cannot corrupt each other.
+	path := filepath.Join(objectDir, hash[:2], hash[2:])
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// WriteBlob stores what src holds, read to the end, as a blob and returns
+// its hash. The object header needs the size up front, so src is first
+// spooled to a temporary file in the object directory, keeping memory use
+// constant like WriteObject's.
+func (r *Repository) WriteBlob(src io.Reader) (string, error) {
+	spool, err := os.CreateTemp(r.ObjectDirs[0], "tmp_blob_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, src)
+	if err != nil {
+		return "", err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return r.WriteObject("blob", size, spool)
+}
+
+func isFullHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// git runs git in dir with a fixed identity and returns its trimmed output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+t.TempDir(),
+		"GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=a@example.com",
+		"GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=a@example.com",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newRepo makes a repository with two commits of a file that packs as a
+// delta, and returns its work tree.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	content := strings.Repeat("a line of the file\n", 200)
+	for _, extra := range []string{"", "one more line\n"} {
+		if err := os.WriteFile(filepath.Join(dir, "f"), []byte(content+extra), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "add", "f")
+		git(t, dir, "commit", "-q", "-m", "change f")
+	}
+	return dir
+}
+
+// checkObjects compares every object git lists in dir with what r reads.
+func checkObjects(t *testing.T, dir string, r *Repository) {
+	t.Helper()
+	list := git(t, dir, "cat-file", "--batch-all-objects", "--batch-check=%(objectname) %(objecttype)")
+	for _, line := range strings.Split(list, "\n") {
+		hash, wantType, _ := strings.Cut(line, " ")
+		objectType, content, err := r.ReadObject(hash)
+		if err != nil {
+			t.Errorf("ReadObject(%s): %v", hash, err)
+			continue
+		}
+		want := git(t, dir, "cat-file", wantType, hash)
+		if objectType != wantType || !bytes.Equal(bytes.TrimSpace(content), []byte(want)) {
+			t.Errorf("ReadObject(%s) = %s %q, want %s %q", hash, objectType, content, wantType, want)
+		}
+		if got, err := r.ObjectType(hash); got != wantType || err != nil {
+			t.Errorf("ObjectType(%s) = %s, %v, want %s", hash, got, err, wantType)
+		}
+		if !r.ObjectExists(hash) {
+			t.Errorf("ObjectExists(%s) = false", hash)
+		}
+	}
+}
+
+func TestReadObject(t *testing.T) {
+	tests := []struct {
+		name   string
+		prep   func(t *testing.T, dir string)
+		opened func(dir string) string
+	}{
+		{name: "loose"},
+		{name: "packed", prep: func(t *testing.T, dir string) {
+			git(t, dir, "repack", "-q", "-a", "-d", "--depth=10")
+		}},
+		{name: "git directory", opened: func(dir string) string { return filepath.Join(dir, ".git") }},
+		{name: "alternate", opened: func(dir string) string {
+			borrower := filepath.Join(dir, "borrower.git")
+			for _, sub := range []string{"objects/info", "refs"} {
+				os.MkdirAll(filepath.Join(borrower, sub), 0755)
+			}
+			os.WriteFile(filepath.Join(borrower, "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+			os.WriteFile(filepath.Join(borrower, "objects", "info", "alternates"), []byte(filepath.Join(dir, ".git", "objects")+"\n"), 0644)
+			return borrower
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newRepo(t)
+			if tt.prep != nil {
+				tt.prep(t, dir)
+			}
+			path := dir
+			if tt.opened != nil {
+				path = tt.opened(dir)
+			}
+			r, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			checkObjects(t, dir, r)
+		})
+	}
+}
+
+func TestMissingObject(t *testing.T) {
+	r, err := Open(newRepo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	const hash = "0123456789012345678901234567890123456789"
+	if _, _, err := r.ReadObject(hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadObject of a missing object: %v, want ErrNotFound", err)
+	}
+	if r.ObjectExists(hash) {
+		t.Error("ObjectExists of a missing object = true")
+	}
+	if _, err := r.ObjectType("not a hash"); err == nil {
+		t.Error("ObjectType of a bad name succeeded")
+	}
+}
+
+func TestOpenNotRepository(t *testing.T) {
+	if _, err := Open(t.TempDir()); err == nil {
+		t.Error("Open of an empty directory succeeded")
+	}
+}
+
+func TestOpenLinkedWorkTree(t *testing.T) {
+	dir := newRepo(t)
+	linked := filepath.Join(t.TempDir(), "linked")
+	git(t, dir, "worktree", "add", "-q", "--detach", linked)
+	gitDir := git(t, linked, "rev-parse", "--absolute-git-dir")
+	for _, path := range []string{linked, gitDir} {
+		r, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", path, err)
+		}
+		if want := filepath.Join(dir, ".git"); r.CommonDir != want {
+			t.Errorf("Open(%s): CommonDir = %s, want %s", path, r.CommonDir, want)
+		}
+		head, err := r.Refs.Resolve("HEAD")
+		if want := git(t, linked, "rev-parse", "HEAD"); head != want || err != nil {
+			t.Errorf("Open(%s): HEAD = %s, %v, want %s", path, head, err, want)
+		}
+		checkObjects(t, dir, r)
+		r.Close()
+	}
+}
+
+func TestWriteBlob(t *testing.T) {
+	dir := newRepo(t)
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	const content = "a blob written by WriteBlob\n"
+	hash, err := r.WriteBlob(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blob"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if want := git(t, dir, "hash-object", "blob"); hash != want {
+		t.Errorf("WriteBlob = %s, want %s", hash, want)
+	}
+	if got := git(t, dir, "cat-file", "blob", hash); got+"\n" != content {
+		t.Errorf("git reads the blob as %q, want %q", got, content)
+	}
+	if !r.HasObject(hash) {
+		t.Errorf("HasObject(%s) = false after WriteBlob", hash)
+	}
+	if again, err := r.WriteBlob(strings.NewReader(content)); again != hash || err != nil {
+		t.Errorf("WriteBlob of a stored blob = %s, %v, want %s", again, err, hash)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "tmp_*")); len(tmps) != 0 {
+		t.Errorf("WriteBlob left temporary files behind: %v", tmps)
+	}
+}
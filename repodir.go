@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushyadav1617/got/repo"
+)
+
+// gitDir is the repository's git directory and commonDir the one holding
+// what linked work trees share with the main one: objects, refs and config.
+// They are the same except in a linked work tree. Both are ".git" until
+// setupRepository finds the repository, and are then absolute.
+var (
+	gitDir    = ".git"
+	commonDir = ".git"
+)
+
+// bareRepo is set when the repository has no work tree, so commands that
+// need one refuse to run.
+var bareRepo bool
+
+// cwdPrefix is the directory the command was run from, relative to the
+// top of the work tree, with a trailing slash; "" at the top or outside
+// the work tree. startDir is the same directory in OS form, "." at the top,
+// and may lead out of the work tree.
+var (
+	cwdPrefix string
+	startDir  = "."
+)
+
+// gitPath joins elem onto the git directory, for what each work tree has
+// its own of: HEAD, the index and merge state.
+func gitPath(elem ...string) string {
+	return filepath.Join(append([]string{gitDir}, elem...)...)
+}
+
+// commonPath joins elem onto the common directory.
+func commonPath(elem ...string) string {
+	return filepath.Join(append([]string{commonDir}, elem...)...)
+}
+
+// setupRepository finds the repository the way git does and changes to the
+// top of its work tree, so that paths in the index and the work tree line
+// up with the current directory. The git directory comes from
+// gitDirOption, GIT_DIR, or the first ".git" directory or file found
+// walking up from the current directory; the work tree from workTreeOption,
+// GIT_WORK_TREE, core.worktree, or else the directory holding ".git" (with
+// an explicit git directory, the current directory). A git directory found
+// as a directory itself, or one with core.bare set and no work tree given,
+// is bare and got stays where it is. Outside a repository nothing changes,
+// and commands fail later as they would have.
+func setupRepository(gitDirOption, workTreeOption string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if gitDirOption == "" {
+		gitDirOption = os.Getenv("GIT_DIR")
+	}
+	if workTreeOption == "" {
+		workTreeOption = os.Getenv("GIT_WORK_TREE")
+	}
+
+	top := cwd
+	if gitDirOption != "" {
+		if gitDir, err = repo.ResolveGitDir(absPath(cwd, gitDirOption)); err != nil {
+			return err
+		}
+	} else {
+		found := false
+		for dir := cwd; ; dir = filepath.Dir(dir) {
+			if gitDir, err = repo.ResolveGitDir(filepath.Join(dir, ".git")); err == nil {
+				top, found = dir, true
+				break
+			}
+			if repo.IsGitDir(dir) {
+				gitDir, found, bareRepo = dir, true, true
+				break
+			}
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+		if !found {
+			gitDir = ".git"
+			return nil
+		}
+	}
+
+	commonDir = gitDir
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = absPath(gitDir, strings.TrimSpace(string(b)))
+	}
+	if workTreeOption != "" {
+		top, bareRepo = absPath(cwd, workTreeOption), false
+	} else if config, err := readRepoConfig(commonPath("config")); err == nil {
+		if worktree, ok := config.Get("core", "worktree"); ok {
+			top, bareRepo = absPath(gitDir, worktree), false
+		} else if config.Bool("core", "bare", false) {
+			bareRepo = true
+		}
+	}
+	if bareRepo {
+		return nil
+	}
+
+	// As in git, a command run outside the work tree given to it works on
+	// the whole of it.
+	if startDir, err = filepath.Rel(top, cwd); err != nil {
+		return err
+	}
+	if rel := filepath.ToSlash(startDir); rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+		cwdPrefix = rel + "/"
+	}
+	return os.Chdir(top)
+}
+
+// absPath resolves p against dir unless it is already absolute.
+func absPath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(dir, p)
+}
+
+// prefixPath turns a path given on the command line, relative to where got
+// was run, into a path from the top of the work tree, in slash form.
+func prefixPath(p string) string {
+	if filepath.IsAbs(p) {
+		top, err := os.Getwd()
+		if err == nil {
+			if rel, err := filepath.Rel(top, p); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+		return filepath.ToSlash(p)
+	}
+	return path.Clean(cwdPrefix + filepath.ToSlash(p))
+}
+
+// relativePath turns a path from the top of the work tree, in slash form,
+// into one relative to the directory got was run from, for showing to the
+// user. A trailing slash, as on untracked directories, is kept.
+func relativePath(p string) string {
+	if cwdPrefix == "" {
+		return p
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(cwdPrefix), filepath.FromSlash(p))
+	if err != nil {
+		return p
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(p, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// prefixFilename turns a file name given on the command line into one that
+// still names the same file after setupRepository changed directory.
+func prefixFilename(p string) string {
+	if p == "-" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(startDir, p)
+}
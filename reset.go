@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func runReset(args []string) {
+	usage := errors.New("usage: got reset [-q] [--soft | --mixed | --hard] [<commit>]\n   or: got reset [-q] [<commit>] [--] <path>...")
+	mode := ""
+	quiet, dashdash := false, false
+	var positional, paths []string
+	for _, arg := range args {
+		switch {
+		case dashdash:
+			paths = append(paths, arg)
+		case arg == "--":
+			dashdash = true
+		case arg == "--soft" || arg == "--mixed" || arg == "--hard":
+			if mode != "" && mode != arg {
+				handleError(usage)
+			}
+			mode = arg
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case len(arg) > 1 && arg[0] == '-':
+			handleError(usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	// Without "--", the first argument is the commit if it names one and
+	// everything else is a path.
+	rev := "HEAD"
+	if len(positional) > 0 {
+		if _, err := resolveRevision(positional[0]); err == nil || dashdash || (mode != "" && len(positional) == 1) {
+			rev, positional = positional[0], positional[1:]
+		}
+	}
+	paths = append(positional, paths...)
+
+	hash, err := resolveRevision(rev)
+	if err != nil {
+		handleError(fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree", rev))
+	}
+	commit, err := peelToCommit(hash)
+	if err != nil {
+		handleError(err)
+	}
+
+	if len(paths) > 0 {
+		if mode == "--soft" || mode == "--hard" {
+			handleError(fmt.Errorf("cannot do %s reset with paths", mode[2:]))
+		}
+		for i, p := range paths {
+			paths[i] = prefixPath(p)
+		}
+		if err := resetPaths(commit, paths); err != nil {
+			handleError(err)
+		}
+		if !quiet {
+			reportUnstaged()
+		}
+		return
+	}
+
+	if mode == "" {
+		mode = "--mixed"
+	}
+	if err := resetTo(commit, rev, mode); err != nil {
+		handleError(err)
+	}
+	switch {
+	case quiet:
+	case mode == "--hard":
+		fmt.Printf("HEAD is now at %s\n", describeCommit(commit))
+	case mode == "--mixed":
+		reportUnstaged()
+	}
+}
+
+// resetTo moves the current branch, or a detached HEAD, to commit. --mixed
+// also makes the index match commit's tree and --hard the working tree as
+// well, discarding local changes to tracked files. The previous HEAD is
+// kept in ORIG_HEAD.
+func resetTo(commit, rev, mode string) error {
+	merging := false
+	if _, err := os.Stat(mergeHeadPath()); err == nil {
+		merging = true
+	}
+	if mode == "--soft" && merging {
+		return errors.New("cannot do a soft reset in the middle of a merge")
+	}
+
+	branch, onBranch, err := currentBranch()
+	if err != nil {
+		return err
+	}
+	ref := "HEAD"
+	if onBranch {
+		ref = "refs/heads/" + branch
+	}
+	head, err := resolveRef("HEAD")
+	if err != nil && !errors.Is(err, errRefNotFound) {
+		return err
+	}
+
+	switch mode {
+	case "--hard":
+		if err := resetWorktree(commit); err != nil {
+			return err
+		}
+	case "--mixed":
+		if err := resetPaths(commit, nil); err != nil {
+			return err
+		}
+	}
+
+	if head != "" {
+		if err := writeRef("ORIG_HEAD", head); err != nil {
+			return err
+		}
+	}
+	if err := updateMergedRef(ref, onBranch, head, commit, "reset: moving to "+rev); err != nil {
+		return err
+	}
+	if mode != "--soft" {
+		return clearMergeState()
+	}
+	return nil
+}
+
+// resetWorktree makes the index and working tree match commit. Files that
+// are only staged, including the sides of unmerged paths, are deleted
+// since nothing else would keep them.
+func resetWorktree(commit string) error {
+	oldTree, err := headTree()
+	if err != nil {
+		return err
+	}
+	oldFiles, err := flattenTree(oldTree)
+	if err != nil {
+		return err
+	}
+	newTree, err := peelToTree(commit)
+	if err != nil {
+		return err
+	}
+	newFiles, err := flattenTree(newTree)
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	var staged []string
+	for _, entry := range idx.Entries {
+		_, inOld := oldFiles[entry.Path]
+		_, inNew := newFiles[entry.Path]
+		if !inOld && !inNew {
+			staged = append(staged, entry.Path)
+		}
+	}
+
+	if err := switchTree(commit, true); err != nil {
+		return err
+	}
+	for _, p := range staged {
+		if err := removeWorktreeFile(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetPaths makes the index entries at or under paths match commit's
+// tree, leaving the working tree alone. No paths means the whole index.
+// Entries that do not change keep their stat data.
+func resetPaths(commit string, paths []string) error {
+	tree, err := peelToTree(commit)
+	if err != nil {
+		return err
+	}
+	files, err := flattenTree(tree)
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+
+	var kept []*IndexEntry
+	for _, entry := range idx.Entries {
+		if !matchesPaths(entry.Path, paths) {
+			kept = append(kept, entry)
+			continue
+		}
+		target, ok := files[entry.Path]
+		if ok && entry.Stage() == 0 && entry.Hash == target.Hash && entry.ModeString() == target.Mode {
+			kept = append(kept, entry)
+			delete(files, entry.Path)
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !matchesPaths(name, paths) {
+			continue
+		}
+		entry, err := newIndexEntry(name, files[name].Mode, files[name].Hash, nil)
+		if err != nil {
+			return err
+		}
+		kept = append(kept, entry)
+	}
+	idx.Entries = kept
+	return idx.write()
+}
+
+// reportUnstaged lists the tracked files whose working tree copy differs
+// from the index, as git does after a reset.
+func reportUnstaged() {
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+	header := false
+	for _, entry := range idx.Entries {
+		change, err := worktreeChange(entry)
+		if err != nil {
+			handleError(err)
+		}
+		if change == "" {
+			continue
+		}
+		if change != "D" {
+			change = "M"
+		}
+		if !header {
+			fmt.Println("Unstaged changes after reset:")
+			header = true
+		}
+		fmt.Printf("%s\t%s\n", change, entry.Path)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+func runRestore(args []string) {
+	source := "HEAD"
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case strings.HasPrefix(arg, "--source="):
+			source = strings.TrimPrefix(arg, "--source=")
+		case arg == "--source" || arg == "-s":
+			if i+1 >= len(args) {
+				handleError(errors.New("option --source requires a value"))
+			}
+			i++
+			source = args[i]
+		default:
+			paths = append(paths, arg)
+		}
+	}
+	if len(paths) == 0 {
+		handleError(errors.New("usage: got restore [--source <tree-ish>] <path>..."))
+	}
+
+	hash, err := resolveRevision(source)
+	if err != nil {
+		handleError(err)
+	}
+	tree, err := peelToTree(hash)
+	if err != nil {
+		handleError(err)
+	}
+
+	for _, p := range paths {
+		if err := restorePath(tree, prefixPath(p)); err != nil {
+			handleError(err)
+		}
+	}
+}
+
+// restorePath overwrites the working tree copy of name, a file or a
+// directory, with its content in tree. Untracked files are left alone.
+func restorePath(tree, name string) error {
+	if name == "." {
+		return walkTree(tree, "", checkoutEntry)
+	}
+
+	entry, err := lookupTreePath(tree, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("pathspec '%s' did not match any file(s) known to got", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if object.IsTreeMode(entry.Mode) {
+		return walkTree(entry.Hash, name, checkoutEntry)
+	}
+	return checkoutEntry(name, entry)
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// resolveRevision turns a revision name into an object hash. It accepts a
+// full or abbreviated hash, HEAD or its "@" alias, a full ref name, a short
+// branch or tag name, <ref>@{<n>} reflog entries and the <branch>@{upstream}
+// and <branch>@{push} shorthands. Any of these may be followed by ~<n>,
+// ^<n> and ^{<type>} suffixes, and <rev>:<path> or :<path> name a blob or
+// tree in a commit or the index.
+func resolveRevision(name string) (string, error) {
+	if rev, path, found := strings.Cut(name, ":"); found {
+		return resolveRevisionPath(rev, path)
+	}
+
+	base, suffix := splitRevisionSuffix(name)
+	hash, err := resolveBaseRevision(base)
+	if err != nil {
+		return "", err
+	}
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		if op == '^' && strings.HasPrefix(suffix, "{") {
+			end := strings.IndexByte(suffix, '}')
+			if end == -1 {
+				return "", fmt.Errorf("unknown revision '%s'", name)
+			}
+			if hash, err = peelToType(hash, suffix[1:end]); err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+			suffix = suffix[end+1:]
+			continue
+		}
+
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(suffix[:digits]); err != nil {
+				return "", fmt.Errorf("unknown revision '%s'", name)
+			}
+			suffix = suffix[digits:]
+		}
+
+		switch {
+		case op == '~':
+			for ; n > 0; n-- {
+				if hash, err = commitParent(hash, 1, name); err != nil {
+					return "", err
+				}
+			}
+		case n == 0:
+			if hash, err = peelToCommit(hash); err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+		default:
+			if hash, err = commitParent(hash, n, name); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hash, nil
+}
+
+// splitRevisionSuffix separates the ~ and ^ operators from the name they
+// apply to. Ref names cannot contain either, so the suffix starts at the
+// first one outside an @{...}.
+func splitRevisionSuffix(name string) (string, string) {
+	for i := 0; i < len(name); i++ {
+		switch {
+		case strings.HasPrefix(name[i:], "@{"):
+			if end := strings.IndexByte(name[i:], '}'); end != -1 {
+				i += end
+			}
+		case name[i] == '~' || name[i] == '^':
+			return name[:i], name[i:]
+		}
+	}
+	return name, ""
+}
+
+// commitParent returns the nth parent of the commit hash points at.
+func commitParent(hash string, n int, name string) (string, error) {
+	hash, err := peelToCommit(hash)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	_, content, err := readObject(hash)
+	if err != nil {
+		return "", err
+	}
+	commit, err := object.ParseCommit(content)
+	if err != nil {
+		return "", err
+	}
+	if n > len(commit.Parents) || isShallow(hash) {
+		return "", fmt.Errorf("unknown revision '%s': commit %s has no parent %d", name, hash[:7], n)
+	}
+	return commit.Parents[n-1], nil
+}
+
+// resolveBaseRevision resolves a revision without ~ and ^ suffixes.
+func resolveBaseRevision(name string) (string, error) {
+	if isFullHash(name) {
+		return strings.ToLower(name), nil
+	}
+	if ref, n, ok := parseReflogSelector(name); ok {
+		return resolveReflogEntry(ref, n)
+	}
+
+	ref, err := expandRevision(name)
+	if err != nil {
+		return "", err
+	}
+	if ref != name {
+		return resolveRef(ref)
+	}
+
+	if name != "" {
+		for _, ref := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+			hash, err := resolveRef(ref)
+			if err == nil {
+				return hash, nil
+			}
+			if !errors.Is(err, errRefNotFound) {
+				return "", err
+			}
+		}
+	}
+
+	if len(name) >= minAbbrev && isHex(name) {
+		matches, err := findObjectsByPrefix(name)
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+		default:
+			return "", fmt.Errorf("short object ID %s %w; candidates are:\n\t%s", name, errAmbiguous, strings.Join(matches, "\n\t"))
+		}
+	}
+	return "", fmt.Errorf("unknown revision '%s'", name)
+}
+
+// errAmbiguous is wrapped by the error for a hash prefix that names more
+// than one object.
+var errAmbiguous = errors.New("is ambiguous")
+
+// minAbbrev is the shortest hash prefix accepted in place of a full hash.
+const minAbbrev = 4
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// findObjectsByPrefix returns, sorted, the hashes of every loose or packed
+// object that starts with prefix.
+func findObjectsByPrefix(prefix string) ([]string, error) {
+	prefix = strings.ToLower(prefix)
+	found := make(map[string]bool)
+
+	r, err := currentRepo()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range r.ObjectDirs {
+		names, err := os.ReadDir(filepath.Join(dir, prefix[:2]))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range names {
+			hash := prefix[:2] + entry.Name()
+			if isFullHash(hash) && strings.HasPrefix(hash, prefix) {
+				found[hash] = true
+			}
+		}
+	}
+
+	packs, err := r.Packs()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		for _, hash := range p.HashesWithPrefix(prefix) {
+			found[hash] = true
+		}
+	}
+
+	matches := make([]string, 0, len(found))
+	for hash := range found {
+		matches = append(matches, hash)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// parseReflogSelector splits "<ref>@{<n>}" into the ref and n.
+func parseReflogSelector(name string) (string, int, bool) {
+	ref, rest, found := strings.Cut(name, "@{")
+	if !found || !strings.HasSuffix(rest, "}") || !isDigits(rest[:len(rest)-1]) || len(rest) == 1 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(rest[:len(rest)-1])
+	if err != nil {
+		return "", 0, false
+	}
+	return ref, n, true
+}
+
+// resolveReflogEntry returns where ref pointed n moves ago, from its
+// reflog. An empty ref means the current branch.
+func resolveReflogEntry(ref string, n int) (string, error) {
+	ref, err := reflogRef(ref)
+	if err != nil {
+		return "", err
+	}
+	entries, err := readReflog(ref)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for '%s' only has %d entries", ref, len(entries))
+	}
+	return entries[len(entries)-1-n].New, nil
+}
+
+// resolveRevisionPath resolves <rev>:<path> to the entry at path in rev's
+// tree, and :<path> or :<stage>:<path> to the entry staged in the index.
+func resolveRevisionPath(rev, name string) (string, error) {
+	name = strings.Trim(name, "/")
+	if rev == "" {
+		stage := 0
+		if len(name) > 2 && name[1] == ':' && name[0] >= '0' && name[0] <= '3' {
+			stage, name = int(name[0]-'0'), name[2:]
+		}
+		idx, err := readIndex()
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range idx.Entries {
+			if entry.Path == name && entry.Stage() == stage {
+				return entry.Hash, nil
+			}
+		}
+		return "", fmt.Errorf("path '%s' is not in the index at stage %d", name, stage)
+	}
+
+	hash, err := resolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
+	tree, err := peelToTree(hash)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", rev, err)
+	}
+	if name == "" {
+		return tree, nil
+	}
+	entry, err := lookupTreePath(tree, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", name, rev)
+	}
+	if err != nil {
+		return "", err
+	}
+	return entry.Hash, nil
+}
+
+// expandRevision rewrites the "@" shorthands into the full ref name they
+// stand for. Other names are returned unchanged.
+func expandRevision(name string) (string, error) {
+	if name == "@" {
+		return "HEAD", nil
+	}
+
+	branch, suffix, found := strings.Cut(name, "@{")
+	if !found || !strings.HasSuffix(suffix, "}") {
+		return name, nil
+	}
+	suffix = strings.ToLower(strings.TrimSuffix(suffix, "}"))
+
+	if branch == "" || branch == "HEAD" {
+		current, onBranch, err := currentBranch()
+		if err != nil {
+			return "", err
+		}
+		if !onBranch {
+			return "", errors.New("HEAD does not point to a branch")
+		}
+		branch = current
+	}
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+
+	switch suffix {
+	case "u", "upstream":
+		return upstreamRef(branch)
+	case "push":
+		return pushRef(branch)
+	}
+	return name, nil
+}
+
+// upstreamRef returns the remote-tracking ref configured as the upstream of
+// branch through branch.<name>.remote and branch.<name>.merge.
+func upstreamRef(branch string) (string, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return "", err
+	}
+	remote, hasRemote := repo.Config.Get("branch."+branch, "remote")
+	merge, hasMerge := repo.Config.Get("branch."+branch, "merge")
+	if !hasRemote || !hasMerge {
+		return "", fmt.Errorf("no upstream configured for branch '%s'", branch)
+	}
+	if remote == "." {
+		return merge, nil
+	}
+	return remoteTrackingRef(repo, remote, merge)
+}
+
+// pushRef returns the remote-tracking ref for where "got push" would send
+// branch: the push remote (branch.<name>.pushRemote, remote.pushDefault or
+// the upstream remote) and, unless push.default is "upstream", a branch of
+// the same name.
+func pushRef(branch string) (string, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return "", err
+	}
+
+	remote, ok := repo.Config.Get("branch."+branch, "pushRemote")
+	if !ok {
+		remote, ok = repo.Config.Get("remote", "pushDefault")
+	}
+	if !ok {
+		remote, ok = repo.Config.Get("branch."+branch, "remote")
+	}
+	if !ok {
+		return "", fmt.Errorf("no push destination configured for branch '%s'", branch)
+	}
+
+	if mode, _ := repo.Config.Get("push", "default"); mode == "upstream" {
+		return upstreamRef(branch)
+	}
+	if remote == "." {
+		return "refs/heads/" + branch, nil
+	}
+	return remoteTrackingRef(repo, remote, "refs/heads/"+branch)
+}
+
+// remoteTrackingRef maps a ref on the named remote to the local ref it is
+// fetched into, using the remote's fetch refspecs.
+func remoteTrackingRef(repo *Repo, remote, ref string) (string, error) {
+	for _, spec := range repo.Config.values[configKey("remote."+remote, "fetch")] {
+		if local, ok := mapRefspec(spec, ref); ok {
+			return local, nil
+		}
+	}
+	return "", fmt.Errorf("ref '%s' is not fetched from remote '%s'", ref, remote)
+}
+
+// mapRefspec applies a "[+]src:dst" refspec, where both sides may contain
+// a single "*", to ref.
+func mapRefspec(spec, ref string) (string, bool) {
+	src, dst, found := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+	if !found {
+		return "", false
+	}
+	prefix, suffix, wildcard := strings.Cut(src, "*")
+	if !wildcard {
+		return dst, src == ref
+	}
+	if !strings.HasPrefix(ref, prefix) || !strings.HasSuffix(ref, suffix) || len(ref) < len(prefix)+len(suffix) {
+		return "", false
+	}
+	match := ref[len(prefix) : len(ref)-len(suffix)]
+	return strings.Replace(dst, "*", match, 1), true
+}
+
+// peelToType dereferences tags, and commits to their trees, until it
+// reaches an object of the wanted type. An empty type peels tags only, and
+// "object" accepts anything that exists.
+func peelToType(hash, want string) (string, error) {
+	for {
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case objectType == want || want == "object":
+			return hash, nil
+		case want == "" && objectType != "tag":
+			return hash, nil
+		case objectType == "tag":
+			if hash, err = tagTarget(content); err != nil {
+				return "", err
+			}
+		case objectType == "commit" && want == "tree":
+			commit, err := object.ParseCommit(content)
+			if err != nil {
+				return "", err
+			}
+			hash = commit.Tree
+		default:
+			return "", fmt.Errorf("expected %s type, but the object dereferences to %s type", want, objectType)
+		}
+	}
+}
+
+func isFullHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func runRevParse(args []string) {
+	symbolic, abbrevRef, verify := false, false, false
+	short := 0
+	var revisions []string
+	for _, arg := range args {
+		switch {
+		case arg == "--symbolic-full-name":
+			symbolic = true
+		case arg == "--abbrev-ref":
+			abbrevRef = true
+		case arg == "--verify":
+			verify = true
+		case arg == "--short":
+			short = 7
+		case strings.HasPrefix(arg, "--short="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--short="))
+			if err != nil {
+				handleError(fmt.Errorf("invalid --short value '%s'", arg))
+			}
+			short = min(max(n, minAbbrev), 40)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			handleError(errors.New("usage: got rev-parse [--verify] [--short[=<n>]] [--symbolic-full-name | --abbrev-ref] <revision>..."))
+		default:
+			revisions = append(revisions, arg)
+		}
+	}
+	if verify && len(revisions) != 1 {
+		handleError(errors.New("needed a single revision"))
+	}
+
+	for _, arg := range revisions {
+		if symbolic || abbrevRef {
+			ref, err := expandRevision(arg)
+			if err != nil {
+				handleError(err)
+			}
+			if ref == "HEAD" || arg == "HEAD" {
+				if branch, onBranch, err := currentBranch(); err == nil && onBranch {
+					ref = "refs/heads/" + branch
+				}
+			}
+			if abbrevRef {
+				ref = shortRefName(ref)
+			}
+			fmt.Println(ref)
+			continue
+		}
+
+		hash, err := resolveRevision(arg)
+		if err != nil {
+			handleError(err)
+		}
+		if short > 0 {
+			hash = uniqueAbbrev(hash, short)
+		}
+		fmt.Println(hash)
+	}
+}
+
+// uniqueAbbrev shortens hash to n characters, or more if needed to keep it
+// unambiguous.
+func uniqueAbbrev(hash string, n int) string {
+	for ; n < len(hash); n++ {
+		if matches, err := findObjectsByPrefix(hash[:n]); err == nil && len(matches) <= 1 {
+			break
+		}
+	}
+	return hash[:n]
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// newUpstreamRepo makes a repository on branch main with remote-tracking
+// refs for two remotes, origin and fork, each at a different commit.
+func newUpstreamRepo(t *testing.T) string {
+	t.Helper()
+	dir := newTestRepo(t)
+	for _, name := range []string{"one", "two", "three", "four"} {
+		commitFile(t, dir, name, name+"\n", name)
+	}
+	for ref, rev := range map[string]string{
+		"refs/remotes/origin/main":  "HEAD~1",
+		"refs/remotes/origin/other": "HEAD~2",
+		"refs/remotes/fork/main":    "HEAD~3",
+		"refs/heads/other":          "HEAD~2",
+	} {
+		runGit(t, dir, "update-ref", ref, rev)
+	}
+	for _, kv := range [][2]string{
+		{"remote.origin.url", "ssh://localhost/origin.git"},
+		{"remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"},
+		{"remote.fork.url", "ssh://localhost/fork.git"},
+		{"remote.fork.fetch", "+refs/heads/*:refs/remotes/fork/*"},
+	} {
+		runGit(t, dir, "config", kv[0], kv[1])
+	}
+	return dir
+}
+
+func TestRevParseUpstream(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    [][2]string
+		revisions []string
+	}{
+		{
+			name:      "at sign",
+			revisions: []string{"@", "@~1", "@^{tree}"},
+		},
+		{
+			name:      "upstream",
+			config:    [][2]string{{"branch.main.remote", "origin"}, {"branch.main.merge", "refs/heads/main"}},
+			revisions: []string{"@{upstream}", "@{u}", "@{U}", "main@{u}", "@{u}~1", "@{upstream}^{tree}"},
+		},
+		{
+			name:      "upstream of another name",
+			config:    [][2]string{{"branch.main.remote", "origin"}, {"branch.main.merge", "refs/heads/other"}},
+			revisions: []string{"@{u}", "main@{upstream}"},
+		},
+		{
+			name:      "local upstream",
+			config:    [][2]string{{"branch.main.remote", "."}, {"branch.main.merge", "refs/heads/other"}},
+			revisions: []string{"@{u}"},
+		},
+		{
+			name: "push remote",
+			config: [][2]string{
+				{"branch.main.remote", "origin"}, {"branch.main.merge", "refs/heads/main"},
+				{"branch.main.pushRemote", "fork"}, {"push.default", "current"},
+			},
+			revisions: []string{"@{push}", "main@{push}", "@{u}"},
+		},
+		{
+			name: "push default remote",
+			config: [][2]string{
+				{"branch.main.remote", "origin"}, {"branch.main.merge", "refs/heads/main"},
+				{"remote.pushDefault", "fork"}, {"push.default", "current"},
+			},
+			revisions: []string{"@{push}"},
+		},
+		{
+			name: "push to upstream",
+			config: [][2]string{
+				{"branch.main.remote", "origin"}, {"branch.main.merge", "refs/heads/other"},
+				{"push.default", "upstream"},
+			},
+			revisions: []string{"@{push}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newUpstreamRepo(t)
+			for _, kv := range tt.config {
+				runGit(t, dir, "config", kv[0], kv[1])
+			}
+			for _, rev := range tt.revisions {
+				want := runGit(t, dir, "rev-parse", rev)
+				if got := runGot(t, dir, "rev-parse", rev); got != want {
+					t.Errorf("rev-parse %s = %s, git has %s", rev, strings.TrimSpace(got), strings.TrimSpace(want))
+				}
+				// git names no ref for a revision with a suffix.
+				if strings.ContainsAny(rev, "~^") {
+					continue
+				}
+				want = runGit(t, dir, "rev-parse", "--symbolic-full-name", rev)
+				if got := runGot(t, dir, "rev-parse", "--symbolic-full-name", rev); got != want {
+					t.Errorf("rev-parse --symbolic-full-name %s = %s, git has %s", rev, strings.TrimSpace(got), strings.TrimSpace(want))
+				}
+			}
+		})
+	}
+}
+
+func TestRevParseNoUpstream(t *testing.T) {
+	dir := newUpstreamRepo(t)
+	for _, rev := range []string{"@{u}", "@{push}", "other@{upstream}"} {
+		_, err := runCommand(dir, gotBinary, "rev-parse", rev)
+		if err == nil || !strings.Contains(err.Error(), "no ") {
+			t.Errorf("rev-parse %s without an upstream: %v", rev, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runRm(args []string) {
+	var cached, force, recursive, quiet bool
+	var paths []string
+	for _, arg := range args {
+		switch arg {
+		case "--cached":
+			cached = true
+		case "-f", "--force":
+			force = true
+		case "-r":
+			recursive = true
+		case "-q", "--quiet":
+			quiet = true
+		default:
+			paths = append(paths, prefixPath(arg))
+		}
+	}
+	if len(paths) == 0 {
+		handleError(errors.New("usage: got rm [--cached] [-f] [-r] [-q] <path>..."))
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		handleError(err)
+	}
+
+	// Work out every entry to remove and check them all before touching
+	// anything, so a refused path leaves the index unchanged.
+	var targets []*IndexEntry
+	for _, p := range paths {
+		matched := false
+		for _, entry := range idx.Entries {
+			if entry.Path == p || (recursive && (p == "." || strings.HasPrefix(entry.Path, p+"/"))) {
+				targets = append(targets, entry)
+				matched = true
+			} else if strings.HasPrefix(entry.Path, p+"/") {
+				handleError(fmt.Errorf("not removing '%s' recursively without -r", p))
+			}
+		}
+		if !matched {
+			handleError(fmt.Errorf("pathspec '%s' did not match any files", p))
+		}
+	}
+
+	// Like git, refuse to lose content that is only in the index or only
+	// in the working tree.
+	if !force {
+		tree, err := headTree()
+		if err != nil {
+			handleError(err)
+		}
+		headFiles, err := flattenTree(tree)
+		if err != nil {
+			handleError(err)
+		}
+		for _, entry := range targets {
+			modified, err := worktreeModified(entry)
+			if err != nil {
+				handleError(err)
+			}
+			head, ok := headFiles[entry.Path]
+			staged := !ok || head.Hash != entry.Hash || head.Mode != entry.ModeString()
+			switch {
+			case staged && modified:
+				handleError(fmt.Errorf("'%s' has staged content different from both the file and the HEAD (use -f to force removal)", entry.Path))
+			case cached:
+			case staged:
+				handleError(fmt.Errorf("'%s' has changes staged in the index (use --cached to keep the file, or -f to force removal)", entry.Path))
+			case modified:
+				handleError(fmt.Errorf("'%s' has local modifications (use --cached to keep the file, or -f to force removal)", entry.Path))
+			}
+		}
+	}
+
+	for _, entry := range targets {
+		idx.remove(entry.Path)
+		// As in git, directories left empty go too.
+		if !cached {
+			if err := removeWorktreeFile(entry.Path); err != nil {
+				handleError(err)
+			}
+		}
+		if !quiet {
+			fmt.Printf("rm '%s'\n", entry.Path)
+		}
+	}
+
+	if err := idx.write(); err != nil {
+		handleError(err)
+	}
+}
+
+// worktreeModified reports whether the working tree copy of entry differs
+// from what is staged. A missing file counts as unmodified.
+func worktreeModified(entry *IndexEntry) (bool, error) {
+	mode, content, err := readWorktreeBlob(filepath.FromSlash(entry.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mode != entry.ModeString() || hashBlob(content) != entry.Hash, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// deepen is how much history fetchPack asks for: depth commits from each
+// wanted tip, or with relative, depth more than the repository's shallow
+// boundary already has. A zero depth asks for all of it.
+type deepen struct {
+	depth    int
+	relative bool
+}
+
+// unshallowDepth is the depth git asks for to fetch the whole history of a
+// shallow repository.
+const unshallowDepth = 0x7fffffff
+
+// shallowUpdate is what a server says about the shallow boundary after a
+// fetch: commits whose parents it did not send, and commits that were
+// shallow and now have them.
+type shallowUpdate struct {
+	shallow, unshallow []string
+}
+
+// shallowState caches the shallow file, which every history walk consults.
+var shallowState struct {
+	sync.Mutex
+	commits map[string]bool
+}
+
+// readShallow returns the commits the shallow file lists: the boundary of
+// a shallow clone, whose parents the repository does not have.
+func readShallow() (map[string]bool, error) {
+	shallowState.Lock()
+	defer shallowState.Unlock()
+	if shallowState.commits != nil {
+		return shallowState.commits, nil
+	}
+	commits := make(map[string]bool)
+	data, err := os.ReadFile(commonPath("shallow"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, line := range strings.Fields(string(data)) {
+		if !isFullHash(line) {
+			return nil, fmt.Errorf("bad shallow line: %s", line)
+		}
+		commits[line] = true
+	}
+	shallowState.commits = commits
+	return commits, nil
+}
+
+// isShallow reports whether hash is on the shallow boundary. History walks
+// treat such commits as having no parents, as git does.
+func isShallow(hash string) bool {
+	commits, err := readShallow()
+	return err == nil && commits[hash]
+}
+
+// apply records u in the shallow file, removing the file when nothing is
+// shallow any more.
+func (u shallowUpdate) apply() error {
+	if len(u.shallow) == 0 && len(u.unshallow) == 0 {
+		return nil
+	}
+	commits, err := readShallow()
+	if err != nil {
+		return err
+	}
+	next := make(map[string]bool, len(commits)+len(u.shallow))
+	for hash := range commits {
+		next[hash] = true
+	}
+	for _, hash := range u.shallow {
+		next[hash] = true
+	}
+	for _, hash := range u.unshallow {
+		delete(next, hash)
+	}
+
+	path := commonPath("shallow")
+	if len(next) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		lines := make([]string, 0, len(next))
+		for hash := range next {
+			lines = append(lines, hash)
+		}
+		slices.Sort(lines)
+		tmp := path + ".lock"
+		if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+	shallowState.Lock()
+	shallowState.commits = next
+	shallowState.Unlock()
+	return nil
+}
+
+// parseLine adds a "shallow <hash>" or "unshallow <hash>" line from
+// the server to u, reporting whether it was one.
+func (u *shallowUpdate) parseLine(line string) (bool, error) {
+	kind, hash, _ := strings.Cut(line, " ")
+	if kind != "shallow" && kind != "unshallow" {
+		return false, nil
+	}
+	if !isFullHash(hash) {
+		return false, fmt.Errorf("bad %s line %q", kind, line)
+	}
+	if kind == "shallow" {
+		u.shallow = append(u.shallow, hash)
+	} else {
+		u.unshallow = append(u.unshallow, hash)
+	}
+	return true, nil
+}
+
+// writeShallowRequest writes the lines that tell the server where our
+// history stops and how much more of it to send: the shallow commits we
+// have, then the depth. Asking for relative depth is up to the caller, as
+// the two protocol versions differ there. It reports whether it wrote
+// anything, in which case the server answers with the new boundary.
+func writeShallowRequest(req io.Writer, d deepen) (bool, error) {
+	commits, err := readShallow()
+	if err != nil {
+		return false, err
+	}
+	hashes := make([]string, 0, len(commits))
+	for hash := range commits {
+		hashes = append(hashes, hash)
+	}
+	slices.Sort(hashes)
+	for _, hash := range hashes {
+		writePktLine(req, "shallow "+hash+"\n")
+	}
+	if d.depth > 0 {
+		writePktLine(req, fmt.Sprintf("deepen %d\n", d.depth))
+	}
+	return len(hashes) > 0 || d.depth > 0, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+func runShow(args []string) {
+	var names []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			handleError(errors.New("usage: got show [<object>...]"))
+		}
+		names = append(names, arg)
+	}
+	if len(names) == 0 {
+		names = []string{"HEAD"}
+	}
+
+	// Commits are separated by a blank line, as in git log.
+	shownCommit := false
+	for _, name := range names {
+		hash, err := resolveRevision(name)
+		if err != nil {
+			handleError(err)
+		}
+		if err := showObject(os.Stdout, name, hash, &shownCommit); err != nil {
+			handleError(err)
+		}
+	}
+}
+
+// showObject writes an object the way git show does: a commit as in git
+// log followed by its patch against the first parent, a tag's header and
+// message followed by whatever it points at, a tree as a list of names
+// and a blob as it is.
+func showObject(w io.Writer, name, hash string, shownCommit *bool) error {
+	objectType, content, err := readObject(hash)
+	if err != nil {
+		return err
+	}
+	switch objectType {
+	case "commit":
+		if *shownCommit {
+			fmt.Fprintln(w)
+		}
+		*shownCommit = true
+		return showCommit(w, hash)
+	case "tag":
+		target, err := showTag(w, content)
+		if err != nil {
+			return err
+		}
+		return showObject(w, target, target, shownCommit)
+	case "tree":
+		entries, err := object.ParseTree(content)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "tree %s\n\n", name)
+		for _, entry := range entries {
+			if object.IsTreeMode(entry.Mode) {
+				fmt.Fprintf(w, "%s/\n", entry.Name)
+			} else {
+				fmt.Fprintln(w, entry.Name)
+			}
+		}
+		return nil
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// showCommit writes a commit's log entry and the patch it introduces. A
+// root commit is compared with the empty tree. Merges are shown without a
+// patch, since no single parent describes their change, as git's combined
+// diff is for a merge that resolved cleanly.
+func showCommit(w io.Writer, hash string) error {
+	c, err := readLogCommit(hash)
+	if err != nil {
+		return err
+	}
+	if err := writeMediumLog(w, c); err != nil {
+		return err
+	}
+	if len(c.Commit.Parents) > 1 {
+		fmt.Fprintln(w)
+		return nil
+	}
+	parentTree := ""
+	if len(c.Commit.Parents) == 1 {
+		if parentTree, err = peelToTree(c.Commit.Parents[0]); err != nil {
+			return err
+		}
+	}
+	changes, err := diffTrees(parentTree, c.Commit.Tree, "")
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, change := range changes {
+		if err := writeTreeChangePatch(w, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// showTag writes an annotated tag's name, tagger and message, and returns
+// the object it points at.
+func showTag(w io.Writer, content []byte) (string, error) {
+	target, err := tagTarget(content)
+	if err != nil {
+		return "", err
+	}
+	header, message, _ := strings.Cut(string(content), "\n\n")
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tag":
+			fmt.Fprintf(w, "tag %s\n", value)
+		case "tagger":
+			tagger, err := parseSignature(value)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(w, "Tagger: %s <%s>\n", tagger.Name, tagger.Email)
+			fmt.Fprintf(w, "Date:   %s\n", tagger.When.Format(gitDateLayout))
+		}
+	}
+	fmt.Fprintf(w, "\n%s\n", strings.TrimRight(message, "\n"))
+	fmt.Fprintln(w)
+	return target, nil
+}
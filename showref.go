@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// peelTag follows annotated tags starting at hash until it reaches an
+// object that is not a tag.
+func peelTag(hash string) (string, error) {
+	for {
+		objectType, content, err := readObject(hash)
+		if err != nil {
+			return "", err
+		}
+		if objectType != "tag" {
+			return hash, nil
+		}
+		target, err := tagTarget(content)
+		if err != nil {
+			return "", err
+		}
+		hash = target
+	}
+}
+
+// tagTarget returns the object field of a tag object.
+func tagTarget(content []byte) (string, error) {
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			break
+		}
+		if target, found := strings.CutPrefix(line, "object "); found {
+			return target, nil
+		}
+	}
+	return "", errors.New("malformed tag: missing object")
+}
+
+func runShowRef(args []string) {
+	var heads, tags, dereference bool
+	for _, arg := range args {
+		switch arg {
+		case "--heads":
+			heads = true
+		case "--tags":
+			tags = true
+		case "-d", "--dereference":
+			dereference = true
+		default:
+			handleError(errors.New("usage: got show-ref [--heads] [--tags] [-d]"))
+		}
+	}
+
+	packed, err := readPackedRefs()
+	if err != nil {
+		handleError(err)
+	}
+	refs, err := listRefs()
+	if err != nil {
+		handleError(err)
+	}
+
+	var sorted []string
+	for _, name := range refs {
+		if (heads || tags) &&
+			!(heads && strings.HasPrefix(name, "refs/heads/")) &&
+			!(tags && strings.HasPrefix(name, "refs/tags/")) {
+			continue
+		}
+		sorted = append(sorted, name)
+	}
+
+	for _, name := range sorted {
+		hash, err := resolveRef(name)
+		if err != nil {
+			handleError(err)
+		}
+		fmt.Printf("%s %s\n", hash, name)
+
+		if !dereference || !strings.HasPrefix(name, "refs/tags/") {
+			continue
+		}
+		peeled := packed[name].Peeled
+		if peeled == "" || packed[name].Hash != hash {
+			if peeled, err = peelTag(hash); err != nil {
+				handleError(err)
+			}
+		}
+		if peeled != hash {
+			fmt.Printf("%s %s^{}\n", peeled, name)
+		}
+	}
+
+	if len(sorted) == 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// A stash is a commit of the working tree whose first parent is HEAD and
+// whose second is a commit of the index, as git makes them. refs/stash
+// points at the newest and its reflog holds the whole list.
+const stashRef = "refs/stash"
+
+func runStash(args []string) {
+	usage := errors.New("usage: got stash [push [-q] [-k | --keep-index] [-m <message>]]\n   or: got stash list\n   or: got stash (apply | pop) [-q] [--index] [<stash>]\n   or: got stash drop [-q] [<stash>]")
+	// As in git, options with no subcommand before them are for push.
+	sub := "push"
+	if len(args) > 0 && (len(args[0]) < 2 || args[0][0] != '-') {
+		sub, args = args[0], args[1:]
+	}
+	var quiet, restoreIndex, keepIndex bool
+	var message string
+	var names []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "--index" && (sub == "apply" || sub == "pop"):
+			restoreIndex = true
+		case (arg == "-k" || arg == "--keep-index") && sub == "push":
+			keepIndex = true
+		case (arg == "-m" || arg == "--message") && sub == "push":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			message = args[i]
+		case len(arg) > 1 && arg[0] == '-':
+			handleError(usage)
+		default:
+			names = append(names, arg)
+		}
+	}
+	if len(names) > 1 || (len(names) > 0 && (sub == "push" || sub == "list")) {
+		handleError(usage)
+	}
+	name := "refs/stash@{0}"
+	if len(names) == 1 {
+		name = names[0]
+	}
+
+	switch sub {
+	case "push":
+		if err := stashPush(message, keepIndex, quiet); err != nil {
+			handleError(err)
+		}
+	case "list":
+		entries, err := readReflog(stashRef)
+		if err != nil {
+			handleError(err)
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			fmt.Printf("stash@{%d}: %s\n", len(entries)-1-i, entries[i].Message)
+		}
+	case "apply", "pop":
+		n, hash, err := stashEntry(name)
+		if err != nil {
+			handleError(err)
+		}
+		clean, err := stashApply(hash, restoreIndex)
+		if err != nil {
+			handleError(err)
+		}
+		if !quiet {
+			status, err := readStatus()
+			if err != nil {
+				handleError(err)
+			}
+			writeLongStatus(status)
+		}
+		if !clean {
+			if sub == "pop" {
+				fmt.Println("The stash entry is kept in case you need it again.")
+			}
+			exit(1)
+		}
+		if sub == "pop" {
+			if err := stashDrop(n); err != nil {
+				handleError(err)
+			}
+			if !quiet {
+				fmt.Printf("Dropped %s (%s)\n", name, hash)
+			}
+		}
+	case "drop":
+		n, hash, err := stashEntry(name)
+		if err != nil {
+			handleError(err)
+		}
+		if err := stashDrop(n); err != nil {
+			handleError(err)
+		}
+		if !quiet {
+			fmt.Printf("Dropped %s (%s)\n", name, hash)
+		}
+	default:
+		handleError(usage)
+	}
+}
+
+// stashEntry resolves "stash@{<n>}", or just "<n>", to its position in the
+// stash list and the stash commit there.
+func stashEntry(name string) (int, string, error) {
+	n, err := strconv.Atoi(name)
+	if err != nil || !isDigits(name) {
+		ref, m, ok := parseReflogSelector(name)
+		if !ok || (ref != "stash" && ref != stashRef) {
+			return 0, "", fmt.Errorf("'%s' is not a stash reference", name)
+		}
+		n = m
+	}
+	entries, err := readReflog(stashRef)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(entries) == 0 {
+		return 0, "", errors.New("no stash entries found")
+	}
+	if n >= len(entries) {
+		return 0, "", fmt.Errorf("%s is not a valid reference", name)
+	}
+	return n, entries[len(entries)-1-n].New, nil
+}
+
+// stashPush saves the index and the tracked files' local changes as a
+// stash, then resets the index and working tree to HEAD. With keepIndex,
+// what was staged stays in the index and the working tree is reset to it
+// instead. Untracked files are left alone.
+func stashPush(message string, keepIndex, quiet bool) error {
+	head, err := resolveRef("HEAD")
+	if errors.Is(err, errRefNotFound) {
+		return errors.New("you do not have the initial commit yet")
+	}
+	if err != nil {
+		return err
+	}
+	headTree, err := peelToTree(head)
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	indexTree, err := writeIndexTree(idx, "", false)
+	if err != nil {
+		return err
+	}
+	// The working tree is the index with every tracked file's current
+	// content, built on a copy so the real index keeps what is staged.
+	work := &Index{}
+	for _, entry := range idx.Entries {
+		copied := *entry
+		work.Entries = append(work.Entries, &copied)
+	}
+	if err := stageTrackedChanges(work); err != nil {
+		return err
+	}
+	workTree, err := writeIndexTree(work, "", false)
+	if err != nil {
+		return err
+	}
+	if indexTree == headTree && workTree == headTree {
+		if !quiet {
+			fmt.Println("No local changes to save")
+		}
+		return nil
+	}
+
+	branch, onBranch, err := currentBranch()
+	if err != nil {
+		return err
+	}
+	if !onBranch {
+		branch = "(no branch)"
+	}
+	description := fmt.Sprintf("%s: %s", branch, describeCommit(head))
+	indexCommit, err := createCommit(indexTree, []string{head}, "index on "+description+"\n")
+	if err != nil {
+		return err
+	}
+	reason := "WIP on " + description
+	if message != "" {
+		reason = fmt.Sprintf("On %s: %s", branch, message)
+	}
+	stash, err := createCommit(workTree, []string{head, indexCommit}, reason+"\n")
+	if err != nil {
+		return err
+	}
+
+	old, err := resolveRef(stashRef)
+	if err != nil && !errors.Is(err, errRefNotFound) {
+		return err
+	}
+	if err := writeRef(stashRef, stash); err != nil {
+		return err
+	}
+	if err := appendReflog(stashRef, old, stash, reason); err != nil {
+		return err
+	}
+	if err := resetWorktree(head); err != nil {
+		return err
+	}
+	// The index matches HEAD again, so moving to the index commit puts
+	// back exactly the staged paths.
+	if keepIndex && indexTree != headTree {
+		if err := switchTree(indexCommit, false); err != nil {
+			return err
+		}
+	}
+	if !quiet {
+		fmt.Printf("Saved working directory and index state %s\n", reason)
+	}
+	return nil
+}
+
+// stashApply merges the changes a stash made to its HEAD into the current
+// index and working tree, and reports whether that went without conflict.
+// The changes are left unstaged, except for files the stash added; with
+// restoreIndex, the stash's index changes are staged again instead.
+func stashApply(stash string, restoreIndex bool) (bool, error) {
+	_, content, err := readObject(stash)
+	if err != nil {
+		return false, err
+	}
+	c, err := object.ParseCommit(content)
+	if err != nil || len(c.Parents) < 2 {
+		return false, fmt.Errorf("'%s' is not a stash-like commit", stash)
+	}
+	baseTree, err := peelToTree(c.Parents[0])
+	if err != nil {
+		return false, err
+	}
+	indexTree, err := peelToTree(c.Parents[1])
+	if err != nil {
+		return false, err
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range idx.Entries {
+		if entry.Stage() != 0 {
+			return false, errors.New("cannot apply a stash in the middle of a merge")
+		}
+	}
+	currentTree, err := writeIndexTree(idx, "", false)
+	if err != nil {
+		return false, err
+	}
+
+	// The staged changes must apply cleanly on their own before anything
+	// is touched.
+	stagedTree := ""
+	if restoreIndex && indexTree != baseTree {
+		tree, conflicts, err := mergeTrees(baseTree, currentTree, indexTree, "")
+		if err != nil {
+			return false, err
+		}
+		if len(conflicts) > 0 {
+			return false, errors.New("conflicts in index; try without --index")
+		}
+		stagedTree = tree
+	}
+
+	conflicts, err := mergeTreesIntoWorktree(baseTree, currentTree, c.Tree, "Updated upstream", "Stashed changes")
+	if err != nil {
+		return false, err
+	}
+	if len(conflicts) > 0 {
+		return false, nil
+	}
+	if stagedTree != "" {
+		return true, resetPaths(stagedTree, nil)
+	}
+	return true, unstageStash(currentTree)
+}
+
+// unstageStash resets the index to tree, the index from before a stash
+// was applied, leaving the applied changes in the working tree only.
+// Files that tree does not have stay staged, so they are not lost from
+// view as untracked files.
+func unstageStash(tree string) error {
+	files, err := flattenTree(tree)
+	if err != nil {
+		return err
+	}
+	idx, err := readIndex()
+	if err != nil {
+		return err
+	}
+	var entries []*IndexEntry
+	for _, entry := range idx.Entries {
+		target, ok := files[entry.Path]
+		delete(files, entry.Path)
+		if ok && (entry.Hash != target.Hash || entry.ModeString() != target.Mode) {
+			if entry, err = newIndexEntry(entry.Path, target.Mode, target.Hash, nil); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry, err := newIndexEntry(name, files[name].Mode, files[name].Hash, nil)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	idx.Entries = entries
+	return idx.write()
+}
+
+// stashDrop removes the nth stash from the list. refs/stash moves to the
+// stash that is then newest, or is deleted with the last one.
+func stashDrop(n int) error {
+	entries, err := readReflog(stashRef)
+	if err != nil {
+		return err
+	}
+	i := len(entries) - 1 - n
+	if i > 0 && i < len(entries)-1 {
+		entries[i+1].Old = entries[i-1].New
+	} else if i == 0 && len(entries) > 1 {
+		entries[1].Old = zeroHash
+	}
+	entries = append(entries[:i], entries[i+1:]...)
+	if len(entries) == 0 {
+		return deleteRef(stashRef)
+	}
+	if err := writeReflog(stashRef, entries); err != nil {
+		return err
+	}
+	return writeRef(stashRef, entries[len(entries)-1].New)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStashKeepIndex(t *testing.T) {
+	for _, args := range [][]string{{"push", "--keep-index"}, {"--keep-index"}, {"-k", "-q"}} {
+		t.Run(args[0], func(t *testing.T) {
+			dir := newTestRepo(t)
+			commitFile(t, dir, "staged", "base\n", "add staged")
+			commitFile(t, dir, "unstaged", "base\n", "add unstaged")
+			write := func(name, content string) {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("staged", "staged\n")
+			runGit(t, dir, "add", "staged")
+			write("unstaged", "unstaged\n")
+
+			runGot(t, dir, append([]string{"stash"}, args...)...)
+			check := func(name, want string) {
+				t.Helper()
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("%s = %q, want %q", name, b, want)
+				}
+			}
+			check("staged", "staged\n")
+			check("unstaged", "base\n")
+			if got := runGit(t, dir, "diff", "--cached", "--name-only"); got != "staged\n" {
+				t.Errorf("staged after stash = %q, want %q", got, "staged\n")
+			}
+			if got := runGit(t, dir, "stash", "list"); got == "" {
+				t.Error("no stash entry was made")
+			}
+
+			runGit(t, dir, "reset", "-q", "--hard")
+			runGot(t, dir, "stash", "pop", "-q", "--index")
+			check("staged", "staged\n")
+			check("unstaged", "unstaged\n")
+			if got := runGit(t, dir, "diff", "--cached", "--name-only"); got != "staged\n" {
+				t.Errorf("staged after pop = %q, want %q", got, "staged\n")
+			}
+		})
+	}
+}
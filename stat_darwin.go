@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileStat fills in the stat fields os.FileInfo does not expose portably.
+func fileStat(entry *IndexEntry, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	entry.CtimeSec = uint32(st.Ctimespec.Sec)
+	entry.CtimeNsec = uint32(st.Ctimespec.Nsec)
+	entry.Dev = uint32(st.Dev)
+	entry.Ino = uint32(st.Ino)
+	entry.Uid = st.Uid
+	entry.Gid = st.Gid
+}
+
+// diskUsage returns the space a file takes on disk, as git reports it.
+func diskUsage(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// fileStat is a no-op where the platform stat structure is not known; the
+// ctime, device and inode fields stay zero and only mtime and size are
+// compared.
+func fileStat(entry *IndexEntry, info os.FileInfo) {}
+
+// diskUsage returns the space a file takes on disk, as git reports it.
+func diskUsage(info os.FileInfo) int64 {
+	return info.Size()
+}
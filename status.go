@@ -0,0 +1,485 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// statusChange is one changed path. Kind is the letter used by the short
+// format: A, M, D or T for a type change.
+type statusChange struct {
+	Path string `json:"path"`
+	Kind string `json:"status"`
+}
+
+// unmergedPath is a path with conflict stages in the index. Kind is the
+// two letter short format code, e.g. "UU" for both modified.
+type unmergedPath struct {
+	Path string `json:"path"`
+	Kind string `json:"status"`
+}
+
+type repoStatus struct {
+	Branch    string         `json:"branch,omitempty"`
+	Detached  bool           `json:"detached"`
+	Head      string         `json:"head,omitempty"`
+	Staged    []statusChange `json:"staged"`
+	Unmerged  []unmergedPath `json:"unmerged"`
+	Unstaged  []statusChange `json:"unstaged"`
+	Untracked []string       `json:"untracked"`
+}
+
+func runStatus(args []string) {
+	short, porcelain, showBranch := false, false, false
+	for _, arg := range args {
+		switch arg {
+		case "-s", "--short":
+			short = true
+		case "--porcelain":
+			short, porcelain = true, true
+		case "-b", "--branch":
+			showBranch = true
+		default:
+			handleError(errors.New("usage: got status [-s] [-b] [--porcelain]"))
+		}
+	}
+
+	status, err := readStatus()
+	if err != nil {
+		handleError(err)
+	}
+
+	switch {
+	case jsonOutput:
+		writeJSON(status)
+	case short:
+		// The porcelain format is for scripts, which want full paths.
+		writeShortStatus(status, showBranch, !porcelain)
+	default:
+		writeLongStatus(status)
+	}
+}
+
+// readStatus compares HEAD's tree, the index and the working tree.
+func readStatus() (*repoStatus, error) {
+	status := &repoStatus{
+		Staged:    []statusChange{},
+		Unmerged:  []unmergedPath{},
+		Unstaged:  []statusChange{},
+		Untracked: []string{},
+	}
+
+	branch, onBranch, err := currentBranch()
+	if err != nil {
+		return nil, err
+	}
+	status.Branch, status.Detached = branch, !onBranch
+
+	var headFiles map[string]TreeEntry
+	head, err := resolveRef("HEAD")
+	switch {
+	case errors.Is(err, errRefNotFound):
+		headFiles = make(map[string]TreeEntry)
+	case err != nil:
+		return nil, err
+	default:
+		status.Head = head
+		tree, err := peelToTree(head)
+		if err != nil {
+			return nil, err
+		}
+		if headFiles, err = flattenTree(tree); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	stages := make(map[string][]int)
+	staged := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		if entry.Stage() != 0 {
+			stages[entry.Path] = append(stages[entry.Path], entry.Stage())
+			continue
+		}
+		staged[entry.Path] = true
+
+		old, inHead := headFiles[entry.Path]
+		switch {
+		case !inHead:
+			status.Staged = append(status.Staged, statusChange{entry.Path, "A"})
+		case !sameType(old.Mode, entry.ModeString()):
+			status.Staged = append(status.Staged, statusChange{entry.Path, "T"})
+		case old.Hash != entry.Hash || old.Mode != entry.ModeString():
+			status.Staged = append(status.Staged, statusChange{entry.Path, "M"})
+		}
+
+		kind, err := worktreeChange(entry)
+		if err != nil {
+			return nil, err
+		}
+		if kind != "" {
+			status.Unstaged = append(status.Unstaged, statusChange{entry.Path, kind})
+		}
+	}
+	for name := range headFiles {
+		if !staged[name] && stages[name] == nil {
+			status.Staged = append(status.Staged, statusChange{name, "D"})
+		}
+	}
+	sort.Slice(status.Staged, func(i, j int) bool {
+		return status.Staged[i].Path < status.Staged[j].Path
+	})
+
+	for name, s := range stages {
+		status.Unmerged = append(status.Unmerged, unmergedPath{name, unmergedKind(s)})
+	}
+	sort.Slice(status.Unmerged, func(i, j int) bool {
+		return status.Unmerged[i].Path < status.Unmerged[j].Path
+	})
+
+	if status.Untracked, err = untrackedFiles(idx); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// sameType reports whether two modes are both files, both symlinks or
+// both gitlinks. The executable bit alone is not a type change.
+func sameType(a, b string) bool {
+	kind := func(mode string) string {
+		if mode == "100755" {
+			return "100644"
+		}
+		return mode
+	}
+	return kind(a) == kind(b)
+}
+
+// worktreeChange returns how the working tree copy of entry differs from
+// the staged one, or "" if it does not.
+func worktreeChange(entry *IndexEntry) (string, error) {
+	fullPath := filepath.FromSlash(entry.Path)
+	info, err := os.Lstat(fullPath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.IsDir() && entry.Mode != 0160000) {
+		return "D", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if entry.Mode == 0160000 {
+		if !isSubmodule(fullPath) {
+			return "", nil
+		}
+		hash, err := submoduleHead(fullPath)
+		if err != nil || hash == entry.Hash {
+			return "", nil
+		}
+		return "M", nil
+	}
+	if _, ok := unchangedHash(entry.Path, info); ok {
+		return "", nil
+	}
+
+	mode, content, err := readWorktreeBlob(fullPath)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !sameType(mode, entry.ModeString()):
+		return "T", nil
+	case mode != entry.ModeString() || hashBlob(content) != entry.Hash:
+		return "M", nil
+	}
+	return "", nil
+}
+
+// unmergedKind turns the stages present for a path into git's two letter
+// code: which sides added, modified or deleted it.
+func unmergedKind(stages []int) string {
+	var has [4]bool
+	for _, s := range stages {
+		has[s] = true
+	}
+	switch {
+	case has[1] && has[2] && has[3]:
+		return "UU"
+	case has[2] && has[3]:
+		return "AA"
+	case has[1] && has[2]:
+		return "UD"
+	case has[1] && has[3]:
+		return "DU"
+	case has[2]:
+		return "AU"
+	case has[3]:
+		return "UA"
+	}
+	return "DD"
+}
+
+var unmergedLabels = map[string]string{
+	"UU": "both modified:",
+	"AA": "both added:",
+	"UD": "deleted by them:",
+	"DU": "deleted by us:",
+	"AU": "added by us:",
+	"UA": "added by them:",
+	"DD": "both deleted:",
+}
+
+// untrackedFiles lists the files not in the index, leaving out ignored
+// ones. A directory holding nothing tracked is reported once, as "dir/",
+// the way git does.
+func untrackedFiles(idx *Index) ([]string, error) {
+	ignore, err := newIgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool)
+	trackedDirs := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		tracked[entry.Path] = true
+		for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	untracked := []string{}
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := os.ReadDir(filepath.FromSlash(dir))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Name() == ".git" {
+				continue
+			}
+			p := path.Join(dir, entry.Name())
+			if tracked[p] {
+				continue
+			}
+			if !trackedDirs[p] {
+				ignored, err := ignore.ignored(p, entry.IsDir())
+				if err != nil {
+					return err
+				}
+				if ignored {
+					continue
+				}
+			}
+			switch {
+			case !entry.IsDir():
+				untracked = append(untracked, p)
+			case trackedDirs[p]:
+				if err := walk(p); err != nil {
+					return err
+				}
+			default:
+				hasFiles, err := containsFiles(p, ignore)
+				if err != nil {
+					return err
+				}
+				if hasFiles {
+					untracked = append(untracked, p+"/")
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk("."); err != nil {
+		return nil, err
+	}
+	sort.Strings(untracked)
+	return untracked, nil
+}
+
+// containsFiles reports whether dir holds anything other than empty
+// directories and ignored files. A nested repository always counts.
+func containsFiles(dir string, ignore *ignoreMatcher) (bool, error) {
+	if isSubmodule(filepath.FromSlash(dir)) {
+		return true, nil
+	}
+	entries, err := os.ReadDir(filepath.FromSlash(dir))
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		ignored, err := ignore.ignored(p, entry.IsDir())
+		if err != nil {
+			return false, err
+		}
+		if ignored {
+			continue
+		}
+		if !entry.IsDir() {
+			return true, nil
+		}
+		found, err := containsFiles(p, ignore)
+		if found || err != nil {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// statusPaths returns how status shows a path from the top of the work
+// tree: as in git, relative to the current directory unless relative is
+// false or status.relativePaths is turned off, and quoted by quotePath.
+// The short formats quote paths with spaces too, so that each line still
+// splits into a code and a path.
+func statusPaths(relative, quoteSpace bool) func(string) string {
+	repo, err := currentRepo()
+	if err != nil {
+		return func(p string) string { return p }
+	}
+	quoteHigh := repo.Config.Bool("core", "quotePath", true)
+	relative = relative && repo.Config.Bool("status", "relativePaths", true)
+	return func(p string) string {
+		if relative {
+			p = relativePath(p)
+		}
+		return quotePath(p, quoteSpace, quoteHigh)
+	}
+}
+
+func writeShortStatus(status *repoStatus, showBranch, relative bool) {
+	show := statusPaths(relative, true)
+	if showBranch {
+		switch {
+		case status.Detached:
+			fmt.Println("## HEAD (no branch)")
+		case status.Head == "":
+			fmt.Printf("## No commits yet on %s\n", status.Branch)
+		default:
+			fmt.Printf("## %s\n", status.Branch)
+		}
+	}
+
+	codes := make(map[string][]byte)
+	code := func(p string) []byte {
+		if codes[p] == nil {
+			codes[p] = []byte("  ")
+		}
+		return codes[p]
+	}
+	for _, change := range status.Staged {
+		code(change.Path)[0] = change.Kind[0]
+	}
+	for _, change := range status.Unstaged {
+		code(change.Path)[1] = change.Kind[0]
+	}
+	for _, u := range status.Unmerged {
+		codes[u.Path] = []byte(u.Kind)
+	}
+
+	paths := make([]string, 0, len(codes))
+	for p := range codes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Printf("%s %s\n", codes[p], show(p))
+	}
+	for _, p := range status.Untracked {
+		fmt.Printf("?? %s\n", show(p))
+	}
+}
+
+var changeLabels = map[string]string{
+	"A": "new file:",
+	"M": "modified:",
+	"D": "deleted:",
+	"T": "typechange:",
+}
+
+func writeLongStatus(status *repoStatus) {
+	show := statusPaths(true, false)
+	hints := adviceEnabled("statusHints")
+	hint := func(line string) {
+		if hints {
+			fmt.Printf("  (%s)\n", line)
+		}
+	}
+
+	if status.Detached {
+		fmt.Printf("HEAD detached at %s\n", status.Head[:7])
+	} else {
+		fmt.Printf("On branch %s\n", status.Branch)
+	}
+	if status.Head == "" {
+		fmt.Printf("\nNo commits yet\n\n")
+	}
+
+	if len(status.Staged) > 0 {
+		fmt.Printf("Changes to be committed:\n")
+		if status.Head == "" {
+			hint(`use "got rm --cached <file>..." to unstage`)
+		} else {
+			hint(`use "got restore --staged <file>..." to unstage`)
+		}
+		for _, change := range status.Staged {
+			fmt.Printf("\t%-12s%s\n", changeLabels[change.Kind], show(change.Path))
+		}
+		fmt.Println()
+	}
+
+	if len(status.Unmerged) > 0 {
+		fmt.Printf("Unmerged paths:\n")
+		hint(`use "got add <file>..." to mark resolution`)
+		for _, u := range status.Unmerged {
+			fmt.Printf("\t%-17s%s\n", unmergedLabels[u.Kind], show(u.Path))
+		}
+		fmt.Println()
+	}
+
+	if len(status.Unstaged) > 0 {
+		fmt.Printf("Changes not staged for commit:\n")
+		hint(`use "got add <file>..." to update what will be committed`)
+		hint(`use "got restore <file>..." to discard changes in working directory`)
+		for _, change := range status.Unstaged {
+			fmt.Printf("\t%-12s%s\n", changeLabels[change.Kind], show(change.Path))
+		}
+		fmt.Println()
+	}
+
+	if len(status.Untracked) > 0 {
+		fmt.Printf("Untracked files:\n")
+		hint(`use "got add <file>..." to include in what will be committed`)
+		for _, p := range status.Untracked {
+			fmt.Printf("\t%s\n", show(p))
+		}
+		fmt.Println()
+	}
+
+	if len(status.Staged) > 0 || len(status.Unmerged) > 0 {
+		return
+	}
+	// The closing line's hint goes with the others.
+	trailer := func(line, hint string) {
+		if hints {
+			line += " (" + hint + ")"
+		}
+		fmt.Println(line)
+	}
+	switch {
+	case len(status.Unstaged) > 0:
+		trailer("no changes added to commit", `use "got add" and/or "got commit -a"`)
+	case len(status.Untracked) > 0:
+		trailer("nothing added to commit but untracked files present", `use "got add" to track`)
+	case status.Head == "":
+		trailer("nothing to commit", `create/copy files and use "got add" to track`)
+	default:
+		fmt.Println("nothing to commit, working tree clean")
+	}
+}
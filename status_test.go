@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newStatusRepo makes a repository with a staged, a modified and an
+// untracked path at the top and under a/b, and returns its work tree.
+func newStatusRepo(t *testing.T) string {
+	t.Helper()
+	dir := newTestRepo(t)
+	commitFile(t, dir, "d.txt", "d\n", "add d")
+	commitFile(t, dir, "a/b/c.txt", "c\n", "add c")
+	for name, content := range map[string]string{
+		"d.txt":       "changed\n",
+		"a/b/c.txt":   "changed\n",
+		"a/new.txt":   "new\n",
+		"e/f.txt":     "untracked\n",
+		"a/b/u.txt":   "untracked\n",
+		"staged.txt":  "staged\n",
+		"a/b/s.txt":   "staged\n",
+		"a/b/s/x.txt": "untracked dir\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, dir, "add", "staged.txt", "a/b/s.txt", "a/new.txt")
+	return dir
+}
+
+func TestStatusRelativePaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		subdir string
+		args   []string
+		config []string
+	}{
+		{name: "short at the top", args: []string{"--short"}},
+		{name: "short in a subdirectory", subdir: "a/b", args: []string{"--short"}},
+		{name: "short one level down", subdir: "a", args: []string{"-s"}},
+		{name: "short with relativePaths off", subdir: "a/b", args: []string{"-s"}, config: []string{"status.relativePaths", "false"}},
+		{name: "porcelain in a subdirectory", subdir: "a/b", args: []string{"--porcelain"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newStatusRepo(t)
+			if tt.config != nil {
+				runGit(t, dir, append([]string{"config"}, tt.config...)...)
+			}
+			cwd := filepath.Join(dir, filepath.FromSlash(tt.subdir))
+			args := append([]string{"status"}, tt.args...)
+			want := runGit(t, cwd, args...)
+			if got := runGot(t, cwd, args...); got != want {
+				t.Errorf("got %s in %q:\n%s\ngit has:\n%s", strings.Join(args, " "), tt.subdir, got, want)
+			}
+		})
+	}
+}
+
+func TestLongStatusRelativePaths(t *testing.T) {
+	dir := newStatusRepo(t)
+	out := runGot(t, filepath.Join(dir, "a", "b"), "status")
+	for _, want := range []string{
+		"new file:   ../new.txt",
+		"new file:   s.txt",
+		"modified:   ../../d.txt",
+		"modified:   c.txt",
+		"\t../../e/\n",
+		"\ts/\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status does not show %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestStatusHintsAdvice(t *testing.T) {
+	tests := []struct {
+		name   string
+		config []string
+		want   bool
+	}{
+		{name: "default", want: true},
+		{name: "enabled", config: []string{"advice.statusHints", "true"}, want: true},
+		{name: "disabled", config: []string{"advice.statusHints", "false"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newStatusRepo(t)
+			if tt.config != nil {
+				runGit(t, dir, append([]string{"config"}, tt.config...)...)
+			}
+			out := runGot(t, dir, "status")
+			for _, hint := range []string{
+				`(use "got restore --staged <file>..." to unstage)`,
+				`(use "got add <file>..." to update what will be committed)`,
+				`(use "got add <file>..." to include in what will be committed)`,
+			} {
+				if got := strings.Contains(out, hint); got != tt.want {
+					t.Errorf("hint %s shown = %v, want %v:\n%s", hint, got, tt.want, out)
+				}
+			}
+			if !strings.Contains(out, "modified:   d.txt") {
+				t.Errorf("status does not list d.txt as modified:\n%s", out)
+			}
+
+			// With nothing staged, the closing line carries a hint too.
+			runGit(t, dir, "reset", "-q")
+			out = runGot(t, dir, "status")
+			if got := strings.Contains(out, `no changes added to commit (use "got add"`); got != tt.want {
+				t.Errorf("closing hint shown = %v, want %v:\n%s", got, tt.want, out)
+			}
+			if !strings.Contains(out, "no changes added to commit") {
+				t.Errorf("status has no closing line:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestStatusQuotedPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		config []string
+	}{
+		{name: "short", args: []string{"-s"}},
+		{name: "porcelain", args: []string{"--porcelain"}},
+		{name: "long", args: nil},
+		{name: "short with quotePath off", args: []string{"-s"}, config: []string{"core.quotePath", "false"}},
+		{name: "long with quotePath off", args: nil, config: []string{"core.quotePath", "false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+			commitFile(t, dir, "with space", "a\n", "add a path with a space")
+			for name, content := range map[string]string{
+				"with space":   "changed\n",
+				"tab\there":    "untracked\n",
+				`quote"slash\`: "untracked\n",
+				"n\u00efve":    "untracked\n",
+				"dir x/file":   "untracked\n",
+				"plain":        "untracked\n",
+			} {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The hints name the program, so leave them out to compare.
+			runGit(t, dir, "config", "advice.statusHints", "false")
+			if tt.config != nil {
+				runGit(t, dir, append([]string{"config"}, tt.config...)...)
+			}
+			args := append([]string{"status"}, tt.args...)
+			want := runGit(t, dir, args...)
+			if got := runGot(t, dir, args...); got != want {
+				t.Errorf("got %s:\n%s\ngit has:\n%s", strings.Join(args, " "), got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runTag(args []string) {
+	usage := errors.New("usage: got tag [-f] [-a] [-m <message> | -F <file>] <name> [<object>] | -d <name>... | -l [-n[<num>]] [<pattern>...]")
+	var annotate, force, del, list bool
+	var message string
+	var hasMessage bool
+	lines := 0
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-a" || arg == "--annotate":
+			annotate = true
+		case arg == "-f" || arg == "--force":
+			force = true
+		case arg == "-d" || arg == "--delete":
+			del = true
+		case arg == "-l" || arg == "--list":
+			list = true
+		case arg == "-n":
+			lines = 1
+		case strings.HasPrefix(arg, "-n") && isDigits(arg[2:]):
+			lines, _ = strconv.Atoi(arg[2:])
+		case arg == "-m" || arg == "-F" || arg == "--file":
+			if i+1 >= len(args) {
+				handleError(fmt.Errorf("option %s requires a value", arg))
+			}
+			i++
+			if arg == "-m" {
+				message = args[i]
+			} else {
+				m, err := readMessageFile(prefixFilename(args[i]))
+				if err != nil {
+					handleError(err)
+				}
+				message = m
+			}
+			hasMessage = true
+			annotate = true
+		case strings.HasPrefix(arg, "-"):
+			handleError(usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if del {
+		if len(positional) == 0 || list || annotate || force {
+			handleError(usage)
+		}
+		failed := false
+		for _, name := range positional {
+			if err := deleteTag(name); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				failed = true
+			}
+		}
+		if failed {
+			exit(1)
+		}
+		return
+	}
+	if list || (len(positional) == 0 && !annotate) {
+		if annotate || force {
+			handleError(usage)
+		}
+		if err := listTags(positional, lines); err != nil {
+			handleError(err)
+		}
+		return
+	}
+	if lines > 0 || len(positional) < 1 || len(positional) > 2 {
+		handleError(usage)
+	}
+	message = cleanupMessage(message)
+	if annotate && message == "" {
+		if hasMessage {
+			handleError(errors.New("aborting tag due to empty message"))
+		}
+		handleError(errors.New("an annotated tag needs a message (-m or -F)"))
+	}
+
+	name := positional[0]
+	if err := checkRefName("tags/" + name); err != nil {
+		handleError(fmt.Errorf("'%s' is not a valid tag name", name))
+	}
+	ref := "refs/tags/" + name
+	old, err := resolveRef(ref)
+	if err != nil && !errors.Is(err, errRefNotFound) {
+		handleError(err)
+	}
+	if old != "" && !force {
+		handleError(fmt.Errorf("tag '%s' already exists", name))
+	}
+
+	target := "HEAD"
+	if len(positional) == 2 {
+		target = positional[1]
+	}
+	hash, err := resolveRevision(target)
+	if err != nil {
+		handleError(err)
+	}
+
+	if annotate {
+		hash, err = writeTag(name, hash, message)
+		if err != nil {
+			handleError(err)
+		}
+	}
+
+	if err := writeRef(ref, hash); err != nil {
+		handleError(err)
+	}
+	if old != "" && old != hash {
+		fmt.Printf("Updated tag '%s' (was %s)\n", name, old[:7])
+	}
+}
+
+// deleteTag removes refs/tags/<name>.
+func deleteTag(name string) error {
+	ref := "refs/tags/" + name
+	old, err := resolveRef(ref)
+	if errors.Is(err, errRefNotFound) {
+		return fmt.Errorf("tag '%s' not found.", name)
+	}
+	if err != nil {
+		return err
+	}
+	if err := deleteRef(ref); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted tag '%s' (was %s)\n", name, old[:7])
+	return nil
+}
+
+// writeTag creates an annotated tag object pointing at target.
+func writeTag(name, target, message string) (string, error) {
+	objectType, _, err := readObject(target)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := currentRepo()
+	if err != nil {
+		return "", err
+	}
+	tagger, err := repo.identity("committer", time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "object %s\n", target)
+	fmt.Fprintf(&content, "type %s\n", objectType)
+	fmt.Fprintf(&content, "tag %s\n", name)
+	fmt.Fprintf(&content, "tagger %s\n", tagger)
+	content.WriteString("\n")
+	content.WriteString(message)
+	if !strings.HasSuffix(message, "\n") {
+		content.WriteString("\n")
+	}
+
+	return writeObject("tag", content.Bytes())
+}
+
+// listTags prints the tags matching any of patterns, or all of them. With
+// lines above zero, that many lines of each tag's message follow its name;
+// a lightweight tag shows its commit's subject instead.
+func listTags(patterns []string, lines int) error {
+	refs, err := listRefs()
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		name, found := strings.CutPrefix(ref, "refs/tags/")
+		if !found || !matchesAnyPattern(name, patterns) {
+			continue
+		}
+		if lines == 0 {
+			fmt.Println(name)
+			continue
+		}
+		annotation, err := tagAnnotation(ref, lines)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-15s %s\n", name, strings.Join(annotation, "\n    "))
+	}
+	return nil
+}
+
+// matchesAnyPattern reports whether name matches one of the shell glob
+// patterns. No patterns match everything.
+func matchesAnyPattern(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// tagAnnotation returns up to n lines of the message of the tag or commit
+// that ref points at.
+func tagAnnotation(ref string, n int) ([]string, error) {
+	hash, err := resolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	objectType, content, err := readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	var message string
+	switch objectType {
+	case "tag", "commit":
+		_, message, _ = strings.Cut(string(content), "\n\n")
+	default:
+		return nil, nil
+	}
+	all := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all, nil
+}
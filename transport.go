@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	registerCapability("protocols", "http, https, ssh, git (smart, v0 and v2), bundle files")
+}
+
+// Transport carries the pack protocol to a remote repository. A session
+// starts with discoverRefs for one service, asking for protocol version 2
+// when version is 2; call then sends that service a request and returns its
+// response, once for version 0 and once per command for version 2. close
+// ends the session.
+type Transport interface {
+	discoverRefs(service string, version int) (*refAdvertisement, error)
+	call(service string, body []byte) (io.ReadCloser, error)
+	close() error
+}
+
+// openRemote returns a transport for the repository at url: http:// and
+// https:// use smart HTTP, git:// the git daemon, and ssh:// or scp-like
+// [user@]host:path run the service over ssh. A bundle file is read
+// directly.
+func openRemote(url string) (Transport, error) {
+	switch {
+	case isBundle(url):
+		b, err := readBundle(url)
+		if err != nil {
+			return nil, err
+		}
+		return &bundleTransport{b: b}, nil
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"):
+		return &httpTransport{url: strings.TrimSuffix(url, "/")}, nil
+	case strings.HasPrefix(url, "git://"):
+		return openGitDaemon(url)
+	case strings.HasPrefix(url, "ssh://"), strings.HasPrefix(url, "git+ssh://"), strings.HasPrefix(url, "ssh+git://"):
+		return openSSH(url)
+	case !strings.Contains(url, "://") && isSCPLike(url):
+		return openSSH(url)
+	}
+	return nil, fmt.Errorf("unsupported URL '%s'", url)
+}
+
+// protocolVersion returns the protocol version to ask servers for: the
+// protocol.version setting, or 2 as in git. Outside a repository there is
+// no setting to read.
+func protocolVersion() int {
+	if repo, err := currentRepo(); err == nil {
+		if v, ok := repo.Config.Get("protocol", "version"); ok && (v == "0" || v == "1") {
+			return 0
+		}
+	}
+	return 2
+}
+
+// isSCPLike reports whether url has the form [user@]host:path, with the
+// colon before any slash.
+func isSCPLike(url string) bool {
+	colon := strings.Index(url, ":")
+	slash := strings.Index(url, "/")
+	return colon > 0 && (slash < 0 || colon < slash)
+}
+
+// httpTransport talks git's smart HTTP protocol to a repository URL. Every
+// request is a separate HTTP exchange, so there is nothing to close.
+type httpTransport struct {
+	url string
+	// protocol is the Git-Protocol header sent with every request, once
+	// version 2 has been asked for.
+	protocol string
+}
+
+func (h *httpTransport) close() error { return nil }
+
+// discoverRefs fetches the ref advertisement for service, either
+// git-upload-pack or git-receive-pack.
+func (h *httpTransport) discoverRefs(service string, version int) (*refAdvertisement, error) {
+	req, err := http.NewRequest("GET", h.url+"/info/refs?service="+service, nil)
+	if err != nil {
+		return nil, err
+	}
+	if version == 2 {
+		h.protocol = "version=2"
+		req.Header.Set("Git-Protocol", h.protocol)
+	}
+	req.Header.Set("User-Agent", "got/"+gotVersion)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to access '%s': the server returned %s", h.url, resp.Status)
+	}
+	if resp.Header.Get("Content-Type") != "application/x-"+service+"-advertisement" {
+		return nil, fmt.Errorf("%s: dumb HTTP servers are not supported", h.url)
+	}
+
+	// Smart HTTP puts a "# service=..." announcement and a flush in front
+	// of the refs. Servers answering in version 2 leave it out.
+	body := bufio.NewReader(resp.Body)
+	if start, _ := body.Peek(4 + len("# service=")); string(start[min(4, len(start)):]) == "# service=" {
+		line, err := readPktLine(body)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSuffix(string(line), "\n") != "# service="+service {
+			return nil, fmt.Errorf("%s: unexpected service announcement %q", h.url, line)
+		}
+		if _, err := readPktLine(body); !errors.Is(err, errFlush) {
+			return nil, fmt.Errorf("%s: expected flush after service announcement", h.url)
+		}
+	}
+	return readRefAdvertisement(body)
+}
+
+// call POSTs a request body to service and returns the response body.
+func (h *httpTransport) call(service string, body []byte) (io.ReadCloser, error) {
+	req, err := http.NewRequest("POST", h.url+"/"+service, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "got/"+gotVersion)
+	if h.protocol != "" {
+		req.Header.Set("Git-Protocol", h.protocol)
+	}
+	req.Header.Set("Content-Type", "application/x-"+service+"-request")
+	req.Header.Set("Accept", "application/x-"+service+"-result")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: the server returned %s", h.url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// streamTransport runs a service over one bidirectional connection, as
+// the ssh and git daemon transports do: the advertisement, the request
+// and the response all share it.
+type streamTransport struct {
+	url     string
+	connect func(service string, version int) (io.Reader, io.WriteCloser, func() error, error)
+
+	r       io.Reader
+	w       io.WriteCloser
+	wait    func() error
+	used    bool
+	version int
+}
+
+func (s *streamTransport) discoverRefs(service string, version int) (*refAdvertisement, error) {
+	if s.r != nil {
+		return nil, errors.New("transport is already connected")
+	}
+	r, w, wait, err := s.connect(service, version)
+	if err != nil {
+		return nil, err
+	}
+	s.r, s.w, s.wait = r, w, wait
+	adv, err := readRefAdvertisement(s.r)
+	if err != nil {
+		s.close()
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("could not read from remote repository '%s'", s.url)
+		}
+		return nil, err
+	}
+	s.version = adv.Version
+	return adv, nil
+}
+
+func (s *streamTransport) call(service string, body []byte) (io.ReadCloser, error) {
+	if s.r == nil || (s.used && s.version != 2) {
+		return nil, errors.New("transport is not connected")
+	}
+	s.used = true
+	if _, err := s.w.Write(body); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(s.r), nil
+}
+
+// close ends the session. A service that was never sent a request, or
+// that takes commands under version 2, is told with a flush that the
+// client wants nothing more.
+func (s *streamTransport) close() error {
+	if s.r == nil {
+		return nil
+	}
+	if !s.used || s.version == 2 {
+		writeFlush(s.w)
+	}
+	s.w.Close()
+	err := s.wait()
+	s.r, s.w, s.wait, s.used, s.version = nil, nil, nil, false, 0
+	return err
+}
+
+// openGitDaemon returns a transport for git://host[:port]/path.
+func openGitDaemon(url string) (Transport, error) {
+	u, err := neturl.Parse(url)
+	if err != nil || u.Host == "" || u.Path == "" {
+		return nil, fmt.Errorf("invalid URL '%s'", url)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "9418")
+	}
+	connect := func(service string, version int) (io.Reader, io.WriteCloser, func() error, error) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// The daemon's request names the service, the path and the host
+		// the client asked for, then any extra parameters after a second
+		// NUL.
+		request := service + " " + u.Path + "\x00host=" + u.Host + "\x00"
+		if version == 2 {
+			request += "\x00version=2\x00"
+		}
+		if err := writePktLine(conn, request); err != nil {
+			conn.Close()
+			return nil, nil, nil, err
+		}
+		return conn, conn, func() error { return nil }, nil
+	}
+	return &streamTransport{url: url, connect: connect}, nil
+}
+
+// openSSH returns a transport for ssh://[user@]host[:port]/path or
+// [user@]host:path. The command comes from GIT_SSH_COMMAND, or is ssh.
+func openSSH(url string) (Transport, error) {
+	var host, port, path string
+	if strings.Contains(url, "://") {
+		u, err := neturl.Parse(url)
+		if err != nil || u.Host == "" || u.Path == "" {
+			return nil, fmt.Errorf("invalid URL '%s'", url)
+		}
+		host, port, path = u.Hostname(), u.Port(), u.Path
+		if u.User != nil {
+			host = u.User.Username() + "@" + host
+		}
+		// ssh://host/~user/repo is relative to that user's home.
+		if strings.HasPrefix(path, "/~") {
+			path = path[1:]
+		}
+	} else {
+		host, path, _ = strings.Cut(url, ":")
+	}
+	if strings.HasPrefix(host, "-") || path == "" {
+		return nil, fmt.Errorf("invalid URL '%s'", url)
+	}
+
+	connect := func(service string, version int) (io.Reader, io.WriteCloser, func() error, error) {
+		var args []string
+		if port != "" {
+			args = append(args, "-p", port)
+		}
+		args = append(args, host, service+" "+shellQuote(path))
+		sshCommand := os.Getenv("GIT_SSH_COMMAND")
+		// The version is passed in GIT_PROTOCOL, which OpenSSH only sends
+		// when told to. A custom command is left to pass it on itself.
+		if version == 2 && sshCommand == "" {
+			args = append([]string{"-o", "SendEnv=GIT_PROTOCOL"}, args...)
+		}
+		cmd := exec.Command("ssh", args...)
+		if sshCommand != "" {
+			cmd = exec.Command("sh", append([]string{"-c", sshCommand + ` "$@"`, sshCommand}, args...)...)
+		}
+		if version == 2 {
+			cmd.Env = append(os.Environ(), "GIT_PROTOCOL=version=2")
+		}
+		cmd.Stderr = os.Stderr
+		w, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		r, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, nil, err
+		}
+		return r, w, cmd.Wait, nil
+	}
+	return &streamTransport{url: url, connect: connect}, nil
+}
+
+// shellQuote quotes s for a POSIX shell, the way the remote end of ssh
+// will parse the command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
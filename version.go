@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+)
+
+const gotVersion = "0.1.0"
+
+// capabilities records what this build of got can do, for
+// "got version --build-options". Features register themselves with
+// registerCapability; anything not registered is reported as "no".
+var capabilities = map[string]string{
+	"hash-algorithms": "sha1",
+	"protocols":       "no",
+	"pack-read":       "no",
+	"pack-write":      "no",
+	"commit-graph":    "no",
+	"reftable":        "no",
+}
+
+func registerCapability(name, value string) {
+	capabilities[name] = value
+}
+
+func runVersion(args []string) {
+	buildOptions := false
+	for _, arg := range args {
+		if arg == "--build-options" {
+			buildOptions = true
+		} else {
+			handleError(errors.New("usage: got version [--build-options]"))
+		}
+	}
+
+	fmt.Printf("got version %s\n", gotVersion)
+	if !buildOptions {
+		return
+	}
+
+	fmt.Printf("go: %s\n", runtime.Version())
+	fmt.Printf("os/arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+
+	names := make([]string, 0, len(capabilities))
+	for name := range capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %s\n", name, capabilities[name])
+	}
+}
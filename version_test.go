@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionArguments(t *testing.T) {
+	dir := t.TempDir()
+	if got := runGot(t, dir, "version"); !strings.HasPrefix(got, "got version ") || strings.Count(got, "\n") != 1 {
+		t.Errorf("version printed %q, want one got version line", got)
+	}
+	if got := runGot(t, dir, "version", "--build-options"); !strings.Contains(got, "\nhash-algorithms: sha1\n") {
+		t.Errorf("version --build-options printed no capabilities:\n%s", got)
+	}
+	for _, args := range [][]string{{"version", "extra"}, {"version", "--bogus"}, {"version", "--build-options", "extra"}} {
+		out, stderr, err := runCommandStderr(dir, gotBinary, args...)
+		if err == nil || out != "" || !strings.Contains(stderr, "usage: got version") {
+			t.Errorf("got %s = %q, %q, %v, want a usage error", strings.Join(args, " "), out, stderr, err)
+		}
+	}
+}
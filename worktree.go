@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushyadav1617/got/object"
+)
+
+// convertToWorktree applies the core.autocrlf output conversion: when it
+// is true, LF line endings in text blobs become CRLF on checkout.
+func convertToWorktree(content []byte) ([]byte, error) {
+	repo, err := currentRepo()
+	if err != nil {
+		return nil, err
+	}
+	if !repo.Config.Bool("core", "autocrlf", false) || isBinary(content) {
+		return content, nil
+	}
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(normalized, []byte("\n"), []byte("\r\n")), nil
+}
+
+// readWorktreeBlob returns the mode and blob content a working tree file
+// would be stored with. Symlinks are stored as a blob holding the link
+// target, never the contents of the file they point at.
+func readWorktreeBlob(fullPath string) (string, []byte, error) {
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return "", nil, err
+		}
+		return "120000", []byte(target), nil
+	}
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err = convertToGit(content)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return worktreeMode(info), content, nil
+}
+
+// writeWorktreeBlob stores the blob for the working tree file whose Lstat
+// info is given and returns its mode and hash. Regular files are streamed
+// unless core.autocrlf converts them, as in hash-object, so that adding a
+// large file does not read it all into memory.
+func writeWorktreeBlob(fullPath string, info os.FileInfo) (string, string, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		mode, content, err := readWorktreeBlob(fullPath)
+		if err != nil {
+			return "", "", err
+		}
+		hash, err := writeObject("blob", content)
+		return mode, hash, err
+	}
+	hash, err := hashFile(fullPath, true)
+	return worktreeMode(info), hash, err
+}
+
+// lookupTreePath finds the entry for a slash-separated path inside the tree
+// with the given hash.
+func lookupTreePath(treeHash, name string) (TreeEntry, error) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	hash := treeHash
+	for i, part := range parts {
+		entries, err := readTreeEntries(hash)
+		if err != nil {
+			return TreeEntry{}, err
+		}
+		entry, ok := entries[part]
+		if !ok {
+			return TreeEntry{}, fmt.Errorf("path '%s' does not exist in tree %s: %w", name, treeHash, fs.ErrNotExist)
+		}
+		if i == len(parts)-1 {
+			return entry, nil
+		}
+		if !object.IsTreeMode(entry.Mode) {
+			return TreeEntry{}, fmt.Errorf("path '%s' does not exist in tree %s: %w", name, treeHash, fs.ErrNotExist)
+		}
+		hash = entry.Hash
+	}
+	return TreeEntry{}, fmt.Errorf("invalid path '%s'", name)
+}
+
+// walkTree calls fn for every non-tree entry below the tree with the given
+// hash, with slash-separated paths relative to prefix.
+func walkTree(hash, prefix string, fn func(path string, entry TreeEntry) error) error {
+	entries, err := readTreeEntries(hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range sortedTreeEntries(entries) {
+		p := path.Join(prefix, entry.Name)
+		if object.IsTreeMode(entry.Mode) {
+			if err := walkTree(entry.Hash, p, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(p, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedTreeEntries(entries map[string]TreeEntry) []TreeEntry {
+	list := make([]TreeEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	object.SortTree(list)
+	return list
+}
+
+// checkoutEntry writes a blob or symlink entry to the working tree at the
+// slash-separated path, replacing whatever is there.
+func checkoutEntry(name string, entry TreeEntry) error {
+	fullPath := filepath.FromSlash(name)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	if entry.Mode == "160000" {
+		// Submodules are checked out separately; just make sure the
+		// directory exists.
+		return os.MkdirAll(fullPath, 0755)
+	}
+
+	_, content, err := readObject(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if entry.Mode == "120000" {
+		return os.Symlink(string(content), fullPath)
+	}
+
+	content, err = convertToWorktree(content)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0644)
+	if entry.Mode == "100755" {
+		perm = 0755
+	}
+	return os.WriteFile(fullPath, content, perm)
+}